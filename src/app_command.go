@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"hilmy.dev/store/src/libs/gracefulshutdown"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+const usage = `usage: store <command> [arguments]
+
+commands:
+  serve                                         start the HTTP server (default)
+  migrate up [steps] | down [steps] | status    manage database schema migrations
+  user create | reset-password | set-role       manage accounts
+  balance adjust                                add to or subtract from an account balance
+  seed                                          insert sample product categories and products
+  jwt keygen                                    generate an RSA private key for signing tokens
+
+run "store <command> -h" for the flags of a command`
+
+// exitOnError prints err and exits with a non-zero status, for administrative commands that have no request to fail.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	closeResources()
+	logger.Error(err, &applogger.Options{IsExit: true})
+}
+
+// closeResources runs the shutdown hooks registered by NewDB and friends, since commands never receive a shutdown signal.
+func closeResources() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := gracefulshutdown.Shutdown(ctx); err != nil {
+		logger.Error(err, &applogger.Options{IsPrintStack: false})
+	}
+}
+
+func exitWithUsage(usage string) {
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+}
+
+// readSecret reads a single line from stdin so that secrets don't have to be passed as flags and end up in shell history.
+func readSecret(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		exitOnError(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
@@ -4,16 +4,52 @@ import (
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/config"
+	"hilmy.dev/store/src/constants"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/env"
+	"hilmy.dev/store/src/libs/metrics"
+	"hilmy.dev/store/src/libs/openapi"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	am "hilmy.dev/store/src/modules/auth/auth_middleware"
+	"hilmy.dev/store/src/modules/health"
+)
+
+const (
+	openAPIPath   = "/api/v1/openapi.json"
+	openAPIUIPath = "/api/v1/docs"
+	uploadsPath   = "/uploads"
 )
 
 func (m *module) controller() {
 	m.app.Get("/", m.rootController)
+
+	openAPIConfig := &openapi.Config{
+		Info: &openapi.Info{
+			Title:   config.Get().App.Name,
+			Version: health.Version,
+		},
+		Path: openAPIPath,
+	}
+	if config.Get().App.Mode != constants.APP_MODE_RELEASE {
+		openAPIConfig.UIPath = openAPIUIPath
+	}
+	openapi.Serve(m.app, openAPIConfig)
+
+	if storageConf := config.Get().Storage; storageConf.Driver == constants.STORAGE_DRIVER_LOCAL {
+		m.app.Static(uploadsPath, storageConf.LocalDir, fiber.Static{
+			MaxAge: 86400,
+		})
+	}
+
+	if metricsAddress := config.Get().Metrics.Address; len(metricsAddress) > 0 {
+		metrics.Serve(metricsAddress)
+	} else {
+		m.app.Get("/metrics", am.AuthGuard(acc.ROLE_ADMIN), metrics.Handler())
+	}
 }
 
 func (*module) rootController(c *fiber.Ctx) error {
 	return c.JSON(&contracts.Response{
-		Data: fmt.Sprintf("%s is running", env.Get(env.APP_NAME)),
+		Data: fmt.Sprintf("%s is running", config.Get().App.Name),
 	})
 }
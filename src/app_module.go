@@ -1,23 +1,27 @@
 package main
 
 import (
-	"strconv"
-	"time"
-
 	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/config"
+	"hilmy.dev/store/src/constants"
 	"hilmy.dev/store/src/libs/db/mongo"
 	"hilmy.dev/store/src/libs/db/pg"
-	"hilmy.dev/store/src/libs/env"
 	"hilmy.dev/store/src/libs/hash/argon2"
 	"hilmy.dev/store/src/libs/jwx/jwt"
+	"hilmy.dev/store/src/libs/storage"
+	"hilmy.dev/store/src/libs/tracing"
+	"hilmy.dev/store/src/migrations"
 	"hilmy.dev/store/src/modules/account"
 	"hilmy.dev/store/src/modules/auth"
 	"hilmy.dev/store/src/modules/balance"
+	"hilmy.dev/store/src/modules/health"
 	"hilmy.dev/store/src/modules/log"
 	"hilmy.dev/store/src/modules/product"
 	productcategory "hilmy.dev/store/src/modules/product_category"
+	productreview "hilmy.dev/store/src/modules/product_review"
 	shoppingcart "hilmy.dev/store/src/modules/shopping_cart"
 	"hilmy.dev/store/src/modules/transaction"
+	"hilmy.dev/store/src/modules/trash"
 )
 
 type module struct {
@@ -25,75 +29,72 @@ type module struct {
 }
 
 func (m *module) load() {
-	// PostgreSQL database
-	pgDB := pg.NewDB(&pg.Config{
-		Address:      env.Get(env.POSTGRES_ADDRESS),
-		User:         env.Get(env.POSTGRES_USER),
-		Password:     env.Get(env.POSTGRES_PASSWORD),
-		DatabaseName: env.Get(env.POSTGRES_DB),
+	conf := config.Get()
+
+	// OpenTelemetry tracing
+	tracing.Init(&tracing.Config{
+		ServiceName:  conf.App.Name,
+		Exporter:     conf.Tracing.Exporter,
+		OTLPEndpoint: conf.Tracing.OTLPEndpoint,
+		OTLPInsecure: conf.Tracing.OTLPInsecure,
+		SampleRatio:  conf.Tracing.SampleRatio,
 	})
 
+	// PostgreSQL database
+	pgDB := newPgDB()
+	if err := pg.MigrateUp(pgDB, migrations.FS, 0); err != nil {
+		logger.Panic(err)
+	}
+
 	// MongoDB database
 	mongoDBClient := mongo.NewClient(&mongo.Config{
-		Address:  env.Get(env.MONGO_ADDRESS),
-		User:     env.Get(env.MONGO_INITDB_ROOT_USERNAME),
-		Password: env.Get(env.MONGO_INITDB_ROOT_PASSWORD),
+		Address:  conf.Mongo.Address,
+		User:     conf.Mongo.User,
+		Password: conf.Mongo.Password,
 	})
 
 	// JWT
-	jwt.Init(&jwt.Config{
-		Duration: func() *time.Duration {
-			duration, err := time.ParseDuration(env.Get(env.JWT_DURATION))
-			if err != nil {
-				logger.Panic(err)
-			}
-			return &duration
-		}(),
-	})
+	initJWT()
 
 	// Argon2
-	argon2.Init(&argon2.Config{
-		Memory: func() uint32 {
-			hashMemory, err := strconv.ParseUint(env.Get(env.HASH_MEMORY), 10, 32)
-			if err != nil {
-				logger.Panic(err)
-			}
-			return uint32(hashMemory)
-		}(),
-		Iterations: func() uint32 {
-			hashIterations, err := strconv.ParseUint(env.Get(env.HASH_ITERATIONS), 10, 32)
-			if err != nil {
-				logger.Panic(err)
-			}
-			return uint32(hashIterations)
-		}(),
-		Parallelism: func() uint8 {
-			hashParallelism, err := strconv.ParseUint(env.Get(env.HASH_PARALLELISM), 10, 8)
-			if err != nil {
-				logger.Panic(err)
-			}
-			return uint8(hashParallelism)
-		}(),
-		SaltLength: func() int {
-			hashSaltLength, err := strconv.Atoi(env.Get(env.HASH_SALTLENGTH))
-			if err != nil {
-				logger.Panic(err)
-			}
-			return hashSaltLength
-		}(),
-		KeyLength: func() uint32 {
-			hashKeyLength, err := strconv.ParseUint(env.Get(env.HASH_KEYLENGTH), 10, 32)
-			if err != nil {
-				logger.Panic(err)
-			}
-			return uint32(hashKeyLength)
-		}(),
-	})
+	initArgon2()
+
+	// File storage
+	fileStorage := newStorage()
 
 	m.controller()
 
+	m.loadModules(&dependencies{
+		pgDB:          pgDB,
+		mongoDBClient: mongoDBClient,
+		storage:       fileStorage,
+	})
+}
+
+// dependencies are the connections the feature modules are loaded with, which tests replace with ones that are never
+// connected.
+type dependencies struct {
+	pgDB          *pg.DB
+	mongoDBClient *mongo.Client
+	storage       storage.Storage
+}
+
+// loadModules registers the routes of every feature module.
+func (m *module) loadModules(deps *dependencies) {
+	conf := config.Get()
+	pgDB := deps.pgDB
+	fileStorage := deps.storage
+
+	health.Load(&health.Module{
+		App:          m.app,
+		DB:           pgDB,
+		DBClient:     deps.mongoDBClient,
+		DrainDelay:   conf.App.ShutdownDrainDelay,
+		IsHideErrors: conf.App.Mode == constants.APP_MODE_RELEASE,
+	})
+
 	log.Load(&log.Module{
-		DBClient: mongoDBClient,
+		DBClient: deps.mongoDBClient,
 	})
 
 	account.Load(&account.Module{
@@ -116,8 +117,11 @@ func (m *module) load() {
 	})
 
 	product.Load(&product.Module{
-		App: m.app,
-		DB:  pgDB,
+		App:           m.app,
+		DB:            pgDB,
+		Storage:       fileStorage,
+		ImageMaxSize:  conf.Image.MaxSize,
+		ImageMaxCount: conf.Image.MaxCount,
 	})
 
 	shoppingcart.Load(&shoppingcart.Module{
@@ -129,4 +133,92 @@ func (m *module) load() {
 		App: m.app,
 		DB:  pgDB,
 	})
+
+	productreview.Load(&productreview.Module{
+		App:           m.app,
+		DB:            pgDB,
+		Storage:       fileStorage,
+		ImageMaxSize:  conf.Image.MaxSize,
+		ImageMaxCount: conf.Image.ReviewMaxCount,
+	})
+
+	trash.Load(&trash.Module{
+		App:           m.app,
+		DB:            pgDB,
+		Storage:       fileStorage,
+		Retention:     conf.Trash.Retention,
+		PurgeInterval: conf.Trash.PurgeInterval,
+	})
+}
+
+func newPgDB() *pg.DB {
+	conf := config.Get()
+
+	return pg.NewDB(&pg.Config{
+		Address:          conf.Postgres.Address,
+		User:             conf.Postgres.User,
+		Password:         conf.Postgres.Password,
+		DatabaseName:     conf.Postgres.DatabaseName,
+		ReplicaAddresses: conf.Postgres.ReplicaAddresses,
+		SSLMode:          conf.Postgres.SSLMode,
+		SSLRootCert:      conf.Postgres.SSLRootCert,
+		SSLCert:          conf.Postgres.SSLCert,
+		SSLKey:           conf.Postgres.SSLKey,
+		ApplicationName:  conf.App.Name,
+		QueryTimeout:     conf.Postgres.QueryTimeout,
+		MaxOpenConns:     conf.Postgres.MaxOpenConns,
+		MaxIdleConns:     conf.Postgres.MaxIdleConns,
+		ConnMaxLifetime:  conf.Postgres.ConnMaxLifetime,
+		ConnMaxIdleTime:  conf.Postgres.ConnMaxIdleTime,
+		ConnectAttempts:  conf.Postgres.ConnectAttempts,
+		ConnectBackoff:   conf.Postgres.ConnectBackoff,
+		// Schema changes in RELEASE mode only ever go through versioned migrations.
+		IsAutoMigrate: conf.App.Mode != constants.APP_MODE_RELEASE && conf.Postgres.AutoMigrate,
+	})
+}
+
+func newStorage() storage.Storage {
+	conf := config.Get()
+
+	if conf.Storage.Driver == constants.STORAGE_DRIVER_S3 {
+		return storage.NewS3(&storage.S3Config{
+			Endpoint:    conf.Storage.S3Endpoint,
+			Region:      conf.Storage.S3Region,
+			Bucket:      conf.Storage.S3Bucket,
+			AccessKey:   conf.Storage.S3AccessKey,
+			SecretKey:   conf.Storage.S3SecretKey,
+			IsPathStyle: conf.Storage.S3PathStyle,
+			BaseURL:     conf.Storage.PublicURL,
+		})
+	}
+
+	baseURL := conf.Storage.PublicURL
+	if len(baseURL) == 0 {
+		baseURL = uploadsPath
+	}
+	return storage.NewLocal(&storage.LocalConfig{
+		Dir:     conf.Storage.LocalDir,
+		BaseURL: baseURL,
+	})
+}
+
+func initJWT() {
+	conf := config.Get()
+
+	jwt.Init(&jwt.Config{
+		Duration:      &conf.JWT.Duration,
+		PrivateKeyPEM: []byte(conf.JWT.PrivateKey),
+	})
+}
+
+func initArgon2() {
+	conf := config.Get()
+
+	argon2.Init(&argon2.Config{
+		Memory:      conf.Hash.Memory,
+		Iterations:  conf.Hash.Iterations,
+		Parallelism: conf.Hash.Parallelism,
+		SaltLength:  conf.Hash.SaltLength,
+		KeyLength:   conf.Hash.KeyLength,
+	})
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/config"
+	"hilmy.dev/store/src/libs/db/mongo"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/openapi"
+	"hilmy.dev/store/src/libs/storage"
+)
+
+// TestOpenAPIMatchesRoutes fails when a route under /api/ is registered without being documented with openapi.Route, or
+// the other way around. The modules are loaded with connections that are never opened, since only the routes matter.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	for key, value := range map[string]string{
+		"CONFIG_FILE":                "",
+		"APP_NAME":                   "store",
+		"POSTGRES_USER":              "store",
+		"POSTGRES_PASSWORD":          "store",
+		"POSTGRES_DB":                "store",
+		"MONGO_INITDB_ROOT_USERNAME": "store",
+		"MONGO_INITDB_ROOT_PASSWORD": "store",
+		"MONGO_DATABASE_NAME":        "store",
+		"INITIAL_ACCOUNT_NAME":       "Admin",
+		"INITIAL_ACCOUNT_USERNAME":   "admin",
+		"INITIAL_ACCOUNT_PASSWORD":   "admin",
+		"METRICS_ADDRESS":            "",
+		"STORAGE_DRIVER":             "LOCAL",
+		"STORAGE_LOCAL_DIR":          t.TempDir(),
+		"TRASH_RETENTION":            "0",
+	} {
+		t.Setenv(key, value)
+	}
+	config.Load("serve", nil)
+
+	m := module{app: fiber.New()}
+	m.controller()
+	m.loadModules(&dependencies{
+		pgDB:          &pg.DB{},
+		mongoDBClient: &mongo.Client{},
+		storage: storage.NewLocal(&storage.LocalConfig{
+			Dir:     t.TempDir(),
+			BaseURL: uploadsPath,
+		}),
+	})
+
+	if err := openapi.Check(m.app, "/api/", openAPIPath, openAPIUIPath); err != nil {
+		t.Fatal(err)
+	}
+}
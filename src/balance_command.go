@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"hilmy.dev/store/src/libs/db/pg"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	b "hilmy.dev/store/src/modules/balance/balance_entity"
+)
+
+const balanceUsage = "usage: store balance adjust -username <username> -amount <amount>"
+
+func balanceCommand(args []string) {
+	if len(args) == 0 || args[0] != "adjust" {
+		exitWithUsage(balanceUsage)
+	}
+
+	flags := flag.NewFlagSet("balance adjust", flag.ExitOnError)
+	username := flags.String("username", "", "username of the account")
+	amount := flags.Int("amount", 0, "amount to add, negative to subtract")
+	flags.Parse(args[1:])
+
+	if len(*username) == 0 || *amount == 0 {
+		exitWithUsage(balanceUsage)
+	}
+
+	pgDB := newPgDB()
+	acc.InitRepository(pgDB)
+	b.InitRepository(pgDB)
+
+	accountDetailData, err := getAccountByUsername(username)
+	exitOnError(err)
+
+	// The amount is changed in a single statement, so that a purchase or top-up made at the same time is not lost, and
+	// the guard keeps it from going below zero.
+	err = b.BalanceRepository().UpdateExpr(map[string]pg.Where{
+		"amount": {
+			Query: "amount + ?",
+			Args:  []interface{}{*amount},
+		},
+	}, &pg.UpdateOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "user_id = ?",
+				Args:  []interface{}{accountDetailData.ID},
+			},
+			{
+				Query: "amount + ? >= 0",
+				Args:  []interface{}{*amount},
+			},
+		},
+	})
+	if pg.IsErrRecordNotFound(err) {
+		err = balanceNotAdjusted(username, accountDetailData)
+	}
+	exitOnError(err)
+
+	balanceDetailData, err := getBalance(accountDetailData)
+	exitOnError(err)
+
+	logger.Log(fmt.Sprintf("balance of %s adjusted by %d to %d", *username, *amount, *balanceDetailData.Amount))
+	closeResources()
+}
+
+func getBalance(accountDetailData *acc.AccountModel) (*b.BalanceModel, error) {
+	return b.BalanceRepository().FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "user_id = ?",
+				Args:  []interface{}{accountDetailData.ID},
+			},
+		},
+		IsPrimary: true,
+	})
+}
+
+// balanceNotAdjusted tells why the adjustment matched no row, which is either a missing balance or one that would
+// become negative.
+func balanceNotAdjusted(username *string, accountDetailData *acc.AccountModel) error {
+	balanceDetailData, err := getBalance(accountDetailData)
+	if pg.IsErrRecordNotFound(err) {
+		return errors.New("account has no balance: " + *username)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("balance of %s is %d, which is too low to subtract from", *username, *balanceDetailData.Amount)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"hilmy.dev/store/src/libs/env"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+type Config struct {
+	App      AppConfig
+	Metrics  MetricsConfig
+	Tracing  TracingConfig
+	Postgres PostgresConfig
+	Mongo    MongoConfig
+	JWT      JWTConfig
+	Hash     HashConfig
+	Storage  StorageConfig
+	Image    ImageConfig
+	Trash    TrashConfig
+	Initial  InitialAccountConfig
+}
+
+type AppConfig struct {
+	Name            string        `env:"APP_NAME" validate:"required"`
+	Mode            string        `env:"APP_MODE" default:"DEBUG" validate:"oneof=DEBUG RELEASE"`
+	Address         string        `env:"APP_ADDRESS" default:"0.0.0.0:8080" validate:"required"`
+	RequestTimeout  time.Duration `env:"REQUEST_TIMEOUT" default:"15s"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" validate:"gt=0"`
+	// ShutdownDrainDelay is how long readiness fails before the server stops taking requests, and has to leave room
+	// for the rest of the shutdown within ShutdownTimeout.
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" default:"5s" validate:"gte=0,ltfield=ShutdownTimeout"`
+	BodyLimit          int           `env:"BODY_LIMIT" default:"8388608" validate:"gt=0"`
+	WebAddress         string        `env:"WEB_ADDRESS"`
+}
+
+type MetricsConfig struct {
+	Address string `env:"METRICS_ADDRESS"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `env:"TRACING_EXPORTER" validate:"omitempty,oneof=STDOUT OTLP"`
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" default:"1" validate:"gte=0,lte=1"`
+}
+
+type PostgresConfig struct {
+	Address      string `env:"POSTGRES_ADDRESS" default:"localhost:5432" validate:"required"`
+	User         string `env:"POSTGRES_USER" validate:"required"`
+	Password     string `env:"POSTGRES_PASSWORD" validate:"required"`
+	DatabaseName string `env:"POSTGRES_DB" validate:"required"`
+	AutoMigrate  bool   `env:"POSTGRES_AUTO_MIGRATE"`
+
+	ReplicaAddresses []string `env:"POSTGRES_REPLICA_ADDRESSES"`
+
+	SSLMode     string `env:"POSTGRES_SSL_MODE" default:"prefer" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	SSLRootCert string `env:"POSTGRES_SSL_ROOT_CERT"`
+	SSLCert     string `env:"POSTGRES_SSL_CERT"`
+	SSLKey      string `env:"POSTGRES_SSL_KEY"`
+
+	QueryTimeout    time.Duration `env:"POSTGRES_QUERY_TIMEOUT" default:"30s"`
+	MaxOpenConns    int           `env:"POSTGRES_MAX_OPEN_CONNS" default:"25" validate:"gte=0"`
+	MaxIdleConns    int           `env:"POSTGRES_MAX_IDLE_CONNS" default:"5" validate:"gte=0"`
+	ConnMaxLifetime time.Duration `env:"POSTGRES_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"POSTGRES_CONN_MAX_IDLE_TIME" default:"5m"`
+	ConnectAttempts int           `env:"POSTGRES_CONNECT_ATTEMPTS" default:"5" validate:"gt=0"`
+	ConnectBackoff  time.Duration `env:"POSTGRES_CONNECT_BACKOFF" default:"1s"`
+}
+
+type MongoConfig struct {
+	Address      string `env:"MONGO_ADDRESS" default:"localhost:27017" validate:"required"`
+	User         string `env:"MONGO_INITDB_ROOT_USERNAME" validate:"required"`
+	Password     string `env:"MONGO_INITDB_ROOT_PASSWORD" validate:"required"`
+	DatabaseName string `env:"MONGO_DATABASE_NAME" validate:"required"`
+}
+
+type JWTConfig struct {
+	Duration time.Duration `env:"JWT_DURATION" default:"720h" validate:"gt=0"`
+	// PrivateKey is usually given as JWT_PRIVATE_KEY_FILE, a path to the PEM file.
+	PrivateKey string `env:"JWT_PRIVATE_KEY"`
+}
+
+type HashConfig struct {
+	Memory      uint32 `env:"HASH_MEMORY" default:"65536" validate:"gt=0"`
+	Iterations  uint32 `env:"HASH_ITERATIONS" default:"1" validate:"gt=0"`
+	Parallelism uint8  `env:"HASH_PARALLELISM" default:"4" validate:"gt=0"`
+	SaltLength  int    `env:"HASH_SALTLENGTH" default:"16" validate:"gt=0"`
+	KeyLength   uint32 `env:"HASH_KEYLENGTH" default:"32" validate:"gt=0"`
+}
+
+type StorageConfig struct {
+	Driver string `env:"STORAGE_DRIVER" default:"LOCAL" validate:"oneof=LOCAL S3"`
+	// PublicURL is where stored files are downloaded from. It defaults to /uploads, served by the app itself, for the
+	// LOCAL driver and to the bucket URL for S3.
+	PublicURL   string `env:"STORAGE_PUBLIC_URL"`
+	LocalDir    string `env:"STORAGE_LOCAL_DIR" default:"uploads" validate:"required_if=Driver LOCAL"`
+	S3Endpoint  string `env:"STORAGE_S3_ENDPOINT" validate:"required_if=Driver S3"`
+	S3Region    string `env:"STORAGE_S3_REGION" default:"us-east-1" validate:"required_if=Driver S3"`
+	S3Bucket    string `env:"STORAGE_S3_BUCKET" validate:"required_if=Driver S3"`
+	S3AccessKey string `env:"STORAGE_S3_ACCESS_KEY" validate:"required_if=Driver S3"`
+	S3SecretKey string `env:"STORAGE_S3_SECRET_KEY" validate:"required_if=Driver S3"`
+	S3PathStyle bool   `env:"STORAGE_S3_PATH_STYLE"`
+}
+
+type ImageConfig struct {
+	MaxSize  int `env:"IMAGE_MAX_SIZE" default:"5242880" validate:"gt=0"`
+	MaxCount int `env:"IMAGE_MAX_COUNT" default:"10" validate:"gt=0"`
+	// ReviewMaxCount is the number of images a product review may have.
+	ReviewMaxCount int `env:"IMAGE_REVIEW_MAX_COUNT" default:"5" validate:"gt=0"`
+}
+
+type TrashConfig struct {
+	// Retention is how long soft-deleted products, categories and accounts can be restored before they are purged for
+	// good. 0 keeps them forever.
+	Retention     time.Duration `env:"TRASH_RETENTION" default:"720h" validate:"gte=0"`
+	PurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL" default:"24h" validate:"gt=0"`
+}
+
+type InitialAccountConfig struct {
+	Name     string `env:"INITIAL_ACCOUNT_NAME" validate:"required"`
+	Username string `env:"INITIAL_ACCOUNT_USERNAME" validate:"required"`
+	Password string `env:"INITIAL_ACCOUNT_PASSWORD" validate:"required"`
+	Role     string `env:"INITIAL_ACCOUNT_ROLE" default:"ADMIN" validate:"oneof=ADMIN USER"`
+}
+
+// commandSections are the sections of Config that the commands other than serve use, which are the only ones they
+// need to be valid. serve checks every section.
+var commandSections = map[string][]string{
+	"migrate": {"App", "Postgres"},
+	"user":    {"App", "Postgres", "Hash"},
+	"balance": {"App", "Postgres"},
+	"seed":    {"App", "Postgres"},
+}
+
+var config *Config
+var logger = applogger.New("Config")
+
+// Load reads the configuration once and exits with a report of every invalid setting in the sections that the command
+// name uses. args are command line flags:
+// -config (or CONFIG_FILE) points to a YAML or TOML file, and every variable can be overridden by its kebab-case name,
+// e.g. -app-address for APP_ADDRESS.
+func Load(name string, args []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+
+	flagKeys := map[string]string{}
+	for _, key := range env.Keys(new(Config)) {
+		flagName := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		flagKeys[flagName] = key
+		flags.String(flagName, "", "overrides "+key)
+	}
+	flags.Parse(args)
+
+	overrides := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			overrides[key] = f.Value.String()
+		}
+	})
+
+	config = new(Config)
+	if err := env.Load(config, &env.LoadOptions{
+		File:      *configFile,
+		Overrides: overrides,
+		Sections:  commandSections[name],
+	}); err != nil {
+		logger.Error(err, &applogger.Options{IsPrintStack: false, IsExit: true})
+	}
+}
+
+func Get() *Config {
+	if config == nil {
+		logger.Panic("config is not loaded")
+	}
+
+	return config
+}
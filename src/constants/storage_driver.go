@@ -0,0 +1,6 @@
+package constants
+
+const (
+	STORAGE_DRIVER_LOCAL = "LOCAL"
+	STORAGE_DRIVER_S3    = "S3"
+)
@@ -1,14 +1,21 @@
 package contracts
 
+import "hilmy.dev/store/src/libs/validator"
+
 type Response struct {
 	Error      *Error      `json:"error,omitempty"`
 	Pagination *Pagination `json:"pagination,omitempty"`
 	Data       interface{} `json:"data,omitempty"`
+	// Facets summarizes the whole filtered list, such as the number of matches per category, when asked for.
+	Facets interface{} `json:"facets,omitempty"`
 }
 
 type Error struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status string `json:"status"`
+	// Code is stable across releases and languages, unlike Message, so clients should branch on it.
+	Code    string                 `json:"code,omitempty"`
+	Message string                 `json:"message"`
+	Fields  []validator.FieldError `json:"fields,omitempty"`
 }
 
 type Pagination struct {
@@ -16,4 +23,20 @@ type Pagination struct {
 	Count *int `json:"count,omitempty"`
 	Page  *int `json:"page,omitempty"`
 	Total *int `json:"total,omitempty"`
+	// Next and Prev are opaque cursors; pass one back as ?cursor= to fetch the neighbouring page.
+	Next *string `json:"next,omitempty"`
+	Prev *string `json:"prev,omitempty"`
 }
+
+const (
+	ERROR_CODE_BAD_REQUEST        = "BAD_REQUEST"
+	ERROR_CODE_MALFORMED_REQUEST  = "MALFORMED_REQUEST"
+	ERROR_CODE_VALIDATION_FAILED  = "VALIDATION_FAILED"
+	ERROR_CODE_UNAUTHORIZED       = "UNAUTHORIZED"
+	ERROR_CODE_FORBIDDEN          = "FORBIDDEN"
+	ERROR_CODE_NOT_FOUND          = "NOT_FOUND"
+	ERROR_CODE_CONFLICT           = "CONFLICT"
+	ERROR_CODE_INSUFFICIENT_FUNDS = "INSUFFICIENT_FUNDS"
+	ERROR_CODE_INVALID_STATE      = "INVALID_STATE"
+	ERROR_CODE_INTERNAL           = "INTERNAL_ERROR"
+)
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"hilmy.dev/store/src/libs/jwx/jwt"
+)
+
+const jwtUsage = "usage: store jwt keygen [-bits <bits>] [-out <file>]"
+
+func jwtCommand(args []string) {
+	if len(args) == 0 || args[0] != "keygen" {
+		exitWithUsage(jwtUsage)
+	}
+
+	flags := flag.NewFlagSet("jwt keygen", flag.ExitOnError)
+	bits := flags.Int("bits", 2048, "RSA key size in bits")
+	out := flags.String("out", "", "file to write the PEM encoded key to, stdout when empty")
+	flags.Parse(args[1:])
+
+	privateKeyPEM, err := jwt.GeneratePrivateKeyPEM(*bits)
+	exitOnError(err)
+
+	if len(*out) == 0 {
+		_, err := os.Stdout.Write(privateKeyPEM)
+		exitOnError(err)
+		return
+	}
+
+	exitOnError(os.WriteFile(*out, privateKeyPEM, 0600))
+	logger.Log("wrote private key to " + *out + ", set JWT_PRIVATE_KEY_FILE to use it")
+}
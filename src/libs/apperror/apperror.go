@@ -0,0 +1,97 @@
+package apperror
+
+import (
+	"errors"
+
+	"hilmy.dev/store/src/libs/db/mongo"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+type Kind int
+
+const (
+	KIND_INTERNAL Kind = iota
+	KIND_MALFORMED
+	KIND_VALIDATION
+	KIND_UNAUTHORIZED
+	KIND_FORBIDDEN
+	KIND_NOT_FOUND
+	KIND_CONFLICT
+	KIND_INSUFFICIENT_FUNDS
+	KIND_INVALID_STATE
+)
+
+// Error is a failure that services and controllers return to the ErrorHandler, which picks the status and error code
+// from Kind. Message is shown to clients, while Err keeps the underlying cause for logs only.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func NotFound(message string) *Error {
+	return New(KIND_NOT_FOUND, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KIND_CONFLICT, message)
+}
+
+func Validation(message string) *Error {
+	return New(KIND_VALIDATION, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(KIND_UNAUTHORIZED, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KIND_FORBIDDEN, message)
+}
+
+func InsufficientFunds(message string) *Error {
+	return New(KIND_INSUFFICIENT_FUNDS, message)
+}
+
+func InvalidState(message string) *Error {
+	return New(KIND_INVALID_STATE, message)
+}
+
+// Malformed reports a request that could not be decoded at all. The decoder's message is useful to clients, so it
+// becomes the message.
+func Malformed(err error) *Error {
+	return New(KIND_MALFORMED, err.Error())
+}
+
+func Is(err error, kind Kind) bool {
+	var appError *Error
+	return errors.As(err, &appError) && appError.Kind == kind
+}
+
+// NotFoundOr reports a missing pg record or mongo document as NotFound with message, keeping the driver error as the
+// cause, and returns any other error as it is.
+func NotFoundOr(err error, message string) error {
+	if pg.IsErrRecordNotFound(err) || mongo.IsErrNoDocuments(err) {
+		return Wrap(KIND_NOT_FOUND, message, err)
+	}
+	return err
+}
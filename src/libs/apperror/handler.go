@@ -0,0 +1,190 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/contracts"
+	"hilmy.dev/store/src/libs/db/cursor"
+	"hilmy.dev/store/src/libs/db/mongo"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/validator"
+)
+
+type Config struct {
+	// IsHideInternal replaces the message of 5xx errors with a generic one, so that driver and query details stay in
+	// the logs.
+	IsHideInternal bool
+	// Log records every error the handler renders, with a stack trace for 5xx errors.
+	Log func(ctx context.Context, location string, message string, isPrintStack bool) error
+	// LogTimeout bounds Log. The handler only runs once every middleware has returned, when the deadline of the request
+	// has already been cancelled, so Log gets a context that keeps the trace of the request but not its deadline.
+	LogTimeout time.Duration
+}
+
+var validationFailedMessages = map[string]string{
+	"en": "request validation failed",
+	"id": "validasi permintaan gagal",
+}
+
+var internalErrorMessages = map[string]string{
+	"en": "unexpected error occurred",
+	"id": "terjadi kesalahan yang tidak terduga",
+}
+
+var uniqueViolationMessages = map[string]string{
+	"en": "%s is already taken",
+	"id": "%s sudah digunakan",
+}
+
+var foreignKeyViolationMessages = map[string]string{
+	"en": "%s conflicts with related data",
+	"id": "%s bertentangan dengan data terkait",
+}
+
+var kindStatuses = map[Kind]int{
+	KIND_INTERNAL:           fiber.StatusInternalServerError,
+	KIND_MALFORMED:          fiber.StatusBadRequest,
+	KIND_VALIDATION:         fiber.StatusBadRequest,
+	KIND_UNAUTHORIZED:       fiber.StatusUnauthorized,
+	KIND_FORBIDDEN:          fiber.StatusForbidden,
+	KIND_NOT_FOUND:          fiber.StatusNotFound,
+	KIND_CONFLICT:           fiber.StatusConflict,
+	KIND_INSUFFICIENT_FUNDS: fiber.StatusUnprocessableEntity,
+	KIND_INVALID_STATE:      fiber.StatusConflict,
+}
+
+var kindCodes = map[Kind]string{
+	KIND_INTERNAL:           contracts.ERROR_CODE_INTERNAL,
+	KIND_MALFORMED:          contracts.ERROR_CODE_MALFORMED_REQUEST,
+	KIND_VALIDATION:         contracts.ERROR_CODE_VALIDATION_FAILED,
+	KIND_UNAUTHORIZED:       contracts.ERROR_CODE_UNAUTHORIZED,
+	KIND_FORBIDDEN:          contracts.ERROR_CODE_FORBIDDEN,
+	KIND_NOT_FOUND:          contracts.ERROR_CODE_NOT_FOUND,
+	KIND_CONFLICT:           contracts.ERROR_CODE_CONFLICT,
+	KIND_INSUFFICIENT_FUNDS: contracts.ERROR_CODE_INSUFFICIENT_FUNDS,
+	KIND_INVALID_STATE:      contracts.ERROR_CODE_INVALID_STATE,
+}
+
+// Handler is the single place where errors returned by handlers are logged and turned into responses.
+func Handler(config *Config) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		if err == nil {
+			err = errors.New("nil error")
+		}
+
+		status, resError := toResponse(validator.Locale(c.Get(fiber.HeaderAcceptLanguage)), err)
+		if status >= fiber.StatusInternalServerError && config.IsHideInternal {
+			resError.Message = internalErrorMessages[validator.Locale(c.Get(fiber.HeaderAcceptLanguage))]
+		}
+
+		if config.Log != nil {
+			ctx := context.WithoutCancel(c.UserContext())
+			if config.LogTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, config.LogTimeout)
+				defer cancel()
+			}
+			config.Log(ctx, c.OriginalURL(), err.Error(), status >= fiber.StatusInternalServerError)
+		}
+
+		return c.Status(status).JSON(&contracts.Response{
+			Error: resError,
+		})
+	}
+}
+
+// Status returns the status Handler responds to err with, for middlewares that run before it, such as the ones
+// recording metrics and traces.
+func Status(err error) int {
+	status, _ := toResponse(validator.DefaultLocale, err)
+	return status
+}
+
+func toResponse(locale string, err error) (int, *contracts.Error) {
+	if fieldErrors := validator.FieldErrors(err, locale); fieldErrors != nil {
+		return fiber.StatusBadRequest, &contracts.Error{
+			Status:  fiber.ErrBadRequest.Error(),
+			Code:    contracts.ERROR_CODE_VALIDATION_FAILED,
+			Message: validationFailedMessages[locale],
+			Fields:  fieldErrors,
+		}
+	}
+
+	var appError *Error
+	if errors.As(err, &appError) {
+		return newResponse(kindStatuses[appError.Kind], kindCodes[appError.Kind], appError.Message)
+	}
+
+	var constraintError *pg.ConstraintError
+	if errors.As(err, &constraintError) {
+		if constraintError.Kind == pg.CONSTRAINT_UNIQUE {
+			return newConflictResponse(constraintError.Field, "unique", uniqueViolationMessages[locale])
+		}
+		return newConflictResponse(constraintError.Field, "exists", foreignKeyViolationMessages[locale])
+	}
+
+	var duplicateKeyError *mongo.DuplicateKeyError
+	if errors.As(err, &duplicateKeyError) {
+		return newConflictResponse(duplicateKeyError.Field, "unique", uniqueViolationMessages[locale])
+	}
+
+	if errors.Is(err, cursor.ErrInvalid) {
+		return newResponse(fiber.StatusBadRequest, contracts.ERROR_CODE_MALFORMED_REQUEST, err.Error())
+	}
+
+	if pg.IsErrRecordNotFound(err) || mongo.IsErrNoDocuments(err) {
+		return newResponse(fiber.StatusNotFound, contracts.ERROR_CODE_NOT_FOUND, "resource not found")
+	}
+
+	var fiberError *fiber.Error
+	if errors.As(err, &fiberError) {
+		return newResponse(fiberError.Code, statusCode(fiberError.Code), fiberError.Message)
+	}
+
+	return newResponse(fiber.StatusInternalServerError, contracts.ERROR_CODE_INTERNAL, err.Error())
+}
+
+func newResponse(status int, code string, message string) (int, *contracts.Error) {
+	return status, &contracts.Error{
+		Status:  fiber.NewError(status).Error(),
+		Code:    code,
+		Message: message,
+	}
+}
+
+// newConflictResponse reports a constraint violation in the same shape as a failed validation rule, so clients can
+// point at the offending field.
+func newConflictResponse(field string, rule string, message string) (int, *contracts.Error) {
+	message = fmt.Sprintf(message, field)
+	status, resError := newResponse(fiber.StatusConflict, contracts.ERROR_CODE_CONFLICT, message)
+	resError.Fields = []validator.FieldError{
+		{
+			Field:   field,
+			Rule:    rule,
+			Message: message,
+		},
+	}
+	return status, resError
+}
+
+// statusCode maps the status of errors raised by fiber itself, such as unknown routes, to an error code.
+func statusCode(status int) string {
+	switch {
+	case status == fiber.StatusUnauthorized:
+		return contracts.ERROR_CODE_UNAUTHORIZED
+	case status == fiber.StatusForbidden:
+		return contracts.ERROR_CODE_FORBIDDEN
+	case status == fiber.StatusNotFound:
+		return contracts.ERROR_CODE_NOT_FOUND
+	case status == fiber.StatusConflict:
+		return contracts.ERROR_CODE_CONFLICT
+	case status >= fiber.StatusInternalServerError:
+		return contracts.ERROR_CODE_INTERNAL
+	default:
+		return contracts.ERROR_CODE_BAD_REQUEST
+	}
+}
@@ -0,0 +1,64 @@
+package apperror
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"hilmy.dev/store/src/libs/deadline"
+	"hilmy.dev/store/src/libs/tracing"
+)
+
+func TestHandlerLogsWithTheRequestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var logSpanContext trace.SpanContext
+	var logErr error
+	isLogged := false
+	app := fiber.New(fiber.Config{
+		ErrorHandler: Handler(&Config{
+			Log: func(ctx context.Context, location string, message string, isPrintStack bool) error {
+				isLogged = true
+				logSpanContext = trace.SpanContextFromContext(ctx)
+				logErr = ctx.Err()
+				return nil
+			},
+			LogTimeout: time.Minute,
+		}),
+	})
+	app.Use(tracing.Middleware(Status))
+	app.Use(deadline.Middleware(time.Minute))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return NotFound("product not found")
+	})
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusNotFound {
+		t.Errorf("status = %d, want %d", res.StatusCode, fiber.StatusNotFound)
+	}
+
+	if !isLogged {
+		t.Fatal("the error was not logged")
+	}
+	// The deadline of the request is cancelled by the time the handler runs, which must not cancel the log.
+	if logErr != nil {
+		t.Errorf("the log context is done: %v", logErr)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want the HTTP span", len(spans))
+	}
+	if logSpanContext.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Errorf("the log is in span %v, want the HTTP span %v", logSpanContext.SpanID(), spans[0].SpanContext().SpanID())
+	}
+}
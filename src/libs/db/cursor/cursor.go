@@ -0,0 +1,46 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor points at the row a keyset page starts after. It is handed to clients as an opaque token.
+type Cursor struct {
+	// Key and IsDesc are the order the cursor was created for, so that a token cannot be replayed against another one.
+	Key        string `json:"k"`
+	IsDesc     bool   `json:"d,omitempty"`
+	Value      string `json:"v,omitempty"`
+	ID         string `json:"i"`
+	IsBackward bool   `json:"b,omitempty"`
+}
+
+var ErrInvalid = errors.New("invalid cursor")
+
+func Encode(c *Cursor) *string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(data)
+	return &token
+}
+
+func Decode(token string, key string, isDesc bool) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	c := new(Cursor)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, ErrInvalid
+	}
+	if c.Key != key || c.IsDesc != isDesc || len(c.ID) == 0 {
+		return nil, ErrInvalid
+	}
+
+	return c, nil
+}
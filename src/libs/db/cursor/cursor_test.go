@@ -0,0 +1,39 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	c := &Cursor{Key: "price", IsDesc: true, Value: "1500", ID: "6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11", IsBackward: true}
+
+	token := Encode(c)
+	decoded, err := Decode(*token, "price", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *c {
+		t.Errorf("decoded %+v, want %+v", *decoded, *c)
+	}
+}
+
+func TestDecodeRejectsInvalidTokens(t *testing.T) {
+	encode := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+
+	tests := map[string]string{
+		"not base64":      "%%%",
+		"not json":        encode("price"),
+		"other key":       *Encode(&Cursor{Key: "title", Value: "a", ID: "1"}),
+		"other direction": *Encode(&Cursor{Key: "price", IsDesc: true, Value: "1500", ID: "1"}),
+		"missing id":      encode(`{"k":"price","v":"1500"}`),
+	}
+	for name, token := range tests {
+		if _, err := Decode(token, "price", false); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrInvalid)
+		}
+	}
+}
@@ -34,11 +34,8 @@ func NewClient(config *Config) *Client {
 
 	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
 		FnDescription: "disconnect mongodb client",
-		Fn: func() {
-			if err := client.Disconnect(context.TODO()); err != nil {
-				logger.Error(err)
-			}
-		},
+		Phase:         gracefulshutdown.PHASE_CLOSE_DB,
+		Fn:            client.Disconnect,
 	})
 
 	return client
@@ -23,12 +23,21 @@ type FindOneOptions struct {
 	Order *[]Order
 }
 
+// Keyset orders FindAll by _id, which grows with insertion time, and makes it return cursors instead of relying on skip.
+type Keyset struct {
+	IsDesc bool
+}
+
 type FindAllOptions struct {
-	Where   *[]FindAllWhere
-	Order   *[]Order
-	Limit   *int
-	Offset  *int
-	AfterID *primitive.ObjectID
+	Where  *[]FindAllWhere
+	Order  *[]Order
+	Limit  *int
+	Offset *int
+	// Keyset replaces Order, and lets the page start after Cursor or AfterID instead of at Offset.
+	Keyset      *Keyset
+	Cursor      *string
+	AfterID     *primitive.ObjectID
+	IsSkipCount bool
 }
 
 type UpdateOptions struct {
@@ -42,5 +51,8 @@ type DestroyOptions struct {
 type Pagination struct {
 	Limit int
 	Count int
-	Total int
+	// Total is nil when the count was skipped.
+	Total *int
+	Next  *string
+	Prev  *string
 }
@@ -0,0 +1,42 @@
+package mongo
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The server only reports the index and key in the message, e.g.
+// `E11000 duplicate key error collection: store.log index: username_1 dup key: { username: "hilmy" }`.
+var duplicateKeyRegexp = regexp.MustCompile(`index: (\S+) dup key: \{ ?"?([^:"]+)"?:`)
+
+// DuplicateKeyError is a write rejected by a unique index, which is caused by the data sent by the client rather
+// than by the server.
+type DuplicateKeyError struct {
+	Index string
+	// Field is the first field of the offending index.
+	Field string
+	Err   error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// asDuplicateKeyError returns err as a *DuplicateKeyError when it is a duplicate key error, and unchanged otherwise.
+func asDuplicateKeyError(err error) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	duplicateKeyError := &DuplicateKeyError{Err: err}
+	if matches := duplicateKeyRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		duplicateKeyError.Index = matches[1]
+		duplicateKeyError.Field = matches[2]
+	}
+	return duplicateKeyError
+}
@@ -2,12 +2,14 @@ package mongo
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"hilmy.dev/store/src/libs/db/cursor"
 	"hilmy.dev/store/src/libs/validator"
 )
 
@@ -18,6 +20,7 @@ type ModelI interface {
 
 type Service[T ModelI] struct {
 	Client *Client
+	ctx    context.Context
 }
 
 type Options struct {
@@ -63,13 +66,30 @@ func NewService[T ModelI](client *Client, moptions ...*Options) *Service[T] {
 	return &Service[T]{Client: client}
 }
 
+// WithContext returns a copy of the service whose operations are bound to ctx, so that they are cancelled together
+// with the request and traced under its span.
+func (s *Service[T]) WithContext(ctx context.Context) *Service[T] {
+	return &Service[T]{Client: s.Client, ctx: ctx}
+}
+
+func (s *Service[T]) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 func (s *Service[T]) Count(countOptions *CountOptions) (*int64, error) {
+	ctx, span := startSpan[T](s.context(), "count")
+	defer span.End()
+
 	model := new(T)
 
 	coll := s.Client.Database((*model).DatabaseName()).Collection((*model).CollectionName())
 
-	count, err := coll.CountDocuments(context.TODO(), countOptions.Where)
+	count, err := coll.CountDocuments(ctx, countOptions.Where)
 	if err != nil {
+		recordSpanError(span, err)
 		logger.Error(err)
 		return nil, err
 	}
@@ -78,6 +98,9 @@ func (s *Service[T]) Count(countOptions *CountOptions) (*int64, error) {
 }
 
 func (s *Service[T]) FindOne(findOptions *FindOneOptions) (*T, error) {
+	ctx, span := startSpan[T](s.context(), "findOne")
+	defer span.End()
+
 	model := new(T)
 
 	docStruct := new(T)
@@ -85,7 +108,8 @@ func (s *Service[T]) FindOne(findOptions *FindOneOptions) (*T, error) {
 	coll := s.Client.Database((*model).DatabaseName()).Collection((*model).CollectionName())
 
 	docMap := make(map[string]interface{}, 0)
-	if err := coll.FindOne(context.TODO(), findOptions.Where).Decode(&docMap); err != nil {
+	if err := coll.FindOne(ctx, findOptions.Where).Decode(&docMap); err != nil {
+		recordSpanError(span, err)
 		if !IsErrNoDocuments(err) {
 			logger.Error(err)
 		}
@@ -99,6 +123,9 @@ func (s *Service[T]) FindOne(findOptions *FindOneOptions) (*T, error) {
 }
 
 func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, error) {
+	ctx, span := startSpan[T](s.context(), "findAll")
+	defer span.End()
+
 	model := new(T)
 
 	docStruct := []*T{}
@@ -106,14 +133,13 @@ func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, e
 	coll := s.Client.Database((*model).DatabaseName()).Collection((*model).CollectionName())
 	optsFind := options.Find()
 
+	limit := int64(FindAllDefaultLimit)
 	if findOptions.Limit != nil && *findOptions.Limit > 0 {
 		if *findOptions.Limit < FindAllMaximumLimit {
-			optsFind = optsFind.SetLimit(int64(*findOptions.Limit))
+			limit = int64(*findOptions.Limit)
 		} else {
-			optsFind = optsFind.SetLimit(FindAllMaximumLimit)
+			limit = FindAllMaximumLimit
 		}
-	} else {
-		optsFind = optsFind.SetLimit(FindAllDefaultLimit)
 	}
 
 	where := []Where{}
@@ -125,16 +151,16 @@ func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, e
 		}
 	}
 
-	total, err := coll.CountDocuments(context.TODO(), where)
-	if err != nil {
-		logger.Error(err)
-		return nil, nil, err
-	}
-
-	if findOptions.Offset != nil && *findOptions.Offset > 0 {
-		optsFind = optsFind.SetSkip(int64(*findOptions.Offset))
-	} else {
-		optsFind = optsFind.SetSkip(0)
+	var total *int
+	if !findOptions.IsSkipCount {
+		count, err := coll.CountDocuments(ctx, where)
+		if err != nil {
+			recordSpanError(span, err)
+			logger.Error(err)
+			return nil, nil, err
+		}
+		total = new(int)
+		*total = int(count)
 	}
 
 	if findOptions.Where != nil {
@@ -145,45 +171,139 @@ func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, e
 		}
 	}
 
-	if findOptions.Order != nil {
-		order := bson.D{}
-		for i := range *findOptions.Order {
-			order = append(order, bson.E{
-				Key: (*findOptions.Order)[i].Key, Value: (*findOptions.Order)[i].Value,
-			})
+	var after *cursor.Cursor
+	afterID := findOptions.AfterID
+	isFromStart := true
+	if findOptions.Keyset != nil {
+		if findOptions.Cursor != nil && len(*findOptions.Cursor) > 0 {
+			var err error
+			after, err = cursor.Decode(*findOptions.Cursor, "_id", findOptions.Keyset.IsDesc)
+			if err != nil {
+				return nil, nil, err
+			}
+			id, err := primitive.ObjectIDFromHex(after.ID)
+			if err != nil {
+				return nil, nil, cursor.ErrInvalid
+			}
+			afterID = &id
+		}
+
+		// A prev cursor scans towards the start of the list; the documents are reversed back afterwards.
+		isDesc := findOptions.Keyset.IsDesc != (after != nil && after.IsBackward)
+		direction := 1
+		operator := "$gt"
+		if isDesc {
+			direction = -1
+			operator = "$lt"
+		}
+		if afterID != nil {
+			isFromStart = false
+			where = append(where, Where{Key: "_id", Value: bson.M{operator: afterID}})
+		}
+		optsFind = optsFind.SetSort(bson.D{{Key: "_id", Value: direction}})
+		// One extra document tells whether there is another page after this one.
+		optsFind = optsFind.SetLimit(limit + 1)
+	} else {
+		if findOptions.Order != nil {
+			order := bson.D{}
+			for i := range *findOptions.Order {
+				order = append(order, bson.E{
+					Key: (*findOptions.Order)[i].Key, Value: (*findOptions.Order)[i].Value,
+				})
+			}
+			optsFind = optsFind.SetSort(&order)
 		}
-		optsFind = optsFind.SetSort(&order)
+		optsFind = optsFind.SetLimit(limit)
 	}
 
-	cursor, err := coll.Find(context.TODO(), where, optsFind)
+	if afterID == nil && findOptions.Offset != nil && *findOptions.Offset > 0 {
+		isFromStart = false
+		optsFind = optsFind.SetSkip(int64(*findOptions.Offset))
+	} else {
+		optsFind = optsFind.SetSkip(0)
+	}
+
+	cursorFind, err := coll.Find(ctx, where, optsFind)
 	if err != nil {
+		recordSpanError(span, err)
 		logger.Error(err)
 		return nil, nil, err
 	}
 
 	docMapList := []map[string]interface{}{}
-	for cursor.Next(context.TODO()) {
+	for cursorFind.Next(ctx) {
 		docMap := make(map[string]interface{}, 0)
-		if err := cursor.Decode(&docMap); err != nil {
+		if err := cursorFind.Decode(&docMap); err != nil {
+			recordSpanError(span, err)
 			logger.Error(err)
 			return nil, nil, err
 		}
 		docMapList = append(docMapList, docMap)
 	}
+
+	pagination := &Pagination{
+		Limit: int(limit),
+		Total: total,
+	}
+
+	if findOptions.Keyset != nil {
+		hasMore := int64(len(docMapList)) > limit
+		if hasMore {
+			docMapList = docMapList[:limit]
+		}
+		isBackward := after != nil && after.IsBackward
+		if isBackward {
+			slices.Reverse(docMapList)
+		}
+
+		if len(docMapList) > 0 {
+			first := idCursor(docMapList[0], findOptions.Keyset.IsDesc, true)
+			last := idCursor(docMapList[len(docMapList)-1], findOptions.Keyset.IsDesc, false)
+			if isBackward {
+				pagination.Next = last
+				if hasMore {
+					pagination.Prev = first
+				}
+			} else {
+				if !isFromStart {
+					pagination.Prev = first
+				}
+				if hasMore {
+					pagination.Next = last
+				}
+			}
+		}
+	}
+
 	if len(docMapList) > 0 {
 		if err := transformInterfaceToStruct(&docMapList, &docStruct); err != nil {
 			return nil, nil, err
 		}
 	}
 
-	return &docStruct, &Pagination{
-		Limit: int(*optsFind.Limit),
-		Count: len(docStruct),
-		Total: int(total),
-	}, nil
+	pagination.Count = len(docStruct)
+
+	return &docStruct, pagination, nil
+}
+
+func idCursor(docMap map[string]interface{}, isDesc bool, isBackward bool) *string {
+	id, ok := docMap["_id"].(primitive.ObjectID)
+	if !ok {
+		return nil
+	}
+
+	return cursor.Encode(&cursor.Cursor{
+		Key:        "_id",
+		IsDesc:     isDesc,
+		ID:         id.Hex(),
+		IsBackward: isBackward,
+	})
 }
 
 func (s *Service[T]) Create(data *T) (*primitive.ObjectID, error) {
+	ctx, span := startSpan[T](s.context(), "insertOne")
+	defer span.End()
+
 	if err := validator.Struct(data); err != nil {
 		logger.Error(err)
 		return nil, err
@@ -198,10 +318,11 @@ func (s *Service[T]) Create(data *T) (*primitive.ObjectID, error) {
 		return nil, err
 	}
 
-	result, err := coll.InsertOne(context.TODO(), docMap)
+	result, err := coll.InsertOne(ctx, docMap)
 	if err != nil {
+		recordSpanError(span, err)
 		logger.Error(err)
-		return nil, err
+		return nil, asDuplicateKeyError(err)
 	}
 
 	id := result.InsertedID.(primitive.ObjectID)
@@ -210,6 +331,9 @@ func (s *Service[T]) Create(data *T) (*primitive.ObjectID, error) {
 }
 
 func (s *Service[T]) Update(data *T, updateOptions *UpdateOptions) error {
+	ctx, span := startSpan[T](s.context(), "updateOne")
+	defer span.End()
+
 	if err := validator.Struct(data); err != nil {
 		logger.Error(err)
 		return err
@@ -224,10 +348,11 @@ func (s *Service[T]) Update(data *T, updateOptions *UpdateOptions) error {
 		return err
 	}
 
-	result, err := coll.UpdateOne(context.TODO(), updateOptions.Where, bson.D{{Key: "$set", Value: docMap}})
+	result, err := coll.UpdateOne(ctx, updateOptions.Where, bson.D{{Key: "$set", Value: docMap}})
 	if err != nil {
+		recordSpanError(span, err)
 		logger.Error(err)
-		return err
+		return asDuplicateKeyError(err)
 	}
 	if result == nil || result.ModifiedCount == 0 {
 		return mongo.ErrNoDocuments
@@ -237,12 +362,16 @@ func (s *Service[T]) Update(data *T, updateOptions *UpdateOptions) error {
 }
 
 func (s *Service[T]) Destroy(destroyOptions *DestroyOptions) error {
+	ctx, span := startSpan[T](s.context(), "deleteOne")
+	defer span.End()
+
 	model := new(T)
 
 	coll := s.Client.Database((*model).DatabaseName()).Collection((*model).CollectionName())
 
-	result, err := coll.DeleteOne(context.TODO(), destroyOptions.Where)
+	result, err := coll.DeleteOne(ctx, destroyOptions.Where)
 	if err != nil {
+		recordSpanError(span, err)
 		logger.Error(err)
 		return err
 	}
@@ -0,0 +1,32 @@
+package mongo
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"hilmy.dev/store/src/libs/tracing"
+)
+
+func startSpan[T ModelI](ctx context.Context, operation string) (context.Context, trace.Span) {
+	model := new(T)
+
+	return tracing.Start(ctx, "mongo."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMongoDB,
+			semconv.DBName((*model).DatabaseName()),
+			semconv.DBMongoDBCollection((*model).CollectionName()),
+			semconv.DBOperation(operation),
+		),
+	)
+}
+
+func recordSpanError(span trace.Span, err error) {
+	if err == nil || IsErrNoDocuments(err) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"hilmy.dev/store/src/libs/deadline"
+	"hilmy.dev/store/src/libs/tracing"
+)
+
+type tracingTestModel struct{}
+
+func (tracingTestModel) DatabaseName() string {
+	return "store"
+}
+
+func (tracingTestModel) CollectionName() string {
+	return "logs"
+}
+
+func TestSpansJoinTheRequestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	app := fiber.New()
+	app.Use(tracing.Middleware(func(err error) int {
+		return fiber.StatusInternalServerError
+	}))
+	app.Use(deadline.Middleware(time.Minute))
+	app.Get("/", func(c *fiber.Ctx) error {
+		repo := (&Service[tracingTestModel]{}).WithContext(c.UserContext())
+		_, span := startSpan[tracingTestModel](repo.context(), "insertOne")
+		span.End()
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var httpSpan, mongoSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			httpSpan = span
+		case trace.SpanKindClient:
+			mongoSpan = span
+		}
+	}
+	if httpSpan == nil || mongoSpan == nil {
+		t.Fatalf("got spans %v, want an HTTP and a Mongo span", recorder.Ended())
+	}
+	if mongoSpan.Name() != "mongo.insertOne" {
+		t.Errorf("name = %q, want mongo.insertOne", mongoSpan.Name())
+	}
+	if mongoSpan.Parent().SpanID() != httpSpan.SpanContext().SpanID() {
+		t.Errorf("parent = %v, want the HTTP span %v", mongoSpan.Parent().SpanID(), httpSpan.SpanContext().SpanID())
+	}
+}
@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"errors"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -8,7 +9,7 @@ import (
 )
 
 func IsErrNoDocuments(err error) bool {
-	return err == mongo.ErrNoDocuments
+	return errors.Is(err, mongo.ErrNoDocuments)
 }
 
 func transformStructToMap[T any](data *T, target *map[string]interface{}) error {
@@ -1,10 +1,20 @@
 package pg
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 	"hilmy.dev/store/src/libs/gracefulshutdown"
 	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/metrics"
 	"hilmy.dev/store/src/libs/validator"
 )
 
@@ -15,9 +25,33 @@ type Config struct {
 	User         string `validate:"required"`
 	Password     string `validate:"required"`
 	DatabaseName string `validate:"required"`
+	// ReplicaAddresses are read replicas sharing the credentials of the primary. FindOne and FindAll outside
+	// transactions are spread across them unless IsPrimary is set; everything else goes to the primary.
+	ReplicaAddresses []string
+	SSLMode          string `validate:"omitempty,oneof=disable allow prefer require verify-ca verify-full"`
+	SSLRootCert      string
+	SSLCert          string
+	SSLKey           string
+	ApplicationName  string
+	// QueryTimeout is enforced by the server as statement_timeout. Zero means no limit.
+	QueryTimeout    time.Duration `validate:"gte=0"`
+	MaxOpenConns    int           `validate:"gte=0"`
+	MaxIdleConns    int           `validate:"gte=0"`
+	ConnMaxLifetime time.Duration `validate:"gte=0"`
+	ConnMaxIdleTime time.Duration `validate:"gte=0"`
+	// ConnectAttempts is how many times the first connection is tried before giving up, doubling ConnectBackoff
+	// after each failure so that the app can start alongside the database.
+	ConnectAttempts int           `validate:"gte=0"`
+	ConnectBackoff  time.Duration `validate:"gte=0"`
+	// IsAutoMigrate lets gorm AutoMigrate every model in NewService. Meant for local development only;
+	// schema changes otherwise go through the versioned migrations.
+	IsAutoMigrate bool
 }
 
+const maxConnectBackoff = 30 * time.Second
+
 var logger = applogger.New("PostgreSQL")
+var isAutoMigrate bool
 
 func NewDB(config *Config) *DB {
 	logger.Log("initializing PostgreSQL database")
@@ -26,28 +60,131 @@ func NewDB(config *Config) *DB {
 		logger.Panic(err)
 	}
 
-	db, err := gorm.Open(postgres.New(postgres.Config{
-		DSN: "postgresql://" + config.User + ":" + config.Password + "@" + config.Address + "/" + config.DatabaseName,
-	}), &gorm.Config{
-		PrepareStmt: true,
-	})
+	db, err := connect(config)
 	if err != nil {
 		logger.Panic(err)
 	}
 
+	isAutoMigrate = config.IsAutoMigrate
+
+	if err := db.Use(&tracingPlugin{}); err != nil {
+		logger.Panic(err)
+	}
+
+	if len(config.ReplicaAddresses) > 0 {
+		replicas := make([]gorm.Dialector, 0, len(config.ReplicaAddresses))
+		for _, address := range config.ReplicaAddresses {
+			replicas = append(replicas, postgres.New(postgres.Config{DSN: dsn(config, address)}))
+		}
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		})
+		if err := db.Use(resolver); err != nil {
+			logger.Panic(err)
+		}
+		if err := resolver.Call(func(connPool gorm.ConnPool) error {
+			if sqlDB, ok := connPool.(*sql.DB); ok {
+				setPool(sqlDB, config)
+			}
+			return nil
+		}); err != nil {
+			logger.Panic(err)
+		}
+		logger.Log(fmt.Sprintf("routing reads to %d replica(s)", len(replicas)))
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Panic(err)
+	}
+	setPool(sqlDB, config)
+	metrics.Register(collectors.NewDBStatsCollector(sqlDB, config.DatabaseName))
+
 	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
 		FnDescription: "close PostgreSQL database",
-		Fn: func() {
-			db, err := db.DB()
-			if err != nil {
-				logger.Error(err)
-				return
-			}
-			if err := db.Close(); err != nil {
-				logger.Error(err)
-			}
+		Phase:         gracefulshutdown.PHASE_CLOSE_DB,
+		Fn: func(context.Context) error {
+			return sqlDB.Close()
 		},
 	})
 
 	return db
 }
+
+func connect(config *Config) (*DB, error) {
+	attempts := config.ConnectAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	backoff := config.ConnectBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var db *DB
+		// gorm.Open pings the database, so a successful open means the primary is reachable.
+		db, err = gorm.Open(postgres.New(postgres.Config{
+			DSN: dsn(config, config.Address),
+		}), &gorm.Config{
+			PrepareStmt: true,
+		})
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		logger.Error(fmt.Errorf("connect attempt %d/%d failed, retrying in %s: %w", attempt, attempts, backoff, err), &applogger.Options{IsPrintStack: false})
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+
+	return nil, fmt.Errorf("cannot connect to PostgreSQL after %d attempt(s): %w", attempts, err)
+}
+
+// dsn builds a URL connection string, escaping the credentials so that passwords may contain any character.
+func dsn(config *Config, address string) string {
+	query := url.Values{}
+	if len(config.SSLMode) > 0 {
+		query.Set("sslmode", config.SSLMode)
+	}
+	if len(config.SSLRootCert) > 0 {
+		query.Set("sslrootcert", config.SSLRootCert)
+	}
+	if len(config.SSLCert) > 0 {
+		query.Set("sslcert", config.SSLCert)
+	}
+	if len(config.SSLKey) > 0 {
+		query.Set("sslkey", config.SSLKey)
+	}
+	if len(config.ApplicationName) > 0 {
+		query.Set("application_name", config.ApplicationName)
+	}
+	if config.QueryTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(config.QueryTimeout.Milliseconds(), 10))
+	}
+
+	return (&url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     address,
+		Path:     "/" + config.DatabaseName,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+func setPool(sqlDB *sql.DB, config *Config) {
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+}
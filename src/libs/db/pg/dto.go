@@ -22,29 +22,55 @@ type CountOptions struct {
 	IsUnscoped bool
 }
 
+type CountByOptions struct {
+	Where *[]Where
+	// GroupBy is the SQL expression rows are grouped by. It must come from code, never from user input.
+	GroupBy    Where
+	IsUnscoped bool
+}
+
 type FindOneOptions struct {
 	Where         *[]Where
 	Order         *[]string
 	IncludeTables *[]IncludeTables
 	IsUnscoped    bool
+	// IsPrimary reads from the primary instead of a replica, for reads that a write is based on or that have to see a
+	// write that was just made.
+	IsPrimary bool
 }
 
 type FindAllOptions struct {
-	Where         *[]FindAllWhere
-	Order         *[]string
-	Limit         *int
-	Offset        *int
+	Where  *[]FindAllWhere
+	Order  *[]string
+	Limit  *int
+	Offset *int
+	// Keyset replaces Order, and lets the page start after Cursor or AfterID instead of at Offset.
+	Keyset        *Keyset
+	Cursor        *string
 	AfterID       *uuid.UUID
 	IncludeTables *[]IncludeTables
-	IsUnscoped    bool
+	// Select replaces the selected columns, e.g. to add computed ones such as a search rank that Order can refer to.
+	// It does not apply to the count.
+	Select      *Where
+	IsUnscoped  bool
+	IsSkipCount bool
+	// IsPrimary reads from the primary instead of a replica, like FindOneOptions.IsPrimary.
+	IsPrimary bool
 }
 
 type CreateOptions struct {
 	IsUpsert bool
+	// ConflictColumns is the unique key an upsert matches the existing rows on instead of the primary key, and
+	// ConflictWhere the predicate of its index when the index is partial.
+	ConflictColumns []string
+	ConflictWhere   *Where
 }
 
 type UpdateOptions struct {
-	Where      *[]Where
+	Where *[]Where
+	// Columns limits the update to the given columns, which are then written even when they are zero or nil, e.g. to
+	// unset a nullable column.
+	Columns    *[]string
 	IsUnscoped bool
 }
 
@@ -53,13 +79,25 @@ type ReplaceOptions struct {
 	IsUnscoped bool
 }
 
+type RestoreOptions struct {
+	Where *[]Where
+}
+
 type DestroyOptions struct {
 	Where      *[]Where
 	IsUnscoped bool
 }
 
+type GroupCount struct {
+	Key   string `gorm:"column:group_key"`
+	Count int64  `gorm:"column:group_count"`
+}
+
 type Pagination struct {
 	Limit int
 	Count int
-	Total int
+	// Total is nil when the count was skipped.
+	Total *int
+	Next  *string
+	Prev  *string
 }
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/schema"
+)
+
+type ConstraintKind int
+
+const (
+	CONSTRAINT_UNIQUE ConstraintKind = iota
+	CONSTRAINT_FOREIGN_KEY
+)
+
+var constraintSQLStates = map[string]ConstraintKind{
+	"23505": CONSTRAINT_UNIQUE,
+	"23503": CONSTRAINT_FOREIGN_KEY,
+}
+
+// The column list is only reported in the detail message, e.g. `Key (username)=(hilmy) already exists.`
+var constraintDetailRegexp = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// ConstraintError is a write rejected by a unique or foreign key constraint, which is caused by the data sent by
+// the client rather than by the server.
+type ConstraintError struct {
+	Kind       ConstraintKind
+	Table      string
+	Constraint string
+	// Field is the json name of the offending column, or a comma separated list of them for composite constraints.
+	// It stays the column name when the column is not one of the model written.
+	Field string
+	Err   error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// asConstraintError returns err as a *ConstraintError when it is a constraint violation, and unchanged otherwise. s is
+// the schema of the model written, which names the fields the way clients send them.
+func asConstraintError(err error, s *schema.Schema) error {
+	var constraintError *ConstraintError
+	if errors.As(err, &constraintError) {
+		return err
+	}
+
+	var pgError *pgconn.PgError
+	if !errors.As(err, &pgError) {
+		return err
+	}
+	kind, ok := constraintSQLStates[pgError.Code]
+	if !ok {
+		return err
+	}
+
+	field := pgError.ColumnName
+	if matches := constraintDetailRegexp.FindStringSubmatch(pgError.Detail); matches != nil {
+		field = matches[1]
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Table:      pgError.TableName,
+		Constraint: pgError.ConstraintName,
+		Field:      constraintField(s, pgError.TableName, field),
+		Err:        err,
+	}
+}
+
+func constraintField(s *schema.Schema, table string, columns string) string {
+	if s == nil || s.Table != table {
+		return columns
+	}
+
+	fields := strings.Split(columns, ", ")
+	for i, column := range fields {
+		field := s.LookUpField(column)
+		if field == nil {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		fields[i] = name
+	}
+	return strings.Join(fields, ", ")
+}
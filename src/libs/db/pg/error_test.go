@@ -0,0 +1,155 @@
+package pg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm/schema"
+)
+
+func TestAsConstraintError(t *testing.T) {
+	tests := []struct {
+		name      string
+		pgError   *pgconn.PgError
+		wantKind  ConstraintKind
+		wantField string
+	}{
+		{
+			name: "unique",
+			pgError: &pgconn.PgError{
+				Code:           "23505",
+				TableName:      "accounts",
+				ConstraintName: "idx_accounts_username",
+				Detail:         "Key (username)=(hilmy) already exists.",
+			},
+			wantKind:  CONSTRAINT_UNIQUE,
+			wantField: "username",
+		},
+		{
+			name: "composite unique",
+			pgError: &pgconn.PgError{
+				Code:           "23505",
+				TableName:      "cart_items",
+				ConstraintName: "idx_cart_items_cart_product",
+				Detail:         "Key (cart_id, product_id)=(1, 2) already exists.",
+			},
+			wantKind:  CONSTRAINT_UNIQUE,
+			wantField: "cart_id, product_id",
+		},
+		{
+			name: "foreign key",
+			pgError: &pgconn.PgError{
+				Code:           "23503",
+				TableName:      "products",
+				ConstraintName: "fk_products_category",
+				Detail:         `Key (category_id)=(6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11) is not present in table "product_categories".`,
+			},
+			wantKind:  CONSTRAINT_FOREIGN_KEY,
+			wantField: "category_id",
+		},
+		{
+			name: "column name without a detail",
+			pgError: &pgconn.PgError{
+				Code:       "23503",
+				TableName:  "products",
+				ColumnName: "category_id",
+			},
+			wantKind:  CONSTRAINT_FOREIGN_KEY,
+			wantField: "category_id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The driver error usually reaches the service wrapped.
+			err := asConstraintError(fmt.Errorf("insert: %w", tt.pgError), nil)
+
+			var constraintError *ConstraintError
+			if !errors.As(err, &constraintError) {
+				t.Fatalf("err = %T, want a *ConstraintError", err)
+			}
+			if constraintError.Kind != tt.wantKind {
+				t.Errorf("Kind = %v, want %v", constraintError.Kind, tt.wantKind)
+			}
+			if constraintError.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", constraintError.Field, tt.wantField)
+			}
+			if constraintError.Table != tt.pgError.TableName || constraintError.Constraint != tt.pgError.ConstraintName {
+				t.Errorf("got table %q and constraint %q, want %q and %q", constraintError.Table,
+					constraintError.Constraint, tt.pgError.TableName, tt.pgError.ConstraintName)
+			}
+			if !errors.Is(err, tt.pgError) {
+				t.Error("the driver error is not unwrapped")
+			}
+		})
+	}
+}
+
+type constraintTestModel struct {
+	Model
+	CartID    *uuid.UUID `json:"cartId,omitempty"`
+	ProductID *uuid.UUID `json:"productId,omitempty"`
+	Secret    *string    `json:"-"`
+	Note      *string
+}
+
+func (constraintTestModel) TableName() string {
+	return "cart_items"
+}
+
+func TestAsConstraintErrorNamesJSONFields(t *testing.T) {
+	s, err := schema.Parse(&constraintTestModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		table     string
+		columns   string
+		wantField string
+	}{
+		{"column", "cart_items", "cart_id", "cartId"},
+		{"composite", "cart_items", "cart_id, product_id", "cartId, productId"},
+		{"without a json tag", "cart_items", "note", "Note"},
+		{"hidden from json", "cart_items", "secret", "secret"},
+		{"unknown column", "cart_items", "variant_id", "variant_id"},
+		// Deleting a row that is still referred to reports the column of the referring table.
+		{"another table", "shopping_carts", "cart_id", "cart_id"},
+	}
+	for _, tt := range tests {
+		pgError := &pgconn.PgError{Code: "23503", TableName: tt.table, Detail: "Key (" + tt.columns + ")=(1) is not present."}
+
+		var constraintError *ConstraintError
+		if !errors.As(asConstraintError(pgError, s), &constraintError) {
+			t.Fatalf("%s: not a *ConstraintError", tt.name)
+		}
+		if constraintError.Field != tt.wantField {
+			t.Errorf("%s: Field = %q, want %q", tt.name, constraintError.Field, tt.wantField)
+		}
+	}
+}
+
+func TestAsConstraintErrorKeepsConstraintErrors(t *testing.T) {
+	err := fmt.Errorf("commit: %w", &ConstraintError{Field: "cartId", Err: &pgconn.PgError{Code: "23505"}})
+	if got := asConstraintError(err, nil); got != err {
+		t.Errorf("asConstraintError = %v, want it unchanged", got)
+	}
+}
+
+func TestAsConstraintErrorKeepsOtherErrors(t *testing.T) {
+	for _, err := range []error{
+		nil,
+		errors.New("connection refused"),
+		// not_null_violation and check_violation are bugs on the server side rather than bad client data.
+		&pgconn.PgError{Code: "23502", ColumnName: "title"},
+		&pgconn.PgError{Code: "23514", ConstraintName: "chk_products_stock"},
+	} {
+		if got := asConstraintError(err, nil); got != err {
+			t.Errorf("asConstraintError(%v) = %v, want it unchanged", err, got)
+		}
+	}
+}
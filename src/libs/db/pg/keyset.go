@@ -0,0 +1,196 @@
+package pg
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"hilmy.dev/store/src/libs/db/cursor"
+)
+
+// Keyset orders FindAll by a not null column, breaking ties by id, and makes it return cursors instead of relying on
+// OFFSET. The column must come from code or a whitelist, never straight from user input.
+type Keyset struct {
+	Column string
+	IsDesc bool
+}
+
+type keysetPage struct {
+	keyset *Keyset
+	after  *cursor.Cursor
+	// afterValue and afterID are the values of after parsed to the types of their columns.
+	afterValue interface{}
+	afterID    interface{}
+	// isBackward scans towards the start of the list, for prev cursors. The rows are reversed back afterwards.
+	isBackward bool
+}
+
+func newKeysetPage(s *schema.Schema, findOptions *FindAllOptions) (*keysetPage, error) {
+	page := &keysetPage{keyset: findOptions.Keyset}
+
+	if findOptions.Cursor != nil && len(*findOptions.Cursor) > 0 {
+		after, err := cursor.Decode(*findOptions.Cursor, findOptions.Keyset.Column, findOptions.Keyset.IsDesc)
+		if err != nil {
+			return nil, err
+		}
+
+		valueField := s.LookUpField(findOptions.Keyset.Column)
+		idField := s.LookUpField("id")
+		if valueField == nil || idField == nil {
+			return nil, fmt.Errorf("%s has no %s or id column to read a cursor with", s.Table, findOptions.Keyset.Column)
+		}
+		// A tampered value would otherwise only fail once Postgres casts it, as an internal error.
+		if page.afterValue, err = parseCursorValue(valueField, after.Value); err != nil {
+			return nil, cursor.ErrInvalid
+		}
+		if page.afterID, err = parseCursorValue(idField, after.ID); err != nil {
+			return nil, cursor.ErrInvalid
+		}
+
+		page.after = after
+		page.isBackward = after.IsBackward
+	}
+
+	return page, nil
+}
+
+func (k *keysetPage) apply(query *DB, afterID interface{}) *DB {
+	isDesc := k.keyset.IsDesc != k.isBackward
+	operator := ">"
+	if isDesc {
+		operator = "<"
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: k.keyset.Column}
+	id := clause.Column{Table: clause.CurrentTable, Name: "id"}
+
+	if k.after != nil {
+		query = query.Where(clause.Expr{
+			SQL:  "(?, ?) " + operator + " (?, ?)",
+			Vars: []interface{}{column, id, k.afterValue, k.afterID},
+		})
+	} else if afterID != nil {
+		// AfterID starts after a known row without a token, reading its sort value in the same statement.
+		query = query.Where(clause.Expr{
+			SQL: "(?, ?) " + operator + " (SELECT anchor.?, anchor.id FROM ? AS anchor WHERE anchor.id = ?)",
+			Vars: []interface{}{
+				column, id,
+				clause.Column{Name: k.keyset.Column}, clause.Table{Name: clause.CurrentTable}, afterID,
+			},
+		})
+	}
+
+	return query.
+		Order(clause.OrderByColumn{Column: column, Desc: isDesc}).
+		Order(clause.OrderByColumn{Column: id, Desc: isDesc})
+}
+
+// cursors returns the tokens for the pages before and after rows. hasMore tells whether the scan stopped early,
+// which is the far side of rows in the direction it went.
+func (k *keysetPage) cursors(s *schema.Schema, rows []reflect.Value, hasMore bool, isFromStart bool) (prev *string, next *string, err error) {
+	if len(rows) == 0 {
+		return nil, nil, nil
+	}
+
+	first, err := k.cursor(s, rows[0], true)
+	if err != nil {
+		return nil, nil, err
+	}
+	last, err := k.cursor(s, rows[len(rows)-1], false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if k.isBackward {
+		if hasMore {
+			prev = first
+		}
+		return prev, last, nil
+	}
+
+	if !isFromStart {
+		prev = first
+	}
+	if hasMore {
+		next = last
+	}
+	return prev, next, nil
+}
+
+func (k *keysetPage) cursor(s *schema.Schema, row reflect.Value, isBackward bool) (*string, error) {
+	valueField := s.LookUpField(k.keyset.Column)
+	idField := s.LookUpField("id")
+	if valueField == nil || idField == nil {
+		return nil, fmt.Errorf("%s has no %s or id column to build a cursor from", s.Table, k.keyset.Column)
+	}
+
+	value, _ := valueField.ValueOf(context.Background(), row)
+	id, _ := idField.ValueOf(context.Background(), row)
+
+	return cursor.Encode(&cursor.Cursor{
+		Key:        k.keyset.Column,
+		IsDesc:     k.keyset.IsDesc,
+		Value:      formatCursorValue(value),
+		ID:         formatCursorValue(id),
+		IsBackward: isBackward,
+	}), nil
+}
+
+// formatCursorValue renders values as text, which parseCursorValue reads back.
+func formatCursorValue(value interface{}) string {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	// Nullable wrappers such as gorm.DeletedAt are rendered by the value they hold.
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return ""
+		}
+		v = reflect.ValueOf(value)
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// parseCursorValue reads text made by formatCursorValue back as the type of field.
+func parseCursorValue(field *schema.Field, text string) (interface{}, error) {
+	switch field.DataType {
+	case schema.Bool:
+		return strconv.ParseBool(text)
+	case schema.Int:
+		return strconv.ParseInt(text, 10, 64)
+	case schema.Uint:
+		return strconv.ParseUint(text, 10, 64)
+	case schema.Float:
+		return strconv.ParseFloat(text, 64)
+	case schema.Time:
+		return time.Parse(time.RFC3339Nano, text)
+	case schema.String:
+		return text, nil
+	}
+
+	// Other types such as uuid.UUID read their own text.
+	value := reflect.New(field.IndirectFieldType)
+	if unmarshaler, ok := value.Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(text)); err != nil {
+			return nil, err
+		}
+		return value.Elem().Interface(), nil
+	}
+	return text, nil
+}
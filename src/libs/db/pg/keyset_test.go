@@ -0,0 +1,263 @@
+package pg
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"hilmy.dev/store/src/libs/db/cursor"
+)
+
+type keysetTestModel struct {
+	Model
+	Price *int
+}
+
+func (keysetTestModel) TableName() string {
+	return "keyset_test"
+}
+
+// newDryRunDB builds statements without ever connecting.
+func newDryRunDB(t *testing.T) *DB {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func keysetTestRows(t *testing.T, prices ...int) (*schema.Schema, []reflect.Value) {
+	s, err := schema.Parse(&keysetTestModel{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := make([]reflect.Value, 0, len(prices))
+	for i, price := range prices {
+		id := uuid.MustParse("00000000-0000-0000-0000-00000000000" + string(rune('1'+i)))
+		rows = append(rows, reflect.ValueOf(&keysetTestModel{Model: Model{ID: &id}, Price: &price}).Elem())
+	}
+	return s, rows
+}
+
+func decodeTestCursor(t *testing.T, token *string) *cursor.Cursor {
+	if token == nil {
+		return nil
+	}
+	c, err := cursor.Decode(*token, "price", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestKeysetCursors(t *testing.T) {
+	first := &cursor.Cursor{Key: "price", Value: "10", ID: "00000000-0000-0000-0000-000000000001", IsBackward: true}
+	last := &cursor.Cursor{Key: "price", Value: "30", ID: "00000000-0000-0000-0000-000000000003"}
+
+	tests := []struct {
+		name        string
+		isBackward  bool
+		hasMore     bool
+		isFromStart bool
+		wantPrev    *cursor.Cursor
+		wantNext    *cursor.Cursor
+	}{
+		{name: "first page with more", hasMore: true, isFromStart: true, wantNext: last},
+		{name: "only page", isFromStart: true},
+		{name: "middle page", hasMore: true, wantPrev: first, wantNext: last},
+		{name: "last page", wantPrev: first},
+		{name: "backward with more", isBackward: true, hasMore: true, wantPrev: first, wantNext: last},
+		{name: "backward to the start", isBackward: true, wantNext: last},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, rows := keysetTestRows(t, 10, 20, 30)
+			page := &keysetPage{keyset: &Keyset{Column: "price"}, isBackward: tt.isBackward}
+
+			prev, next, err := page.cursors(s, rows, tt.hasMore, tt.isFromStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := decodeTestCursor(t, prev); !reflect.DeepEqual(got, tt.wantPrev) {
+				t.Errorf("prev = %+v, want %+v", got, tt.wantPrev)
+			}
+			if got := decodeTestCursor(t, next); !reflect.DeepEqual(got, tt.wantNext) {
+				t.Errorf("next = %+v, want %+v", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestKeysetCursorsOfEmptyPage(t *testing.T) {
+	s, _ := keysetTestRows(t)
+	page := &keysetPage{keyset: &Keyset{Column: "price"}}
+
+	prev, next, err := page.cursors(s, nil, false, false)
+	if err != nil || prev != nil || next != nil {
+		t.Errorf("cursors = %v, %v, %v, want none", prev, next, err)
+	}
+}
+
+func TestKeysetApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		isDesc bool
+		cursor *cursor.Cursor
+		want   []string
+	}{
+		{
+			name: "first page",
+			want: []string{`ORDER BY "keyset_test"."price","keyset_test"."id"`},
+		},
+		{
+			name:   "forward",
+			cursor: &cursor.Cursor{Key: "price", Value: "20", ID: "1"},
+			want: []string{
+				`("keyset_test"."price", "keyset_test"."id") > ($1, $2)`,
+				`ORDER BY "keyset_test"."price","keyset_test"."id"`,
+			},
+		},
+		{
+			name:   "backward",
+			cursor: &cursor.Cursor{Key: "price", Value: "20", ID: "1", IsBackward: true},
+			want: []string{
+				`("keyset_test"."price", "keyset_test"."id") < ($1, $2)`,
+				`ORDER BY "keyset_test"."price" DESC,"keyset_test"."id" DESC`,
+			},
+		},
+		{
+			name:   "backward on a descending order",
+			isDesc: true,
+			cursor: &cursor.Cursor{Key: "price", Value: "20", ID: "1", IsBackward: true},
+			want: []string{
+				`("keyset_test"."price", "keyset_test"."id") > ($1, $2)`,
+				`ORDER BY "keyset_test"."price","keyset_test"."id"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := &keysetPage{keyset: &Keyset{Column: "price", IsDesc: tt.isDesc}, after: tt.cursor}
+			if tt.cursor != nil {
+				page.afterValue, page.afterID = tt.cursor.Value, tt.cursor.ID
+				page.isBackward = tt.cursor.IsBackward
+			}
+
+			statement := page.apply(newDryRunDB(t).Model(&keysetTestModel{}), nil).Find(&[]*keysetTestModel{}).Statement
+			sql := statement.SQL.String()
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Errorf("SQL %s does not contain %s", sql, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewKeysetPage(t *testing.T) {
+	s, _ := keysetTestRows(t)
+	id := "00000000-0000-0000-0000-000000000001"
+
+	page, err := newKeysetPage(s, &FindAllOptions{
+		Keyset: &Keyset{Column: "price", IsDesc: true},
+		Cursor: cursor.Encode(&cursor.Cursor{Key: "price", IsDesc: true, Value: "20", ID: id, IsBackward: true}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.afterValue != int64(20) || page.afterID != uuid.MustParse(id) || !page.isBackward {
+		t.Errorf("page after %v, %v, backward %v", page.afterValue, page.afterID, page.isBackward)
+	}
+}
+
+func TestNewKeysetPageRejectsInvalidCursors(t *testing.T) {
+	s, _ := keysetTestRows(t)
+	id := "00000000-0000-0000-0000-000000000001"
+
+	tests := map[string]*cursor.Cursor{
+		"another column":     {Key: "title", Value: "a", ID: id},
+		"another direction":  {Key: "price", IsDesc: true, Value: "20", ID: id},
+		"value not a number": {Key: "price", Value: "twenty", ID: id},
+		"id not a uuid":      {Key: "price", Value: "20", ID: "1"},
+	}
+	for name, c := range tests {
+		_, err := newKeysetPage(s, &FindAllOptions{Keyset: &Keyset{Column: "price"}, Cursor: cursor.Encode(c)})
+		if !errors.Is(err, cursor.ErrInvalid) {
+			t.Errorf("%s: err = %v, want %v", name, err, cursor.ErrInvalid)
+		}
+	}
+}
+
+func TestParseCursorValue(t *testing.T) {
+	s, err := schema.Parse(&struct {
+		Model
+		Price  int
+		Rating float64
+		IsSale bool
+		Title  string
+	}{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, time.March, 1, 3, 30, 0, 5, time.UTC)
+
+	tests := []struct {
+		column string
+		text   string
+		want   interface{}
+	}{
+		{"price", "1500", int64(1500)},
+		{"rating", "4.5", 4.5},
+		{"is_sale", "true", true},
+		{"title", "shirt", "shirt"},
+		{"created_at", "2024-03-01T03:30:00.000000005Z", at},
+		{"deleted_at", "2024-03-01T03:30:00.000000005Z", at},
+		{"id", "00000000-0000-0000-0000-000000000001", uuid.MustParse("00000000-0000-0000-0000-000000000001")},
+	}
+	for _, tt := range tests {
+		got, err := parseCursorValue(s.LookUpField(tt.column), tt.text)
+		if err != nil {
+			t.Errorf("%s: %v", tt.column, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseCursorValue = %#v, want %#v", tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCursorValue(t *testing.T) {
+	price := 1500
+	var nilPrice *int
+	at := time.Date(2024, time.March, 1, 10, 30, 0, 5, time.FixedZone("WIB", 7*60*60))
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"pointer", &price, "1500"},
+		{"nil pointer", nilPrice, ""},
+		{"time in UTC", &at, "2024-03-01T03:30:00.000000005Z"},
+		{"valid deleted at", &gorm.DeletedAt{Time: at, Valid: true}, "2024-03-01T03:30:00.000000005Z"},
+		{"null deleted at", &gorm.DeletedAt{}, ""},
+		{"string", "shirt", "shirt"},
+	}
+	for _, tt := range tests {
+		if got := formatCursorValue(tt.value); got != tt.want {
+			t.Errorf("%s: formatCursorValue = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
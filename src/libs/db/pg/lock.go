@@ -0,0 +1,44 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+)
+
+// WithTryLock runs fn unless another session holds the advisory lock key, such as another instance of the app running
+// the same job, and reports whether fn ran. The lock is taken on a pinned connection, because advisory locks belong to
+// the session that took them, while fn is free to use the rest of the pool.
+func WithTryLock(ctx context.Context, db *DB, key int64, fn func()) (bool, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Error(err)
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		logger.Error(err)
+		return false, err
+	}
+	defer conn.Close()
+
+	isLocked := false
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&isLocked); err != nil {
+		logger.Error(err)
+		return false, err
+	}
+	if !isLocked {
+		return false, nil
+	}
+	defer unlock(conn, key)
+
+	fn()
+	return true, nil
+}
+
+// unlock releases the lock even when the context of the job is cancelled by then, since the connection goes back to
+// the pool afterwards.
+func unlock(conn *sql.Conn, key int64) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,298 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationLockKey is an arbitrary application-wide key for pg_advisory_lock so that replicas starting together
+// apply migrations one at a time.
+const migrationLockKey int64 = 7_265_339_021
+
+const migrationTableName = "schema_migrations"
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	migrationMap := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+
+		migration, ok := migrationMap[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			migrationMap[version] = migration
+		} else if migration.Name != matches[2] {
+			err := fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, matches[2])
+			logger.Error(err)
+			return nil, err
+		}
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(migrationMap))
+	for _, migration := range migrationMap {
+		if len(migration.Up) == 0 {
+			err := fmt.Errorf("migration %d_%s is missing its up file", migration.Version, migration.Name)
+			logger.Error(err)
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp applies up to steps pending migrations in version order. A non-positive steps applies all of them.
+func MigrateUp(db *DB, fsys fs.FS, steps int) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, migration := range migrations {
+			if steps > 0 && count >= steps {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			logger.Log(fmt.Sprintf("applying migration %d_%s", migration.Version, migration.Name))
+			if err := runMigration(ctx, conn, migration.Up,
+				"INSERT INTO "+migrationTableName+" (version, name, applied_at) VALUES ($1, $2, now())",
+				migration.Version, migration.Name,
+			); err != nil {
+				err := fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+				logger.Error(err)
+				return err
+			}
+			count++
+		}
+
+		if count == 0 {
+			logger.Log("database schema is up to date")
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the latest steps applied migrations. A non-positive steps reverts only the latest one.
+func MigrateDown(db *DB, fsys fs.FS, steps int) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	if steps <= 0 {
+		steps = 1
+	}
+
+	return withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if len(migration.Down) == 0 {
+				err := fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+				logger.Error(err)
+				return err
+			}
+
+			logger.Log(fmt.Sprintf("reverting migration %d_%s", migration.Version, migration.Name))
+			if err := runMigration(ctx, conn, migration.Down,
+				"DELETE FROM "+migrationTableName+" WHERE version = $1",
+				migration.Version,
+			); err != nil {
+				err := fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+				logger.Error(err)
+				return err
+			}
+			count++
+		}
+
+		return nil
+	})
+}
+
+func GetMigrationStatus(db *DB, fsys fs.FS) ([]*MigrationStatus, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, 0, len(migrations))
+	if err := withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// withMigrationLock pins a single connection, because advisory locks belong to the session that took them.
+func withMigrationLock(db *DB, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	defer conn.Close()
+
+	// Waiting for the lock and running migrations may legitimately take longer than the per-query timeout.
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		logger.Error(err)
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "RESET statement_timeout"); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		logger.Error(err)
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationTableName+" (version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL)"); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return fn(ctx, conn)
+}
+
+func getAppliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+migrationTableName)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, script string, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logger.Error(err)
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		if err := tx.Rollback(); err != nil {
+			logger.Error(err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_add_index.up.sql":     {Data: []byte("CREATE INDEX")},
+		"0002_orders.up.sql":        {Data: []byte("CREATE TABLE orders")},
+		"0002_orders.down.sql":      {Data: []byte("DROP TABLE orders")},
+		"0001_init.up.sql":          {Data: []byte("CREATE TABLE account")},
+		"0001_init.down.sql":        {Data: []byte("DROP TABLE account")},
+		"README.md":                 {Data: []byte("not a migration")},
+		"0003_notes.sql":            {Data: []byte("not a migration either")},
+		"0004_dir.up.sql/nested":    {Data: []byte("in a directory")},
+		"0005_unknown.sideways.sql": {Data: []byte("not a direction")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE account", Down: "DROP TABLE account"},
+		{Version: 2, Name: "orders", Up: "CREATE TABLE orders", Down: "DROP TABLE orders"},
+		{Version: 10, Name: "add_index", Up: "CREATE INDEX"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, migration := range migrations {
+		if *migration != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, *migration, want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsInconsistentFiles(t *testing.T) {
+	tests := map[string]struct {
+		fsys fstest.MapFS
+		want string
+	}{
+		"missing up": {
+			fsys: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("DROP TABLE account")},
+			},
+			want: "missing its up file",
+		},
+		"conflicting names": {
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":      {Data: []byte("CREATE TABLE account")},
+				"0001_initial.down.sql": {Data: []byte("DROP TABLE account")},
+			},
+			want: "conflicting names",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMigrations(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one saying %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -13,3 +13,8 @@ type Model struct {
 	UpdatedAt *time.Time      `json:"updatedAt,omitempty"`
 	DeletedAt *gorm.DeletedAt `json:"deletedAt,omitempty"`
 }
+
+// GetID lets code that is generic over the models read their id.
+func (m Model) GetID() *uuid.UUID {
+	return m.ID
+}
@@ -0,0 +1,29 @@
+package pg
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms bounds the number of words taken from a search, so that a long input cannot build a huge query.
+const maxSearchTerms = 10
+
+// PrefixTSQuery turns free text into a to_tsquery input that matches documents containing every word as a prefix, so
+// that "red sho" becomes "red:* & sho:*". Only letters and digits are kept, which leaves nothing for to_tsquery to
+// reject. It returns false when the text has no words.
+func PrefixTSQuery(text string) (string, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "", false
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+
+	for i := range words {
+		words[i] += ":*"
+	}
+	return strings.Join(words, " & "), true
+}
@@ -1,8 +1,13 @@
 package pg
 
 import (
+	"context"
+	"reflect"
+	"slices"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 	"hilmy.dev/store/src/libs/validator"
 )
 
@@ -19,26 +24,35 @@ func NewService[T ModelI](db *DB) *Service[T] {
 		logger.Panic("service.DB must exist")
 	}
 
-	model := new(T)
-	if err := db.AutoMigrate(model); err != nil {
-		logger.Panic(err)
+	if isAutoMigrate {
+		model := new(T)
+		if err := db.AutoMigrate(model); err != nil {
+			logger.Panic(err)
+		}
 	}
 
 	return &Service[T]{DB: db}
 }
 
+// WithContext returns a copy of the service whose queries are bound to ctx, so that they are cancelled together with
+// the request and traced under its span.
+func (s *Service[T]) WithContext(ctx context.Context) *Service[T] {
+	return &Service[T]{DB: s.DB.WithContext(ctx)}
+}
+
 func Transaction(db *DB, txs ...func(tx *DB) *DB) error {
 	if err := db.Transaction(func(tx *DB) error {
 		for i := range txs {
-			if err := txs[i](tx).Error; err != nil {
+			result := txs[i](tx)
+			if err := result.Error; err != nil {
 				logger.Error(err)
-				return err
+				return asConstraintError(err, result.Statement.Schema)
 			}
 		}
 		return nil
 	}); err != nil {
 		logger.Error(err)
-		return err
+		return asConstraintError(err, nil)
 	}
 	return nil
 }
@@ -46,7 +60,8 @@ func Transaction(db *DB, txs ...func(tx *DB) *DB) error {
 func (s *Service[T]) Count(countOptions *CountOptions) (*int64, error) {
 	docStruct := new(T)
 
-	countQuery := s.DB.Model(docStruct)
+	// Only FindOne and FindAll may be served by read replicas.
+	countQuery := s.DB.Clauses(dbresolver.Write).Model(docStruct)
 
 	if countOptions.Where != nil {
 		for _, where := range *countOptions.Where {
@@ -66,6 +81,37 @@ func (s *Service[T]) Count(countOptions *CountOptions) (*int64, error) {
 	return count, nil
 }
 
+// CountBy counts the rows in each group, such as the products per category. Groups without rows are left out.
+func (s *Service[T]) CountBy(countByOptions *CountByOptions) (*[]*GroupCount, error) {
+	docStruct := new(T)
+
+	// Only FindOne and FindAll may be served by read replicas.
+	countQuery := s.DB.Clauses(dbresolver.Write).Model(docStruct)
+
+	if countByOptions.Where != nil {
+		for _, where := range *countByOptions.Where {
+			countQuery = countQuery.Where(where.Query, where.Args...)
+		}
+	}
+	if countByOptions.IsUnscoped {
+		countQuery = countQuery.Unscoped()
+	}
+
+	groupCounts := &[]*GroupCount{}
+	if err := countQuery.
+		Clauses(clause.Select{Expression: clause.Expr{
+			SQL:  countByOptions.GroupBy.Query + " AS group_key, count(*) AS group_count",
+			Vars: countByOptions.GroupBy.Args,
+		}}).
+		Group("group_key").
+		Scan(groupCounts).Error; err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return groupCounts, nil
+}
+
 func (s *Service[T]) FindOne(findOptions *FindOneOptions) (*T, error) {
 	docStruct := new(T)
 
@@ -89,6 +135,9 @@ func (s *Service[T]) FindOne(findOptions *FindOneOptions) (*T, error) {
 	if findOptions.IsUnscoped {
 		selectQuery = selectQuery.Unscoped()
 	}
+	if findOptions.IsPrimary {
+		selectQuery = selectQuery.Clauses(dbresolver.Write)
+	}
 
 	if err := selectQuery.Take(docStruct).Error; err != nil {
 		if !IsErrRecordNotFound(err) {
@@ -120,9 +169,20 @@ func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, e
 	if findOptions.IsUnscoped {
 		selectQuery = selectQuery.Unscoped()
 	}
+	if findOptions.IsPrimary {
+		selectQuery = selectQuery.Clauses(dbresolver.Write)
+	}
 
-	var total int64
-	selectQuery.Count(&total)
+	var total *int
+	if !findOptions.IsSkipCount {
+		var count int64
+		if err := selectQuery.Count(&count).Error; err != nil {
+			logger.Error(err)
+			return nil, nil, err
+		}
+		total = new(int)
+		*total = int(count)
+	}
 
 	if findOptions.Where != nil {
 		for _, where := range *findOptions.Where {
@@ -131,34 +191,94 @@ func (s *Service[T]) FindAll(findOptions *FindAllOptions) (*[]*T, *Pagination, e
 			}
 		}
 	}
-	if findOptions.Order != nil {
+	if findOptions.Select != nil {
+		selectQuery = selectQuery.Select(findOptions.Select.Query, findOptions.Select.Args...)
+	}
+
+	var keyset *keysetPage
+	if findOptions.Keyset != nil {
+		if err := selectQuery.Statement.Parse(docStruct); err != nil {
+			logger.Error(err)
+			return nil, nil, err
+		}
+		var err error
+		keyset, err = newKeysetPage(selectQuery.Statement.Schema, findOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		var afterID interface{}
+		if findOptions.AfterID != nil {
+			afterID = findOptions.AfterID
+		}
+		selectQuery = keyset.apply(selectQuery, afterID)
+	} else if findOptions.Order != nil {
 		for _, order := range *findOptions.Order {
 			selectQuery = selectQuery.Order(order)
 		}
 	}
-	if findOptions.Limit != nil && *findOptions.Limit > 0 {
+
+	if findOptions.Limit == nil {
+		findOptions.Limit = new(int)
+	}
+	if *findOptions.Limit > 0 {
 		if *findOptions.Limit > FindAllMaximumLimit {
 			*findOptions.Limit = FindAllMaximumLimit
 		}
 	} else {
 		*findOptions.Limit = FindAllDefaultLimit
 	}
-	selectQuery = selectQuery.Limit(*findOptions.Limit)
 
-	if findOptions.Offset != nil && *findOptions.Offset > 0 {
+	limit := *findOptions.Limit
+	if keyset != nil {
+		// One extra row tells whether there is another page after this one.
+		limit++
+	}
+	selectQuery = selectQuery.Limit(limit)
+
+	isFromStart := true
+	if keyset != nil && (keyset.after != nil || findOptions.AfterID != nil) {
+		isFromStart = false
+	} else if findOptions.Offset != nil && *findOptions.Offset > 0 {
+		isFromStart = false
 		selectQuery = selectQuery.Offset(*findOptions.Offset)
 	}
 
-	if err := selectQuery.Find(docStruct).Error; err != nil {
+	result := selectQuery.Find(docStruct)
+	if err := result.Error; err != nil {
 		logger.Error(err)
 		return nil, nil, err
 	}
 
-	return docStruct, &Pagination{
+	pagination := &Pagination{
 		Limit: *findOptions.Limit,
-		Count: len(*docStruct),
-		Total: int(total),
-	}, nil
+		Total: total,
+	}
+
+	if keyset != nil {
+		hasMore := len(*docStruct) > *findOptions.Limit
+		if hasMore {
+			*docStruct = (*docStruct)[:*findOptions.Limit]
+		}
+		if keyset.isBackward {
+			slices.Reverse(*docStruct)
+		}
+
+		rows := make([]reflect.Value, len(*docStruct))
+		for i, doc := range *docStruct {
+			rows[i] = reflect.ValueOf(doc).Elem()
+		}
+		prev, next, err := keyset.cursors(result.Statement.Schema, rows, hasMore, isFromStart)
+		if err != nil {
+			logger.Error(err)
+			return nil, nil, err
+		}
+		pagination.Prev = prev
+		pagination.Next = next
+	}
+
+	pagination.Count = len(*docStruct)
+
+	return docStruct, pagination, nil
 }
 
 func (s *Service[T]) Create(data *T, createOptions ...*CreateOptions) (*T, error) {
@@ -167,9 +287,10 @@ func (s *Service[T]) Create(data *T, createOptions ...*CreateOptions) (*T, error
 		return nil, err
 	}
 
-	if err := s.CreateTx(s.DB, data, createOptions...).Error; err != nil {
+	tx := s.CreateTx(s.DB, data, createOptions...)
+	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, asConstraintError(err, tx.Statement.Schema)
 	}
 
 	return data, nil
@@ -183,9 +304,10 @@ func (s *Service[T]) BulkCreate(data *[]*T, createOptions ...*CreateOptions) (*[
 		}
 	}
 
-	if err := s.BulkCreateTx(s.DB, data, createOptions...).Error; err != nil {
+	tx := s.BulkCreateTx(s.DB, data, createOptions...)
+	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, asConstraintError(err, tx.Statement.Schema)
 	}
 
 	return data, nil
@@ -200,7 +322,7 @@ func (s *Service[T]) Update(data *T, updateOptions ...*UpdateOptions) (*T, error
 	tx := s.UpdateTx(s.DB, data, updateOptions...)
 	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, asConstraintError(err, tx.Statement.Schema)
 	}
 	if tx.RowsAffected == 0 {
 		return nil, gorm.ErrRecordNotFound
@@ -209,6 +331,20 @@ func (s *Service[T]) Update(data *T, updateOptions ...*UpdateOptions) (*T, error
 	return data, nil
 }
 
+// UpdateExpr is UpdateExprTx outside of a transaction, which fails with gorm.ErrRecordNotFound when no row matches.
+func (s *Service[T]) UpdateExpr(exprs map[string]Where, updateOptions ...*UpdateOptions) error {
+	tx := s.UpdateExprTx(s.DB, exprs, updateOptions...)
+	if err := tx.Error; err != nil {
+		logger.Error(err)
+		return asConstraintError(err, tx.Statement.Schema)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (s *Service[T]) BulkUpdate(data *[]*T, updateOptions ...*UpdateOptions) (*[]*T, error) {
 	for _, doc := range *data {
 		if err := validator.Struct(doc); err != nil {
@@ -220,7 +356,7 @@ func (s *Service[T]) BulkUpdate(data *[]*T, updateOptions ...*UpdateOptions) (*[
 	tx := s.BulkUpdateTx(s.DB, data, updateOptions...)
 	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return nil, err
+		return nil, asConstraintError(err, tx.Statement.Schema)
 	}
 	if tx.RowsAffected == 0 {
 		return nil, gorm.ErrRecordNotFound
@@ -238,7 +374,21 @@ func (s *Service[T]) Replace(data *T, replaceOptions ...*ReplaceOptions) error {
 	tx := s.ReplaceTx(s.DB, data, replaceOptions...)
 	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return err
+		return asConstraintError(err, tx.Statement.Schema)
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Restore undoes the soft deletion of the deleted rows matched by restoreOptions.
+func (s *Service[T]) Restore(restoreOptions *RestoreOptions) error {
+	tx := s.RestoreTx(s.DB, restoreOptions)
+	if err := tx.Error; err != nil {
+		logger.Error(err)
+		return asConstraintError(err, tx.Statement.Schema)
 	}
 	if tx.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
@@ -251,7 +401,7 @@ func (s *Service[T]) Destroy(data *T, destroyOptions ...*DestroyOptions) error {
 	tx := s.DestroyTx(s.DB, data, destroyOptions...)
 	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return err
+		return asConstraintError(err, tx.Statement.Schema)
 	}
 	if tx.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
@@ -264,7 +414,7 @@ func (s *Service[T]) BulkDestroy(data *[]*T, destroyOptions ...*DestroyOptions)
 	tx := s.BulkDestroyTx(s.DB, data, destroyOptions...)
 	if err := tx.Error; err != nil {
 		logger.Error(err)
-		return err
+		return asConstraintError(err, tx.Statement.Schema)
 	}
 	if tx.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
@@ -280,7 +430,7 @@ func (s *Service[T]) CreateTx(tx *DB, data *T, createOptions ...*CreateOptions)
 
 	if len(createOptions) > 0 {
 		if createOptions[0].IsUpsert {
-			insertQuery = insertQuery.Clauses(clause.OnConflict{UpdateAll: true})
+			insertQuery = insertQuery.Clauses(upsertClause(createOptions[0]))
 		}
 	}
 
@@ -294,13 +444,28 @@ func (s *Service[T]) BulkCreateTx(tx *DB, data *[]*T, createOptions ...*CreateOp
 
 	if len(createOptions) > 0 {
 		if createOptions[0].IsUpsert {
-			insertQuery = insertQuery.Clauses(clause.OnConflict{UpdateAll: true})
+			insertQuery = insertQuery.Clauses(upsertClause(createOptions[0]))
 		}
 	}
 
 	return insertQuery.Create(data)
 }
 
+func upsertClause(createOptions *CreateOptions) clause.OnConflict {
+	onConflict := clause.OnConflict{UpdateAll: true}
+	for _, column := range createOptions.ConflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if createOptions.ConflictWhere != nil {
+		onConflict.TargetWhere = clause.Where{
+			Exprs: []clause.Expression{
+				clause.Expr{SQL: createOptions.ConflictWhere.Query, Vars: createOptions.ConflictWhere.Args},
+			},
+		}
+	}
+	return onConflict
+}
+
 func (s *Service[T]) UpdateTx(tx *DB, data *T, updateOptions ...*UpdateOptions) *DB {
 	docStruct := new(T)
 
@@ -314,10 +479,37 @@ func (s *Service[T]) UpdateTx(tx *DB, data *T, updateOptions ...*UpdateOptions)
 			updateQuery = updateQuery.Unscoped()
 		}
 	}
+	if len(updateOptions) > 0 && updateOptions[0].Columns != nil {
+		updateQuery = updateQuery.Select(*updateOptions[0].Columns)
+	}
 
 	return updateQuery.Updates(data)
 }
 
+// UpdateExprTx sets each column of exprs to the result of its SQL expression, such as one computed from the other
+// columns of the row, which cannot be written as a struct. The expressions must come from code, never from user input.
+func (s *Service[T]) UpdateExprTx(tx *DB, exprs map[string]Where, updateOptions ...*UpdateOptions) *DB {
+	docStruct := new(T)
+
+	updateQuery := tx.Model(docStruct)
+
+	if len(updateOptions) > 0 && updateOptions[0].Where != nil {
+		for _, where := range *updateOptions[0].Where {
+			updateQuery = updateQuery.Where(where.Query, where.Args...)
+		}
+		if updateOptions[0].IsUnscoped {
+			updateQuery = updateQuery.Unscoped()
+		}
+	}
+
+	values := make(map[string]interface{}, len(exprs))
+	for column, expr := range exprs {
+		values[column] = gorm.Expr(expr.Query, expr.Args...)
+	}
+
+	return updateQuery.Updates(values)
+}
+
 func (s *Service[T]) BulkUpdateTx(tx *DB, data *[]*T, updateOptions ...*UpdateOptions) *DB {
 	docStruct := new(T)
 
@@ -352,6 +544,20 @@ func (s *Service[T]) ReplaceTx(tx *DB, data *T, replaceOptions ...*ReplaceOption
 	return updateQuery.Updates(data)
 }
 
+func (s *Service[T]) RestoreTx(tx *DB, restoreOptions *RestoreOptions) *DB {
+	docStruct := new(T)
+
+	restoreQuery := tx.Model(docStruct).Unscoped().Where("deleted_at IS NOT NULL")
+
+	if restoreOptions != nil && restoreOptions.Where != nil {
+		for _, where := range *restoreOptions.Where {
+			restoreQuery = restoreQuery.Where(where.Query, where.Args...)
+		}
+	}
+
+	return restoreQuery.Update("deleted_at", nil)
+}
+
 func (s *Service[T]) DestroyTx(tx *DB, data *T, destroyOptions ...*DestroyOptions) *DB {
 	deleteQuery := tx
 
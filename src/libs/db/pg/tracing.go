@@ -0,0 +1,83 @@
+package pg
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"hilmy.dev/store/src/libs/tracing"
+)
+
+const tracingSpanKey = "tracing:span"
+
+// tracingPlugin wraps every gorm callback chain in a client span so that each pg.Service call shows up in traces.
+type tracingPlugin struct{}
+
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+func (p tracingPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callback.Create().Before("gorm:create").Register, callback.Create().After("gorm:create").Register},
+		{"query", callback.Query().Before("gorm:query").Register, callback.Query().After("gorm:query").Register},
+		{"update", callback.Update().Before("gorm:update").Register, callback.Update().After("gorm:update").Register},
+		{"delete", callback.Delete().Before("gorm:delete").Register, callback.Delete().After("gorm:delete").Register},
+		{"row", callback.Row().Before("gorm:row").Register, callback.Row().After("gorm:row").Register},
+		{"raw", callback.Raw().Before("gorm:raw").Register, callback.Raw().After("gorm:raw").Register},
+	}
+
+	for _, hook := range hooks {
+		if err := hook.before("tracing:before_"+hook.operation, p.before(hook.operation)); err != nil {
+			return err
+		}
+		if err := hook.after("tracing:after_"+hook.operation, p.after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tracingPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := tracing.Start(db.Statement.Context, "pg."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperation(operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func (tracingPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBSQLTable(db.Statement.Table),
+		semconv.DBStatement(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !IsErrRecordNotFound(db.Error) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
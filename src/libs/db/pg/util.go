@@ -1,7 +1,15 @@
 package pg
 
-import "gorm.io/gorm"
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrRecordNotFound is the error of a write that matched no row, for transactions to add when they check RowsAffected
+// themselves.
+var ErrRecordNotFound = gorm.ErrRecordNotFound
 
 func IsErrRecordNotFound(err error) bool {
-	return err == gorm.ErrRecordNotFound
+	return errors.Is(err, gorm.ErrRecordNotFound)
 }
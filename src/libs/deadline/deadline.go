@@ -0,0 +1,25 @@
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Middleware bounds c.UserContext() by timeout, so that database calls made with it are cancelled once a request
+// has run for too long. fasthttp does not report client disconnects, so this deadline is also what stops work for
+// clients that have gone away. A non-positive timeout disables the deadline.
+func Middleware(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -1,61 +1,267 @@
 package env
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	_ "github.com/joho/godotenv/autoload"
 	applogger "hilmy.dev/store/src/libs/logger"
+	appvalidator "hilmy.dev/store/src/libs/validator"
 )
 
-type Env string
+var logger = applogger.New("Env")
 
-const (
-	APP_NAME    Env = "APP_NAME"
-	APP_MODE    Env = "APP_MODE"
-	APP_ADDRESS Env = "APP_ADDRESS"
+type LoadOptions struct {
+	// File is an optional YAML or TOML file whose top-level keys are variable names.
+	File string
+	// Overrides take precedence over every other source, e.g. values from command line flags.
+	Overrides map[string]string
+	// Sections limits the checks to these top-level fields of target, such as the parts of a configuration that a
+	// command uses. Every field is still loaded. Everything is checked when Sections is empty.
+	Sections []string
+}
 
-	WEB_ADDRESS Env = "WEB_ADDRESS"
+// LoadError aggregates every missing or invalid setting so that they can all be fixed in one go.
+type LoadError struct {
+	Problems []string
+}
 
-	POSTGRES_ADDRESS  Env = "POSTGRES_ADDRESS"
-	POSTGRES_USER     Env = "POSTGRES_USER"
-	POSTGRES_PASSWORD Env = "POSTGRES_PASSWORD"
-	POSTGRES_DB       Env = "POSTGRES_DB"
+func (e *LoadError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
 
-	MONGO_ADDRESS              Env = "MONGO_ADDRESS"
-	MONGO_INITDB_ROOT_USERNAME Env = "MONGO_INITDB_ROOT_USERNAME"
-	MONGO_INITDB_ROOT_PASSWORD Env = "MONGO_INITDB_ROOT_PASSWORD"
-	MONGO_DATABASE_NAME        Env = "MONGO_DATABASE_NAME"
+type field struct {
+	key        string
+	defaultVal *string
+	namespace  string
+	value      reflect.Value
+}
 
-	JWT_DURATION Env = "JWT_DURATION"
+// Load fills the fields of target tagged with `env:"NAME"`. For every variable the first non-empty value wins, in order:
+// overrides, the NAME environment variable (or .env file), the contents of the file at NAME_FILE, the configuration
+// file, and the `default:"..."` tag. The result is then checked against its `validate` tags.
+func Load[T any](target *T, options ...*LoadOptions) error {
+	option := &LoadOptions{}
+	if len(options) > 0 && options[0] != nil {
+		option = options[0]
+	}
 
-	HASH_MEMORY      Env = "HASH_MEMORY"
-	HASH_ITERATIONS  Env = "HASH_ITERATIONS"
-	HASH_PARALLELISM Env = "HASH_PARALLELISM"
-	HASH_SALTLENGTH  Env = "HASH_SALTLENGTH"
-	HASH_KEYLENGTH   Env = "HASH_KEYLENGTH"
+	fileValues := map[string]string{}
+	if len(option.File) > 0 {
+		values, err := readFile(option.File)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+		fileValues = values
+	}
 
-	INITIAL_ACCOUNT_NAME     Env = "INITIAL_ACCOUNT_NAME"
-	INITIAL_ACCOUNT_USERNAME Env = "INITIAL_ACCOUNT_USERNAME"
-	INITIAL_ACCOUNT_PASSWORD Env = "INITIAL_ACCOUNT_PASSWORD"
-	INITIAL_ACCOUNT_ROLE     Env = "INITIAL_ACCOUNT_ROLE"
-)
+	fields := []*field{}
+	value := reflect.ValueOf(target).Elem()
+	collectFields(value, value.Type().Name(), &fields)
 
-var logger = applogger.New("Env")
+	isChecked := func(namespace string) bool {
+		if len(option.Sections) == 0 {
+			return true
+		}
+		for _, section := range option.Sections {
+			if strings.HasPrefix(namespace, value.Type().Name()+"."+section+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	problems := []string{}
+	invalidKeys := map[string]bool{}
+	namespaceKeys := map[string]string{}
+	for _, f := range fields {
+		namespaceKeys[f.namespace] = f.key
 
-type Option struct {
-	MustExist bool
+		raw, err := lookup(f, option.Overrides, fileValues)
+		if err != nil {
+			if isChecked(f.namespace) {
+				problems = append(problems, err.Error())
+			}
+			invalidKeys[f.key] = true
+			continue
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			if isChecked(f.namespace) {
+				problems = append(problems, fmt.Sprintf("%s: invalid value %q: %v", f.key, raw, err))
+			}
+			invalidKeys[f.key] = true
+		}
+	}
+
+	if err := appvalidator.Struct(target); err != nil {
+		validationErrors := validator.ValidationErrors{}
+		if !errors.As(err, &validationErrors) {
+			return err
+		}
+		for _, fieldError := range validationErrors {
+			key, ok := namespaceKeys[fieldError.StructNamespace()]
+			if !ok {
+				key = fieldError.StructNamespace()
+			}
+			// A value that could not be parsed has already been reported.
+			if invalidKeys[key] || !isChecked(fieldError.StructNamespace()) {
+				continue
+			}
+			problems = append(problems, key+" "+describeValidation(fieldError))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &LoadError{Problems: problems}
+	}
+	return nil
 }
 
-func Get(env Env, option ...Option) string {
-	envVal, isExist := os.LookupEnv(string(env))
-	if !isExist {
-		err := fmt.Errorf("unknown env variable: %s", env)
-		if len(option) > 0 && option[0].MustExist {
-			logger.Panic(err)
+// Keys lists the variable names read by Load for target, in declaration order.
+func Keys[T any](target *T) []string {
+	fields := []*field{}
+	value := reflect.ValueOf(target).Elem()
+	collectFields(value, value.Type().Name(), &fields)
+
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+func collectFields(value reflect.Value, namespace string, fields *[]*field) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		structField := valueType.Field(i)
+		if !structField.IsExported() {
+			continue
 		}
-		logger.Error(err)
+		fieldNamespace := namespace + "." + structField.Name
+
+		key, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if structField.Type.Kind() == reflect.Struct {
+				collectFields(value.Field(i), fieldNamespace, fields)
+			}
+			continue
+		}
+
+		f := &field{
+			key:       key,
+			namespace: fieldNamespace,
+			value:     value.Field(i),
+		}
+		if defaultVal, ok := structField.Tag.Lookup("default"); ok {
+			f.defaultVal = &defaultVal
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+func lookup(f *field, overrides map[string]string, fileValues map[string]string) (string, error) {
+	if raw := overrides[f.key]; len(raw) > 0 {
+		return raw, nil
+	}
+	if raw := os.Getenv(f.key); len(raw) > 0 {
+		return raw, nil
+	}
+	if path := os.Getenv(f.key + "_FILE"); len(path) > 0 {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s_FILE: %v", f.key, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	if raw := fileValues[f.key]; len(raw) > 0 {
+		return raw, nil
+	}
+	if f.defaultVal != nil {
+		return *f.defaultVal, nil
+	}
+	return "", nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", value.Type())
+		}
+		items := strings.Split(raw, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
 	}
 
-	return envVal
+	return nil
+}
+
+func describeValidation(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "required_if":
+		field, value, _ := strings.Cut(fieldError.Param(), " ")
+		return "is required when " + field + " is " + value
+	case "oneof":
+		return "must be one of: " + fieldError.Param()
+	case "gte", "min":
+		return "must be at least " + fieldError.Param()
+	case "lte", "max":
+		return "must be at most " + fieldError.Param()
+	default:
+		if len(fieldError.Param()) > 0 {
+			return fmt.Sprintf("failed on the '%s=%s' rule", fieldError.Tag(), fieldError.Param())
+		}
+		return fmt.Sprintf("failed on the '%s' rule", fieldError.Tag())
+	}
 }
@@ -0,0 +1,60 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testConfig struct {
+	App      testAppConfig
+	Postgres testPostgresConfig
+}
+
+type testAppConfig struct {
+	Name string `env:"TEST_APP_NAME" validate:"required"`
+	Mode string `env:"TEST_APP_MODE" default:"DEBUG" validate:"oneof=DEBUG RELEASE"`
+}
+
+type testPostgresConfig struct {
+	User string `env:"TEST_POSTGRES_USER" validate:"required"`
+	Port int    `env:"TEST_POSTGRES_PORT" default:"5432"`
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("TEST_APP_MODE", "OTHER")
+	t.Setenv("TEST_POSTGRES_PORT", "port")
+
+	err := Load(new(testConfig))
+
+	loadError := new(LoadError)
+	if !errors.As(err, &loadError) {
+		t.Fatalf("err = %v, want a LoadError", err)
+	}
+	if len(loadError.Problems) != 4 {
+		t.Errorf("problems = %q, want one for each of the 4 settings", loadError.Problems)
+	}
+}
+
+func TestLoadChecksOnlySections(t *testing.T) {
+	t.Setenv("TEST_POSTGRES_USER", "store")
+	t.Setenv("TEST_APP_MODE", "OTHER")
+
+	config := new(testConfig)
+	if err := Load(config, &LoadOptions{Sections: []string{"Postgres"}}); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := &testConfig{
+		App:      testAppConfig{Mode: "OTHER"},
+		Postgres: testPostgresConfig{User: "store", Port: 5432},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("loaded %+v, want %+v", config, want)
+	}
+
+	t.Setenv("TEST_POSTGRES_USER", "")
+	if err := Load(new(testConfig), &LoadOptions{Sections: []string{"Postgres"}}); err == nil {
+		t.Error("Load succeeded without a required setting of a checked section")
+	}
+}
@@ -0,0 +1,49 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		err = fmt.Errorf("unsupported configuration file type: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch value := value.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+
+	return values, nil
+}
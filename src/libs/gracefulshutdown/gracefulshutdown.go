@@ -1,38 +1,184 @@
 package gracefulshutdown
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	applogger "hilmy.dev/store/src/libs/logger"
 )
 
+type Phase int
+
+// Hooks run phase by phase in ascending order, and in registration order within the same phase.
+const (
+	PHASE_STOP_ACCEPTING Phase = iota
+	PHASE_DRAIN_HTTP
+	PHASE_FLUSH_WORKERS
+	PHASE_CLOSE_DB
+)
+
+const DefaultTimeout = 30 * time.Second
+
 type FnRunInShutdown struct {
 	FnDescription string
-	Fn            func()
+	Phase         Phase
+	Fn            func(ctx context.Context) error
+}
+
+type Config struct {
+	Timeout time.Duration
 }
 
-var fnsRunInShutdown []FnRunInShutdown
+// Manager holds the hooks of one shutdown. The package level functions use a default Manager shared by the whole
+// process, while tests create their own.
+type Manager struct {
+	mu               sync.Mutex
+	fnsRunInShutdown []FnRunInShutdown
+	isShuttingDown   atomic.Bool
+	done             chan struct{}
+	// ctx is cancelled as soon as shutdown begins so that background goroutines can stop picking up new work.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var defaultManager = New()
 var logger = applogger.New("GracefullShutdown")
 
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
 func Add(newFns ...FnRunInShutdown) {
-	fnsRunInShutdown = append(fnsRunInShutdown, newFns...)
+	defaultManager.Add(newFns...)
 }
 
-func Run() {
+func (m *Manager) Add(newFns ...FnRunInShutdown) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fnsRunInShutdown = append(m.fnsRunInShutdown, newFns...)
+}
+
+func Run(config ...*Config) {
+	defaultManager.Run(config...)
+}
+
+func (m *Manager) Run(config ...*Config) {
+	timeout := DefaultTimeout
+	if len(config) > 0 && config[0].Timeout > 0 {
+		timeout = config[0].Timeout
+	}
+
 	logger.Log("listen to shutdown signals")
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGTERM)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	go func() {
-		<-c
-		if len(fnsRunInShutdown) > 0 {
-			logger.Log("start clearing resources")
+		sig := <-c
+		logger.Log("received " + sig.String())
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
+		defer shutdownCancel()
+
+		if err := m.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, &applogger.Options{IsPrintStack: false})
 		}
-		for _, fn := range fnsRunInShutdown {
-			logger.Log(fn.FnDescription)
-			fn.Fn()
+	}()
+}
+
+func Shutdown(shutdownCtx context.Context) error {
+	return defaultManager.Shutdown(shutdownCtx)
+}
+
+// Shutdown runs every registered hook in phase order within the deadline of ctx and returns the joined hook errors.
+// It only runs once; later calls return immediately.
+func (m *Manager) Shutdown(shutdownCtx context.Context) error {
+	if !m.isShuttingDown.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer close(m.done)
+
+	m.cancel()
+
+	m.mu.Lock()
+	fns := make([]FnRunInShutdown, len(m.fnsRunInShutdown))
+	copy(fns, m.fnsRunInShutdown)
+	m.mu.Unlock()
+
+	sort.SliceStable(fns, func(i, j int) bool {
+		return fns[i].Phase < fns[j].Phase
+	})
+
+	if len(fns) > 0 {
+		logger.Log("start clearing resources")
+	}
+
+	errs := []error{}
+	for _, fn := range fns {
+		logger.Log(fn.FnDescription)
+		if err := runWithDeadline(shutdownCtx, fn); err != nil {
+			logger.Error(err, &applogger.Options{IsPrintStack: false})
+			errs = append(errs, err)
 		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runWithDeadline stops waiting on a hook once the deadline passes, even if the hook itself ignores ctx.
+func runWithDeadline(shutdownCtx context.Context, fn FnRunInShutdown) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn.Fn(shutdownCtx)
 	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("%s: %w", fn.FnDescription, err)
+		}
+		return nil
+	case <-shutdownCtx.Done():
+		return fmt.Errorf("%s: %w", fn.FnDescription, shutdownCtx.Err())
+	}
+}
+
+// Context returns a context that is cancelled as soon as shutdown begins.
+func Context() context.Context {
+	return defaultManager.Context()
+}
+
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Wait blocks until every shutdown hook has finished or has been abandoned after the deadline.
+func Wait() {
+	defaultManager.Wait()
+}
+
+func (m *Manager) Wait() {
+	<-m.done
+}
+
+// IsShuttingDown reports whether shutdown has begun.
+func IsShuttingDown() bool {
+	return defaultManager.IsShuttingDown()
+}
+
+func (m *Manager) IsShuttingDown() bool {
+	return m.isShuttingDown.Load()
 }
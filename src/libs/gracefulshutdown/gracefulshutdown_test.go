@@ -0,0 +1,141 @@
+package gracefulshutdown
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsPhasesInOrder(t *testing.T) {
+	m := New()
+
+	var mu sync.Mutex
+	ran := []string{}
+	hook := func(name string, phase Phase) FnRunInShutdown {
+		return FnRunInShutdown{
+			FnDescription: name,
+			Phase:         phase,
+			Fn: func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				ran = append(ran, name)
+				return nil
+			},
+		}
+	}
+	m.Add(
+		hook("close db", PHASE_CLOSE_DB),
+		hook("drain http", PHASE_DRAIN_HTTP),
+		hook("flush workers", PHASE_FLUSH_WORKERS),
+		hook("stop accepting", PHASE_STOP_ACCEPTING),
+		hook("drain http again", PHASE_DRAIN_HTTP),
+	)
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"stop accepting", "drain http", "drain http again", "flush workers", "close db"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestShutdownAbandonsHooksAfterDeadline(t *testing.T) {
+	m := New()
+
+	release := make(chan struct{})
+	defer close(release)
+	m.Add(FnRunInShutdown{
+		FnDescription: "stuck",
+		Phase:         PHASE_DRAIN_HTTP,
+		// Ignores ctx on purpose, which must not hold up the shutdown.
+		Fn: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := m.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown took %v, want it to stop at the deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want it to wrap %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestShutdownJoinsErrors(t *testing.T) {
+	m := New()
+
+	errFirst := errors.New("first")
+	errSecond := errors.New("second")
+	m.Add(
+		FnRunInShutdown{
+			FnDescription: "first",
+			Phase:         PHASE_DRAIN_HTTP,
+			Fn: func(ctx context.Context) error {
+				return errFirst
+			},
+		},
+		FnRunInShutdown{
+			FnDescription: "succeeds",
+			Phase:         PHASE_FLUSH_WORKERS,
+			Fn: func(ctx context.Context) error {
+				return nil
+			},
+		},
+		FnRunInShutdown{
+			FnDescription: "second",
+			Phase:         PHASE_CLOSE_DB,
+			Fn: func(ctx context.Context) error {
+				return errSecond
+			},
+		},
+	)
+
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Errorf("err = %v, want both hook errors", err)
+	}
+}
+
+func TestShutdownRunsOnce(t *testing.T) {
+	m := New()
+
+	calls := 0
+	m.Add(FnRunInShutdown{
+		FnDescription: "count",
+		Fn: func(ctx context.Context) error {
+			calls++
+			return nil
+		},
+	})
+
+	if m.IsShuttingDown() {
+		t.Fatal("IsShuttingDown before Shutdown")
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Wait()
+
+	if calls != 1 {
+		t.Errorf("hook ran %d times, want 1", calls)
+	}
+	if !m.IsShuttingDown() {
+		t.Error("IsShuttingDown after Shutdown")
+	}
+	if m.Context().Err() == nil {
+		t.Error("Context is not cancelled after Shutdown")
+	}
+}
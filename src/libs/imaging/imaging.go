@@ -0,0 +1,160 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+const (
+	CONTENT_TYPE_JPEG = "image/jpeg"
+	CONTENT_TYPE_PNG  = "image/png"
+	CONTENT_TYPE_WEBP = "image/webp"
+)
+
+// MaxPixels bounds the size of a decoded image, so that a small file declaring huge dimensions cannot exhaust memory.
+const MaxPixels = 40_000_000
+
+var ErrUnsupportedFormat = errors.New("unsupported image format, expected JPEG, PNG or WebP")
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+var extensions = map[string]string{
+	CONTENT_TYPE_JPEG: ".jpg",
+	CONTENT_TYPE_PNG:  ".png",
+	CONTENT_TYPE_WEBP: ".webp",
+}
+
+var logger = applogger.New("Imaging")
+
+type Image struct {
+	image.Image
+	// ContentType is sniffed from the data rather than taken from the client.
+	ContentType string
+	Extension   string
+}
+
+// Sniff returns the content type of data when it is a supported image format.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return "", ErrUnsupportedFormat
+	}
+	return contentType, nil
+}
+
+func Decode(data []byte) (*Image, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := decodeConfig(contentType, data)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, err := decode(contentType, data)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return &Image{
+		Image:       img,
+		ContentType: contentType,
+		Extension:   extensions[contentType],
+	}, nil
+}
+
+func decodeConfig(contentType string, data []byte) (image.Config, error) {
+	switch contentType {
+	case CONTENT_TYPE_JPEG:
+		return jpeg.DecodeConfig(bytes.NewReader(data))
+	case CONTENT_TYPE_PNG:
+		return png.DecodeConfig(bytes.NewReader(data))
+	default:
+		return webp.DecodeConfig(bytes.NewReader(data))
+	}
+}
+
+func decode(contentType string, data []byte) (image.Image, error) {
+	switch contentType {
+	case CONTENT_TYPE_JPEG:
+		return jpeg.Decode(bytes.NewReader(data))
+	case CONTENT_TYPE_PNG:
+		return png.Decode(bytes.NewReader(data))
+	default:
+		return webp.Decode(bytes.NewReader(data))
+	}
+}
+
+// Fit scales img down, keeping its aspect ratio, so that neither side exceeds maxSize. Smaller images are returned as
+// they are.
+func Fit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// VARIANT_JPEG_QUALITY is the quality the resized variants of opaque images are encoded at.
+const VARIANT_JPEG_QUALITY = 80
+
+// Variant is a resized copy of an uploaded image, encoded for serving.
+type Variant struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// EncodeVariant encodes a resized copy of an image. The WebP encoder is lossless only, which makes photos larger than
+// their upload, so opaque images are encoded as JPEG instead, and only images with transparency as WebP.
+func EncodeVariant(img image.Image) (*Variant, error) {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		buffer := new(bytes.Buffer)
+		if err := jpeg.Encode(buffer, img, &jpeg.Options{Quality: VARIANT_JPEG_QUALITY}); err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		return &Variant{Data: buffer.Bytes(), ContentType: CONTENT_TYPE_JPEG, Extension: extensions[CONTENT_TYPE_JPEG]}, nil
+	}
+
+	data, err := EncodeWebP(img)
+	if err != nil {
+		return nil, err
+	}
+	return &Variant{Data: data, ContentType: CONTENT_TYPE_WEBP, Extension: extensions[CONTENT_TYPE_WEBP]}, nil
+}
+
+// EncodeWebP encodes img as a lossless WebP image.
+func EncodeWebP(img image.Image) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := nativewebp.Encode(buffer, img, nil); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
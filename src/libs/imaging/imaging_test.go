@@ -0,0 +1,198 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func newTestImage(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	buffer := new(bytes.Buffer)
+	if err := png.Encode(buffer, img); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestDecodeSniffsFormat(t *testing.T) {
+	img := newTestImage(4, 3)
+
+	jpegData := new(bytes.Buffer)
+	if err := jpeg.Encode(jpegData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	webpData, err := EncodeWebP(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		extension   string
+	}{
+		{"png", encodePNG(t, img), CONTENT_TYPE_PNG, ".png"},
+		{"jpeg", jpegData.Bytes(), CONTENT_TYPE_JPEG, ".jpg"},
+		{"webp", webpData, CONTENT_TYPE_WEBP, ".webp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := Decode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.ContentType != tt.contentType || decoded.Extension != tt.extension {
+				t.Errorf("decoded as %s (%s), want %s (%s)", decoded.ContentType, decoded.Extension, tt.contentType, tt.extension)
+			}
+			if size := decoded.Bounds().Size(); size != image.Pt(4, 3) {
+				t.Errorf("decoded size %v, want 4x3", size)
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsUnsupportedData(t *testing.T) {
+	gifData := new(bytes.Buffer)
+	if err := gif.Encode(gifData, newTestImage(2, 2), nil); err != nil {
+		t.Fatal(err)
+	}
+	pngData := encodePNG(t, newTestImage(2, 2))
+
+	tests := map[string][]byte{
+		"gif":           gifData.Bytes(),
+		"text":          []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+		"empty":         {},
+		"truncated png": pngData[:len(pngData)/2],
+	}
+	for name, data := range tests {
+		if _, err := Decode(data); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrUnsupportedFormat)
+		}
+	}
+}
+
+func TestDecodeRejectsTooManyPixels(t *testing.T) {
+	data := encodePNG(t, newTestImage(1, 1))
+
+	// Declare a 10000x5000 image in the IHDR chunk, which starts right after the 8 byte signature, without the data to
+	// back it.
+	binary.BigEndian.PutUint32(data[16:20], 10000)
+	binary.BigEndian.PutUint32(data[20:24], 5000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Decode(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("err = %v, want %v", err, ErrTooManyPixels)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		want   image.Point
+	}{
+		{"landscape", 400, 200, image.Pt(100, 50)},
+		{"portrait", 200, 400, image.Pt(50, 100)},
+		{"square", 300, 300, image.Pt(100, 100)},
+		{"thin", 1000, 5, image.Pt(100, 1)},
+		{"smaller", 80, 60, image.Pt(80, 60)},
+		{"exact", 100, 40, image.Pt(100, 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newTestImage(tt.width, tt.height)
+			fitted := Fit(img, 100)
+			if size := fitted.Bounds().Size(); size != tt.want {
+				t.Errorf("size %v, want %v", size, tt.want)
+			}
+			if tt.width <= 100 && tt.height <= 100 && fitted != image.Image(img) {
+				t.Error("an image that already fits was scaled")
+			}
+		})
+	}
+}
+
+// newTestPhoto draws smooth shading with fine grain, which compresses like a photo rather than like a flat graphic.
+func newTestPhoto(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	seed := uint32(1)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			seed = seed*1664525 + 1013904223
+			grain := int(seed>>28) - 8
+			img.Set(x, y, color.NRGBA{
+				R: uint8(min(255, max(0, x*255/width+grain))),
+				G: uint8(min(255, max(0, y*255/height+grain))),
+				B: uint8(min(255, max(0, (x+y)*255/(width+height)+grain))),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+func TestEncodeVariantIsSmallerThanAPhoto(t *testing.T) {
+	original := new(bytes.Buffer)
+	if err := jpeg.Encode(original, newTestPhoto(1600, 1200), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := Decode(original.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{320, 960} {
+		variant, err := EncodeVariant(Fit(img, size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if variant.ContentType != CONTENT_TYPE_JPEG || variant.Extension != ".jpg" {
+			t.Errorf("size %d: got %s %s, want a JPEG", size, variant.ContentType, variant.Extension)
+		}
+		if len(variant.Data) >= original.Len() {
+			t.Errorf("size %d: the variant is %d bytes, want less than the %d of the original", size, len(variant.Data), original.Len())
+		}
+		if _, err := Decode(variant.Data); err != nil {
+			t.Errorf("size %d: %v", size, err)
+		}
+	}
+}
+
+func TestEncodeVariantKeepsTransparency(t *testing.T) {
+	img := newTestImage(40, 30)
+	img.Set(0, 0, color.NRGBA{A: 0})
+
+	variant, err := EncodeVariant(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variant.ContentType != CONTENT_TYPE_WEBP || variant.Extension != ".webp" {
+		t.Fatalf("got %s %s, want a WebP", variant.ContentType, variant.Extension)
+	}
+	decoded, err := Decode(variant.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := decoded.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("alpha = %d, want the transparent pixel to stay transparent", a)
+	}
+}
@@ -20,6 +20,9 @@ type jwtConf struct {
 type Config struct {
 	Bits     int
 	Duration *time.Duration `validate:"required"`
+	// PrivateKeyPEM is an optional RSA private key. Without it a new key is generated on every start,
+	// which invalidates issued tokens and can't be shared between replicas.
+	PrivateKeyPEM []byte
 }
 
 func Init(config *Config) {
@@ -33,7 +36,13 @@ func Init(config *Config) {
 		config.Bits = 2048
 	}
 
-	privKey, err := rsa.GenerateKey(rand.Reader, config.Bits)
+	var privKey *rsa.PrivateKey
+	var err error
+	if len(config.PrivateKeyPEM) > 0 {
+		privKey, err = parsePrivateKeyPEM(config.PrivateKeyPEM)
+	} else {
+		privKey, err = rsa.GenerateKey(rand.Reader, config.Bits)
+	}
 	if err != nil {
 		logger.Panic(err)
 	}
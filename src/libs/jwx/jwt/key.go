@@ -0,0 +1,57 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+func GeneratePrivateKeyPEM(bits int) ([]byte, error) {
+	if bits == 0 {
+		bits = 2048
+	}
+
+	privKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privKey),
+	}), nil
+}
+
+func parsePrivateKeyPEM(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		err := errors.New("invalid PEM encoded private key")
+		logger.Error(err)
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		privKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			err := errors.New("private key is not an RSA key")
+			logger.Error(err)
+			return nil, err
+		}
+		return privKey, nil
+	default:
+		err := errors.New("unsupported private key type: " + block.Type)
+		logger.Error(err)
+		return nil, err
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"hilmy.dev/store/src/libs/gracefulshutdown"
+)
+
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// Serve exposes the metrics endpoint on a dedicated admin listener so it is never reachable from the public address.
+func Serve(address string) {
+	logger.Log("serving metrics on " + address)
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Get("/metrics", Handler())
+
+	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
+		FnDescription: "shutting down metrics listener",
+		Phase:         gracefulshutdown.PHASE_DRAIN_HTTP,
+		Fn:            app.ShutdownWithContext,
+	})
+
+	go func() {
+		if err := app.Listen(address); err != nil {
+			logger.Error(err)
+		}
+	}()
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHandlerExposesRegisteredMetrics(t *testing.T) {
+	counter := NewCounter("handler_test_total", "Counts nothing, for the handler test.")
+	counter.Add(3)
+
+	app := fiber.New()
+	app.Get("/metrics", Handler())
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"store_handler_test_total 3", "go_goroutines", "process_start_time_seconds"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+}
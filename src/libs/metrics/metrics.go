@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+const namespace = "store"
+
+var registry = prometheus.NewRegistry()
+var factory = promauto.With(registry)
+var logger = applogger.New("Metrics")
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func Register(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := registry.Register(c); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func NewCounter(name string, help string) prometheus.Counter {
+	return factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	})
+}
+
+func NewCounterVec(name string, help string, labels ...string) *prometheus.CounterVec {
+	return factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, labels)
+}
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	return factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+		Buckets:   buckets,
+	}, labels)
+}
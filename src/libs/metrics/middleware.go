@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var httpRequestsTotal = NewCounterVec(
+	"http_requests_total",
+	"Total number of HTTP requests by method, route and status.",
+	"method", "route", "status",
+)
+
+var httpRequestDuration = NewHistogramVec(
+	"http_request_duration_seconds",
+	"HTTP request latency by method, route and status.",
+	prometheus.DefBuckets,
+	"method", "route", "status",
+)
+
+// Middleware takes errorStatus to tell the status of a failed request, since the ErrorHandler that writes the
+// response only runs once every middleware has returned.
+func Middleware(errorStatus func(err error) int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = errorStatus(err)
+		}
+
+		// Use the registered route pattern so that path parameters don't explode the label cardinality.
+		route := c.Route().Path
+		if status == fiber.StatusNotFound && route == "/" && c.Path() != "/" {
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{
+			"method": c.Method(),
+			"route":  route,
+			"status": strconv.Itoa(status),
+		}
+		httpRequestsTotal.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
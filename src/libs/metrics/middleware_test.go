@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var errNotFound = errors.New("not found")
+
+func TestMiddlewareCountsErrorStatus(t *testing.T) {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.SendStatus(fiber.StatusNotFound)
+		},
+	})
+	app.Use(Middleware(func(err error) int {
+		if errors.Is(err, errNotFound) {
+			return fiber.StatusNotFound
+		}
+		return fiber.StatusInternalServerError
+	}))
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		return errNotFound
+	})
+
+	labels := prometheus.Labels{"method": fiber.MethodGet, "route": "/items/:id", "status": "404"}
+	before := testutil.ToFloat64(httpRequestsTotal.With(labels))
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/items/1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("status = %d, want %d", res.StatusCode, fiber.StatusNotFound)
+	}
+
+	if got := testutil.ToFloat64(httpRequestsTotal.With(labels)) - before; got != 1 {
+		t.Errorf("404 requests counted = %v, want 1", got)
+	}
+	internalLabels := prometheus.Labels{"method": fiber.MethodGet, "route": "/items/:id", "status": "500"}
+	if got := testutil.ToFloat64(httpRequestsTotal.With(internalLabels)); got != 0 {
+		t.Errorf("500 requests counted = %v, want 0", got)
+	}
+}
+
+func TestMiddlewareLabelsUnmatchedRoutes(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware(func(err error) int {
+		fiberError := new(fiber.Error)
+		if errors.As(err, &fiberError) {
+			return fiberError.Code
+		}
+		return fiber.StatusInternalServerError
+	}))
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	okLabels := prometheus.Labels{"method": fiber.MethodGet, "route": "/items/:id", "status": "200"}
+	unmatchedLabels := prometheus.Labels{"method": fiber.MethodGet, "route": "unmatched", "status": "404"}
+	okBefore := testutil.ToFloat64(httpRequestsTotal.With(okLabels))
+	unmatchedBefore := testutil.ToFloat64(httpRequestsTotal.With(unmatchedLabels))
+
+	for _, path := range []string{"/items/1", "/items/2", "/random/1", "/random/2"} {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := testutil.ToFloat64(httpRequestsTotal.With(okLabels)) - okBefore; got != 2 {
+		t.Errorf("requests counted under the route pattern = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequestsTotal.With(unmatchedLabels)) - unmatchedBefore; got != 2 {
+		t.Errorf("requests counted as unmatched = %v, want 2", got)
+	}
+}
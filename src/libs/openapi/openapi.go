@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/contracts"
+	"hilmy.dev/store/src/libs/parser"
+)
+
+// Operation documents a route. Params, Query, Body, Form and Response take a zero value of the struct the handler
+// parses or returns, and the spec is read from its json, params, query, form and validate tags.
+type Operation struct {
+	Summary string
+	Tags    []string
+	// IsAuth marks routes that read the bearer token. Roles lists the roles let through by AuthGuard, if any.
+	IsAuth bool
+	Roles  []string
+	Params interface{}
+	Query  interface{}
+	// ListQuery documents the filter[...] and sort parameters accepted by parser.ParseReqListQuery.
+	ListQuery parser.QueryFields
+	Body      interface{}
+	Form      interface{}
+	// Status is the status of a successful response and defaults to 200.
+	Status   int
+	Response interface{}
+	IsList   bool
+	// Facets documents the facets returned next to a list.
+	Facets interface{}
+}
+
+type route struct {
+	method string
+	path   string
+}
+
+var operations = map[route]*Operation{}
+var mu sync.Mutex
+
+var pathParamRegexp = regexp.MustCompile(`:(\w+)\??`)
+
+// Route registers handlers on app for method and path, where path uses fiber's syntax, and documents the route with
+// operation, so that a route cannot be added without being documented.
+func Route(app *fiber.App, method string, path string, operation *Operation, handlers ...fiber.Handler) {
+	mu.Lock()
+	operations[route{method: method, path: path}] = operation
+	mu.Unlock()
+
+	app.Add(method, path, handlers...)
+}
+
+// Check reports the routes under prefix that are registered in app without Route, and the documented routes that
+// are not registered, so that the spec cannot silently drift from the code.
+func Check(app *fiber.App, prefix string, ignoredPaths ...string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registered := map[route]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead || !strings.HasPrefix(r.Path, prefix) || isIgnored(r.Path, ignoredPaths) {
+			continue
+		}
+		registered[route{method: r.Method, path: r.Path}] = true
+	}
+
+	problems := []string{}
+	for r := range registered {
+		if _, ok := operations[r]; !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is not documented", r.method, r.path))
+		}
+	}
+	for r := range operations {
+		if !registered[r] {
+			problems = append(problems, fmt.Sprintf("%s %s is documented but not registered", r.method, r.path))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New("openapi spec does not match the routes:\n  " + strings.Join(problems, "\n  "))
+}
+
+func isIgnored(path string, ignoredPaths []string) bool {
+	for _, ignoredPath := range ignoredPaths {
+		if strings.HasPrefix(path, ignoredPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build assembles the document from every operation added so far.
+func Build(info *Info) *Document {
+	mu.Lock()
+	defer mu.Unlock()
+
+	builder := newSchemaBuilder()
+	builder.components["Error"] = builder.structSchema(reflect.TypeOf(contracts.Error{}), "json")
+	builder.components["Pagination"] = builder.structSchema(reflect.TypeOf(contracts.Pagination{}), "json")
+
+	document := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]*OperationObject{},
+		Components: &Components{
+			Schemas: builder.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for r, operation := range operations {
+		path := pathParamRegexp.ReplaceAllString(r.path, "{$1}")
+		if document.Paths[path] == nil {
+			document.Paths[path] = map[string]*OperationObject{}
+		}
+		document.Paths[path][strings.ToLower(r.method)] = buildOperation(builder, r, operation)
+	}
+
+	return document
+}
+
+func buildOperation(builder *schemaBuilder, r route, operation *Operation) *OperationObject {
+	operationObject := &OperationObject{
+		Summary:     operation.Summary,
+		Tags:        operation.Tags,
+		OperationID: operationID(r),
+		Responses:   map[string]*Response{},
+	}
+
+	if operation.Params != nil {
+		operationObject.Parameters = append(operationObject.Parameters, parameters(builder, operation.Params, "params", "path")...)
+	}
+	if operation.Query != nil {
+		operationObject.Parameters = append(operationObject.Parameters, parameters(builder, operation.Query, "query", "query")...)
+	}
+	if operation.ListQuery != nil {
+		operationObject.Parameters = append(operationObject.Parameters, listQueryParameters(operation.ListQuery)...)
+	}
+
+	if operation.Body != nil {
+		operationObject.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				fiber.MIMEApplicationJSON: {Schema: builder.schema(reflect.TypeOf(operation.Body))},
+			},
+		}
+	}
+	if operation.Form != nil {
+		operationObject.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				fiber.MIMEMultipartForm: {Schema: builder.structSchema(reflect.TypeOf(operation.Form), "form")},
+			},
+		}
+	}
+
+	if operation.IsAuth || len(operation.Roles) > 0 {
+		operationObject.Security = []map[string][]string{{"bearer": {}}}
+		if len(operation.Roles) > 0 {
+			operationObject.Description = "Requires one of the roles: " + strings.Join(operation.Roles, ", ") + "."
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = fiber.StatusOK
+	}
+	operationObject.Responses[fmt.Sprint(status)] = &Response{
+		Description: fiber.NewError(status).Error(),
+		Content: map[string]*MediaType{
+			fiber.MIMEApplicationJSON: {Schema: responseSchema(builder, operation)},
+		},
+	}
+	operationObject.Responses["default"] = &Response{
+		Description: "Error",
+		Content: map[string]*MediaType{
+			fiber.MIMEApplicationJSON: {Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"error": {Ref: "#/components/schemas/Error"},
+				},
+			}},
+		},
+	}
+
+	return operationObject
+}
+
+func responseSchema(builder *schemaBuilder, operation *Operation) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if operation.Response != nil {
+		data := builder.schema(reflect.TypeOf(operation.Response))
+		if operation.IsList {
+			data = &Schema{Type: "array", Items: data}
+		}
+		schema.Properties["data"] = data
+	}
+	if operation.IsList {
+		schema.Properties["pagination"] = &Schema{Ref: "#/components/schemas/Pagination"}
+	}
+	if operation.Facets != nil {
+		schema.Properties["facets"] = builder.schema(reflect.TypeOf(operation.Facets))
+	}
+	return schema
+}
+
+func parameters(builder *schemaBuilder, v interface{}, tag string, in string) []*Parameter {
+	schema := builder.structSchema(reflect.TypeOf(v), tag)
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	result := make([]*Parameter, 0, len(names))
+	for _, name := range names {
+		result = append(result, &Parameter{
+			Name:     name,
+			In:       in,
+			Required: in == "path" || required[name],
+			Schema:   schema.Properties[name],
+		})
+	}
+	return result
+}
+
+var fieldTypeSchemas = map[parser.FieldType]Schema{
+	parser.FIELD_TYPE_STRING: {Type: "string"},
+	parser.FIELD_TYPE_INT:    {Type: "integer"},
+	parser.FIELD_TYPE_UUID:   {Type: "string", Format: "uuid"},
+	parser.FIELD_TYPE_TIME:   {Type: "string", Format: "date-time"},
+}
+
+func listQueryParameters(fields parser.QueryFields) []*Parameter {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []*Parameter{}
+	sortable := []string{}
+	for _, name := range names {
+		field := fields[name]
+		if field.IsSortable {
+			sortable = append(sortable, name)
+		}
+		for _, operator := range field.Operators {
+			schema := fieldTypeSchemas[field.Type]
+			description := ""
+			if operator == parser.OPERATOR_IN {
+				schema = Schema{Type: "string"}
+				description = "Comma separated list of values."
+			}
+			result = append(result, &Parameter{
+				Name:        fmt.Sprintf("filter[%s][%s]", name, operator),
+				In:          "query",
+				Description: description,
+				Schema:      &schema,
+			})
+		}
+	}
+
+	if len(sortable) > 0 {
+		result = append(result, &Parameter{
+			Name:        "sort",
+			In:          "query",
+			Description: "Comma separated fields to sort by, each prefixed with - for descending order. Sortable fields: " + strings.Join(sortable, ", ") + ".",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+
+	return result
+}
+
+// operationID turns "POST /api/v1/transaction/:id/pay" into "postTransactionIdPay".
+func operationID(r route) string {
+	id := strings.ToLower(r.method)
+	for _, part := range strings.FieldsFunc(r.path, func(c rune) bool {
+		return c == '/' || c == '-' || c == '_' || c == ':' || c == '?'
+	}) {
+		if part == "api" || part == "v1" {
+			continue
+		}
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
@@ -0,0 +1,253 @@
+package openapi
+
+import (
+	"encoding/json"
+	"go/token"
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	deletedAtType  = reflect.TypeOf(gorm.DeletedAt{})
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaBuilder turns Go types into schemas. Exported named structs become shared components, while the unexported
+// request and response structs of modules are inlined where they are used.
+type schemaBuilder struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+	// Types with their own JSON encoding, such as datatypes.JSON, may encode to anything.
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if !token.IsExported(t.Name()) {
+			return b.structSchema(t, "json")
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, ok := b.components[name]; ok {
+		name = strings.ReplaceAll(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:], "_", "") + name
+	}
+	b.names[t] = name
+	// Reserve the name before descending, so self-referencing types end in a $ref instead of recursing forever.
+	b.components[name] = &Schema{}
+	*b.components[name] = *b.structSchema(t, "json")
+
+	return name
+}
+
+// structSchema describes the fields of t that are named by tag, flattening embedded structs the way encoding/json
+// and fiber's parsers do.
+func (b *schemaBuilder) structSchema(t reflect.Type, tag string) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range fields(t, tag) {
+		fieldSchema := b.schema(field.Type)
+		if applyValidation(fieldSchema, field.Type, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, field.name)
+		}
+		schema.Properties[field.name] = fieldSchema
+	}
+	sort.Strings(schema.Required)
+
+	return schema
+}
+
+type namedField struct {
+	reflect.StructField
+	name string
+}
+
+func fields(t reflect.Type, tag string) []namedField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	result := []namedField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+
+		if field.Anonymous && len(name) == 0 {
+			result = append(result, fields(field.Type, tag)...)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			if tag != "json" {
+				continue
+			}
+			name = field.Name
+		}
+		result = append(result, namedField{StructField: field, name: name})
+	}
+
+	return result
+}
+
+// applyValidation copies the validate rules that have an OpenAPI equivalent onto schema and reports whether the
+// field is required. Rules after dive apply to elements and are left out.
+func applyValidation(schema *Schema, t reflect.Type, rules string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	isRequired := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return isRequired
+		case "required":
+			isRequired = true
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "email":
+			schema.Format = "email"
+		case "uuid":
+			schema.Format = "uuid"
+		case "url":
+			schema.Format = "uri"
+		case "gt", "gte", "min", "lt", "lte", "max", "len":
+			value, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(schema, t, name, value)
+		}
+	}
+
+	return isRequired
+}
+
+func applyBound(schema *Schema, t reflect.Type, rule string, value float64) {
+	isLower := rule == "gt" || rule == "gte" || rule == "min" || rule == "len"
+	isUpper := rule == "lt" || rule == "lte" || rule == "max" || rule == "len"
+
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		// For lengths, gt=0 means at least one, so exclusive bounds become inclusive ones.
+		lower := int(value)
+		if rule == "gt" {
+			lower++
+		}
+		upper := int(value)
+		if rule == "lt" {
+			upper--
+		}
+
+		if t.Kind() == reflect.String {
+			if isLower {
+				schema.MinLength = &lower
+			}
+			if isUpper {
+				schema.MaxLength = &upper
+			}
+		} else {
+			if isLower {
+				schema.MinItems = &lower
+			}
+			if isUpper {
+				schema.MaxItems = &upper
+			}
+		}
+	default:
+		if isLower {
+			schema.Minimum = &value
+			schema.ExclusiveMinimum = rule == "gt"
+		}
+		if isUpper {
+			schema.Maximum = &value
+			schema.ExclusiveMaximum = rule == "lt"
+		}
+	}
+}
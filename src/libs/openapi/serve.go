@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	swaggerfiles "github.com/swaggo/files/v2"
+)
+
+type Config struct {
+	Info *Info
+	// Path serves the document as JSON.
+	Path string
+	// UIPath serves Swagger UI for the document when set.
+	UIPath string
+}
+
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "{{path}}",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// Serve registers the routes for the document and, optionally, Swagger UI. The document is built on the first
+// request, after every module has added its operations.
+func Serve(app *fiber.App, config *Config) {
+	var document *Document
+	var once sync.Once
+
+	app.Get(config.Path, func(c *fiber.Ctx) error {
+		once.Do(func() {
+			document = Build(config.Info)
+		})
+		return c.JSON(document)
+	})
+
+	if len(config.UIPath) == 0 {
+		return
+	}
+
+	initializer := strings.Replace(swaggerInitializer, "{{path}}", config.Path, 1)
+	app.Get(config.UIPath+"/swagger-initializer.js", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "text/javascript; charset=utf-8")
+		return c.SendString(initializer)
+	})
+	app.Use(config.UIPath, filesystem.New(filesystem.Config{
+		Root:   http.FS(swaggerfiles.FS),
+		Index:  "index.html",
+		Browse: false,
+	}))
+}
@@ -1,23 +1,23 @@
 package parser
 
 import (
-	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/apperror"
 )
 
 func GetReqBearerToken(c *fiber.Ctx) (*string, error) {
 	authorizationHeader := c.Get("authorization")
 	if len(authorizationHeader) == 0 {
-		err := errors.New("authorization header not found")
+		err := apperror.Unauthorized("authorization header not found")
 		logger.Error(err)
 		return nil, err
 	}
 
 	authorization := strings.Split(authorizationHeader, " ")
 	if strings.ToLower(authorization[0]) != "bearer" {
-		err := errors.New("not a bearer token")
+		err := apperror.Unauthorized("not a bearer token")
 		logger.Error(err)
 		return nil, err
 	}
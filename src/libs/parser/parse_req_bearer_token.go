@@ -2,6 +2,7 @@ package parser
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/jwx/jwt"
 )
 
@@ -12,7 +13,7 @@ func ParseReqBearerToken[T any](c *fiber.Ctx, tokenData *T) error {
 	}
 
 	if err := jwt.Parse(*token, tokenData); err != nil {
-		return err
+		return apperror.Wrap(apperror.KIND_UNAUTHORIZED, "invalid token", err)
 	}
 
 	return nil
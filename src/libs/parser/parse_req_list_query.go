@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+type FieldType int
+
+const (
+	FIELD_TYPE_STRING FieldType = iota
+	FIELD_TYPE_INT
+	FIELD_TYPE_UUID
+	FIELD_TYPE_TIME
+)
+
+type Operator string
+
+const (
+	OPERATOR_EQ       Operator = "eq"
+	OPERATOR_NE       Operator = "ne"
+	OPERATOR_GT       Operator = "gt"
+	OPERATOR_GTE      Operator = "gte"
+	OPERATOR_LT       Operator = "lt"
+	OPERATOR_LTE      Operator = "lte"
+	OPERATOR_IN       Operator = "in"
+	OPERATOR_CONTAINS Operator = "contains"
+)
+
+// QueryField whitelists one filterable or sortable field. Column is only ever taken from here, never from the request.
+type QueryField struct {
+	Column     string
+	Type       FieldType
+	Operators  []Operator
+	IsSortable bool
+	// IsReversed sorts descending unless prefixed with -, for sort-only names such as newest.
+	IsReversed bool
+}
+
+// QueryFields maps the field names accepted in filter[...] and sort to their columns.
+type QueryFields map[string]QueryField
+
+type ListQuery struct {
+	Where *[]pg.FindAllWhere
+	// Keyset is set when sorting by a single field, so that cursor pagination stays available. Sorting by several
+	// fields falls back to Order.
+	Keyset *pg.Keyset
+	Order  *[]string
+}
+
+const maxFilterValues = 100
+
+var filterKeyRegexp = regexp.MustCompile(`^filter\[(\w+)\](?:\[(\w+)\])?$`)
+
+var operatorSQL = map[Operator]string{
+	OPERATOR_EQ:       "= ?",
+	OPERATOR_NE:       "<> ?",
+	OPERATOR_GT:       "> ?",
+	OPERATOR_GTE:      ">= ?",
+	OPERATOR_LT:       "< ?",
+	OPERATOR_LTE:      "<= ?",
+	OPERATOR_IN:       "IN ?",
+	OPERATOR_CONTAINS: "ILIKE ?",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ParseReqListQuery turns ?filter[price][gte]=100&sort=-created_at,title into conditions and an order over fields.
+// Values are always passed as query arguments. defaultSort uses the same syntax as the sort parameter.
+func ParseReqListQuery(c *fiber.Ctx, fields QueryFields, defaultSort string) (*ListQuery, error) {
+	where := []pg.FindAllWhere{}
+
+	var err error
+	c.Context().QueryArgs().VisitAll(func(key []byte, value []byte) {
+		if err != nil {
+			return
+		}
+		matches := filterKeyRegexp.FindStringSubmatch(string(key))
+		if matches == nil {
+			if strings.HasPrefix(string(key), "filter") {
+				err = fmt.Errorf("malformed filter %q", key)
+			}
+			return
+		}
+
+		var condition *pg.Where
+		condition, err = parseFilter(fields, matches[1], Operator(matches[2]), string(value))
+		if err != nil {
+			return
+		}
+		where = append(where, pg.FindAllWhere{
+			Where:          *condition,
+			IncludeInCount: true,
+		})
+	})
+	if err != nil {
+		logger.Error(err)
+		return nil, apperror.Malformed(err)
+	}
+
+	sort := c.Query("sort", defaultSort)
+	listQuery, err := parseSort(fields, sort)
+	if err != nil {
+		logger.Error(err)
+		return nil, apperror.Malformed(err)
+	}
+	listQuery.Where = &where
+
+	return listQuery, nil
+}
+
+func parseFilter(fields QueryFields, name string, operator Operator, value string) (*pg.Where, error) {
+	field, ok := fields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter field %q", name)
+	}
+	if len(operator) == 0 {
+		operator = OPERATOR_EQ
+	}
+
+	isAllowed := false
+	for _, allowed := range field.Operators {
+		if allowed == operator {
+			isAllowed = true
+			break
+		}
+	}
+	if !isAllowed {
+		return nil, fmt.Errorf("operator %q is not allowed on filter field %q", operator, name)
+	}
+
+	switch operator {
+	case OPERATOR_IN:
+		rawValues := strings.Split(value, ",")
+		if len(rawValues) > maxFilterValues {
+			return nil, fmt.Errorf("filter field %q accepts at most %d values", name, maxFilterValues)
+		}
+		values := make([]interface{}, 0, len(rawValues))
+		for _, rawValue := range rawValues {
+			v, err := parseFilterValue(field.Type, strings.TrimSpace(rawValue))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for filter field %q: %w", name, err)
+			}
+			values = append(values, v)
+		}
+		return &pg.Where{Query: field.Column + " " + operatorSQL[operator], Args: []interface{}{values}}, nil
+	case OPERATOR_CONTAINS:
+		if field.Type != FIELD_TYPE_STRING {
+			return nil, fmt.Errorf("operator %q needs a text filter field, %q is not", operator, name)
+		}
+		return &pg.Where{Query: field.Column + " " + operatorSQL[operator], Args: []interface{}{"%" + likeEscaper.Replace(value) + "%"}}, nil
+	default:
+		v, err := parseFilterValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for filter field %q: %w", name, err)
+		}
+		return &pg.Where{Query: field.Column + " " + operatorSQL[operator], Args: []interface{}{v}}, nil
+	}
+}
+
+func parseFilterValue(fieldType FieldType, value string) (interface{}, error) {
+	switch fieldType {
+	case FIELD_TYPE_INT:
+		return strconv.Atoi(value)
+	case FIELD_TYPE_UUID:
+		return uuid.Parse(value)
+	case FIELD_TYPE_TIME:
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, value)
+	default:
+		return value, nil
+	}
+}
+
+func parseSort(fields QueryFields, sort string) (*ListQuery, error) {
+	listQuery := &ListQuery{}
+	if len(sort) == 0 {
+		return listQuery, nil
+	}
+
+	keysets := []pg.Keyset{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(sort, ",") {
+		name = strings.TrimSpace(name)
+		isDesc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := fields[name]
+		if !ok || !field.IsSortable {
+			return nil, fmt.Errorf("unknown sort field %q", name)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		keysets = append(keysets, pg.Keyset{Column: field.Column, IsDesc: isDesc != field.IsReversed})
+	}
+
+	if len(keysets) == 1 {
+		listQuery.Keyset = &keysets[0]
+		return listQuery, nil
+	}
+
+	order := []string{}
+	for _, keyset := range keysets {
+		direction := "asc"
+		if keyset.IsDesc {
+			direction = "desc"
+		}
+		order = append(order, keyset.Column+" "+direction)
+	}
+	// id keeps the order stable between pages when the sort values are equal.
+	order = append(order, "id asc")
+	listQuery.Order = &order
+
+	return listQuery, nil
+}
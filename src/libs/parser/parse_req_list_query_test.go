@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+var testQueryFields = QueryFields{
+	"title":      {Column: "title", Type: FIELD_TYPE_STRING, Operators: []Operator{OPERATOR_EQ, OPERATOR_CONTAINS}, IsSortable: true},
+	"price":      {Column: "price", Type: FIELD_TYPE_INT, Operators: []Operator{OPERATOR_EQ, OPERATOR_GTE, OPERATOR_LT}, IsSortable: true},
+	"categoryId": {Column: "category_id", Type: FIELD_TYPE_UUID, Operators: []Operator{OPERATOR_EQ, OPERATOR_IN}},
+	"newest":     {Column: "created_at", Type: FIELD_TYPE_TIME, IsSortable: true, IsReversed: true},
+}
+
+func TestParseFilter(t *testing.T) {
+	first := uuid.MustParse("6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11")
+	second := uuid.MustParse("0b7e4a52-4f0e-4c0b-8f55-2f6ad4d8c6b2")
+
+	tests := []struct {
+		name     string
+		field    string
+		operator Operator
+		value    string
+		want     *pg.Where
+	}{
+		{
+			name:  "eq by default",
+			field: "price",
+			value: "100",
+			want:  &pg.Where{Query: "price = ?", Args: []interface{}{100}},
+		},
+		{
+			name:     "comparison",
+			field:    "price",
+			operator: OPERATOR_GTE,
+			value:    "100",
+			want:     &pg.Where{Query: "price >= ?", Args: []interface{}{100}},
+		},
+		{
+			name:     "in",
+			field:    "categoryId",
+			operator: OPERATOR_IN,
+			value:    first.String() + ", " + second.String(),
+			want:     &pg.Where{Query: "category_id IN ?", Args: []interface{}{[]interface{}{first, second}}},
+		},
+		{
+			name:     "contains escapes the LIKE wildcards",
+			field:    "title",
+			operator: OPERATOR_CONTAINS,
+			value:    `50%_off\`,
+			want:     &pg.Where{Query: "title ILIKE ?", Args: []interface{}{`%50\%\_off\\%`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilter(testQueryFields, tt.field, tt.operator, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFilter = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejectsInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		operator Operator
+		value    string
+		want     string
+	}{
+		{"unknown field", "password", OPERATOR_EQ, "secret", "unknown filter field"},
+		{"operator not allowed", "price", OPERATOR_CONTAINS, "1", "is not allowed"},
+		{"unknown operator", "price", Operator("like"), "1", "is not allowed"},
+		{"invalid int", "price", OPERATOR_EQ, "cheap", "invalid value"},
+		{"invalid uuid in a list", "categoryId", OPERATOR_IN, "6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11,nope", "invalid value"},
+		{"too many values", "categoryId", OPERATOR_IN, strings.Repeat("x,", maxFilterValues) + "x", "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilter(testQueryFields, tt.field, tt.operator, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one saying %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name       string
+		sort       string
+		wantKeyset *pg.Keyset
+		wantOrder  *[]string
+	}{
+		{name: "none", sort: ""},
+		{name: "single field", sort: "price", wantKeyset: &pg.Keyset{Column: "price"}},
+		{name: "descending", sort: "-price", wantKeyset: &pg.Keyset{Column: "price", IsDesc: true}},
+		{name: "reversed field", sort: "newest", wantKeyset: &pg.Keyset{Column: "created_at", IsDesc: true}},
+		{name: "reversed field descending", sort: "-newest", wantKeyset: &pg.Keyset{Column: "created_at"}},
+		{name: "repeated field", sort: "price,-price", wantKeyset: &pg.Keyset{Column: "price"}},
+		{name: "several fields", sort: "-price, title", wantOrder: &[]string{"price desc", "title asc", "id asc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSort(testQueryFields, tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Keyset, tt.wantKeyset) {
+				t.Errorf("Keyset = %+v, want %+v", got.Keyset, tt.wantKeyset)
+			}
+			if !reflect.DeepEqual(got.Order, tt.wantOrder) {
+				t.Errorf("Order = %v, want %v", got.Order, tt.wantOrder)
+			}
+		})
+	}
+
+	for _, sort := range []string{"password", "categoryId", "price;drop table account"} {
+		if _, err := parseSort(testQueryFields, sort); err == nil {
+			t.Errorf("sort %q was accepted", sort)
+		}
+	}
+}
+
+func TestParseReqListQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		wantWhere int
+		wantOrder *[]string
+		isError   bool
+	}{
+		{name: "default sort", target: "/?filter[price][gte]=100&filter[title]=shirt", wantWhere: 2},
+		{name: "sort", target: "/?sort=-price,title", wantOrder: &[]string{"price desc", "title asc", "id asc"}},
+		{name: "malformed filter", target: "/?filter[price[gte]=100", isError: true},
+		{name: "unknown filter", target: "/?filter[password]=secret", isError: true},
+		{name: "unknown sort", target: "/?sort=password", isError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				listQuery, err := ParseReqListQuery(c, testQueryFields, "-newest")
+				if tt.isError {
+					if err == nil {
+						t.Error("the query was accepted")
+					}
+					return nil
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(*listQuery.Where) != tt.wantWhere {
+					t.Errorf("got %d conditions, want %d", len(*listQuery.Where), tt.wantWhere)
+				}
+				if tt.wantOrder == nil && !reflect.DeepEqual(listQuery.Keyset, &pg.Keyset{Column: "created_at"}) {
+					t.Errorf("Keyset = %+v, want the default sort", listQuery.Keyset)
+				}
+				if !reflect.DeepEqual(listQuery.Order, tt.wantOrder) {
+					t.Errorf("Order = %v, want %v", listQuery.Order, tt.wantOrder)
+				}
+				return nil
+			})
+
+			if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, tt.target, nil)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 
 	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/validator"
 )
 
@@ -11,7 +12,7 @@ func ParseReqMultipartForm[T any](c *fiber.Ctx, req T) error {
 	parsedMultipart, err := c.MultipartForm()
 	if err != nil {
 		logger.Error(err)
-		return err
+		return apperror.Malformed(err)
 	}
 
 	reqValue := reflect.ValueOf(req).Elem()
@@ -0,0 +1,42 @@
+package slug
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Make turns s into a URL slug, e.g. "Café & Bakery" into "cafe-bakery". Accents and apostrophes are dropped, and every
+// other run of characters that are not ASCII letters or digits becomes a single hyphen. The result is empty when s has no letters
+// or digits at all.
+func Make(s string) string {
+	var builder strings.Builder
+	isHyphen := false
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’':
+			continue
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			builder.WriteRune(r)
+			isHyphen = false
+		case 'A' <= r && r <= 'Z':
+			builder.WriteRune(unicode.ToLower(r))
+			isHyphen = false
+		default:
+			if builder.Len() > 0 && !isHyphen {
+				builder.WriteByte('-')
+				isHyphen = true
+			}
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "-")
+}
+
+// IsValid reports whether s is a slug as made by Make.
+func IsValid(s string) bool {
+	return slugRegexp.MatchString(s)
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hilmy.dev/store/src/libs/validator"
+)
+
+type LocalConfig struct {
+	Dir string `validate:"required"`
+	// BaseURL is the URL the directory is served from, e.g. "/uploads" or "https://cdn.example.com".
+	BaseURL string `validate:"required"`
+}
+
+type localStorage struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal stores files in a directory of the local filesystem, which the app or a reverse proxy serves at BaseURL.
+func NewLocal(config *LocalConfig) Storage {
+	logger.Log("initializing local storage")
+
+	if err := validator.Struct(config); err != nil {
+		logger.Panic(err)
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		logger.Panic(err)
+	}
+
+	return &localStorage{
+		dir:     config.Dir,
+		baseURL: strings.TrimSuffix(config.BaseURL, "/"),
+	}
+}
+
+func (s *localStorage) Put(_ context.Context, key string, body []byte, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	// Write to a temporary file first, so that a file is never served half written.
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		logger.Error(err)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		logger.Error(err)
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		os.Remove(f.Name())
+		logger.Error(err)
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStorage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *localStorage) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		err := errors.New("invalid storage key: " + key)
+		logger.Error(err)
+		return "", err
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalPutAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(&LocalConfig{Dir: dir, BaseURL: "/uploads/"})
+	ctx := context.Background()
+	path := filepath.Join(dir, "products", "1", "original.png")
+
+	for _, body := range []string{"first", "second"} {
+		if err := s.Put(ctx, "products/1/original.png", []byte(body), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != body {
+			t.Errorf("file holds %q, want %q", data, body)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the stored one", len(entries))
+	}
+
+	if err := s.Delete(ctx, "products/1/original.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still exists after Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "products/1/original.png"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestLocalRejectsKeysOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(&LocalConfig{Dir: filepath.Join(dir, "uploads"), BaseURL: "/uploads"})
+	ctx := context.Background()
+
+	for _, key := range []string{"../escaped.png", "products/../../escaped.png", "/etc/escaped.png", ""} {
+		if err := s.Put(ctx, key, []byte("data"), "image/png"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.png")); !os.IsNotExist(err) {
+		t.Error("a file was written outside the storage directory")
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	s := NewLocal(&LocalConfig{Dir: t.TempDir(), BaseURL: "https://cdn.example.com/"})
+	if got, want := s.URL("products/1/original.png"), "https://cdn.example.com/products/1/original.png"; got != want {
+		t.Errorf("URL = %s, want %s", got, want)
+	}
+}
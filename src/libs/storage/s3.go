@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"hilmy.dev/store/src/libs/validator"
+)
+
+type S3Config struct {
+	// Endpoint is the base URL of the S3 compatible service, e.g. "https://s3.us-east-1.amazonaws.com" or
+	// "http://localhost:9000" for MinIO.
+	Endpoint  string `validate:"required,url"`
+	Region    string `validate:"required"`
+	Bucket    string `validate:"required"`
+	AccessKey string `validate:"required"`
+	SecretKey string `validate:"required"`
+	// IsPathStyle addresses the bucket as <endpoint>/<bucket> instead of <bucket>.<endpoint host>, which is what most
+	// self-hosted services expect.
+	IsPathStyle bool
+	// BaseURL is the URL files are downloaded from, such as a CDN in front of the bucket. Defaults to the bucket URL.
+	BaseURL string
+}
+
+type s3Storage struct {
+	bucketURL *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+// NewS3 stores files in a bucket of any service that speaks the S3 API, signing requests with AWS Signature
+// Version 4. The bucket should allow public reads of the stored keys, or be fronted by BaseURL.
+func NewS3(config *S3Config) Storage {
+	logger.Log("initializing S3 storage")
+
+	if err := validator.Struct(config); err != nil {
+		logger.Panic(err)
+	}
+
+	bucketURL, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		logger.Panic(err)
+	}
+	if config.IsPathStyle {
+		bucketURL.Path += "/" + config.Bucket
+	} else {
+		bucketURL.Host = config.Bucket + "." + bucketURL.Host
+	}
+
+	baseURL := bucketURL.String()
+	if len(config.BaseURL) > 0 {
+		baseURL = config.BaseURL
+	}
+
+	return &s3Storage{
+		bucketURL: bucketURL,
+		region:    config.Region,
+		accessKey: config.AccessKey,
+		secretKey: config.SecretKey,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		client:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	return s.do(ctx, http.MethodPut, key, body, contentType)
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	// S3 answers 204 whether or not the key existed.
+	return s.do(ctx, http.MethodDelete, key, nil, "")
+}
+
+func (s *s3Storage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *s3Storage) do(ctx context.Context, method string, key string, body []byte, contentType string) error {
+	objectURL := *s.bucketURL
+	objectURL.Path += "/" + key
+	objectURL.RawPath = s.bucketURL.EscapedPath() + "/" + escapePath(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		err := fmt.Errorf("s3 %s %s: %s: %s", method, key, res.Status, bytes.TrimSpace(message))
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// sign adds the Authorization header described in
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (s *s3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// escapePath percent-encodes every byte of key except the unreserved characters and slashes, as S3 expects in the
+// canonical request.
+func escapePath(key string) string {
+	var builder strings.Builder
+	for _, b := range []byte(key) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || b == '/' {
+			builder.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", b)
+	}
+	return builder.String()
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
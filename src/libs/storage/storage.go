@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+// Storage keeps uploaded files under slash separated keys, such as "products/<id>/original.png".
+type Storage interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the file stored under key.
+	URL(key string) string
+}
+
+var logger = applogger.New("Storage")
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware takes errorStatus to tell the status of a failed request, since the ErrorHandler that writes the
+// response only runs once every middleware has returned.
+func Middleware(errorStatus func(err error) int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		reqCarrier := propagation.HeaderCarrier{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			reqCarrier.Set(string(key), string(value))
+		})
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), reqCarrier)
+
+		ctx, span := Start(ctx, "HTTP "+c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.URLScheme(c.Protocol()),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = errorStatus(err)
+			span.RecordError(err)
+		}
+
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPStatusCode(status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fiber.ErrInternalServerError.Error())
+		}
+
+		resCarrier := propagation.HeaderCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, resCarrier)
+		for key := range resCarrier {
+			c.Set(key, resCarrier.Get(key))
+		}
+
+		return err
+	}
+}
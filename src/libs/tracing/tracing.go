@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"hilmy.dev/store/src/libs/gracefulshutdown"
+	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/validator"
+)
+
+const (
+	EXPORTER_NONE   = ""
+	EXPORTER_STDOUT = "STDOUT"
+	EXPORTER_OTLP   = "OTLP"
+)
+
+type Config struct {
+	ServiceName  string `validate:"required"`
+	Exporter     string `validate:"omitempty,oneof=STDOUT OTLP"`
+	OTLPEndpoint string
+	OTLPInsecure bool
+	SampleRatio  float64 `validate:"gte=0,lte=1"`
+}
+
+// The global tracer delegates to whichever provider is installed by Init, so it is safe to use before Init runs.
+var tracer = otel.Tracer("hilmy.dev/store")
+var logger = applogger.New("Tracing")
+
+func Init(config *Config) {
+	logger.Log("initializing tracing")
+
+	if err := validator.Struct(config); err != nil {
+		logger.Panic(err)
+	}
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if config.Exporter == EXPORTER_NONE {
+		logger.Log("no exporter configured, spans will not be exported")
+		return
+	}
+
+	exporter, err := newExporter(config)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+	))
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
+		FnDescription: "flushing and shutting down tracer provider",
+		Phase:         gracefulshutdown.PHASE_FLUSH_WORKERS,
+		Fn:            provider.Shutdown,
+	})
+}
+
+func newExporter(config *Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case EXPORTER_STDOUT:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		// Without an explicit endpoint the exporter falls back to the standard OTEL_EXPORTER_OTLP_* variables.
+		options := []otlptracehttp.Option{}
+		if len(config.OTLPEndpoint) > 0 {
+			options = append(options, otlptracehttp.WithEndpoint(config.OTLPEndpoint))
+		}
+		if config.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), options...)
+	}
+}
+
+func Start(ctx context.Context, spanName string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer.Start(ctx, spanName, options...)
+}
@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	idtranslations "github.com/go-playground/validator/v10/translations/id"
+)
+
+// FieldError describes one failed rule in a machine-readable way. Rule and Param are the validate tag and its
+// parameter, and Message is translated for the client.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+const DefaultLocale = "en"
+
+// Locales lists the languages messages can be translated to, for matching against Accept-Language.
+var Locales = []string{"en", "id"}
+
+var universalTranslator *ut.UniversalTranslator
+
+func init() {
+	enLocale := en.New()
+	universalTranslator = ut.New(enLocale, enLocale, id.New())
+
+	enTranslator, _ := universalTranslator.GetTranslator("en")
+	if err := entranslations.RegisterDefaultTranslations(validate, enTranslator); err != nil {
+		panic(err)
+	}
+	idTranslator, _ := universalTranslator.GetTranslator("id")
+	if err := idtranslations.RegisterDefaultTranslations(validate, idTranslator); err != nil {
+		panic(err)
+	}
+
+	validate.RegisterTagNameFunc(fieldName)
+}
+
+// Locale picks the first supported language in an Accept-Language header, matching on the primary subtag so that
+// en-US selects en.
+func Locale(acceptLanguage string) string {
+	for _, spec := range strings.Split(acceptLanguage, ",") {
+		spec, _, _ = strings.Cut(spec, ";")
+		spec, _, _ = strings.Cut(strings.TrimSpace(spec), "-")
+		spec = strings.ToLower(spec)
+		for _, locale := range Locales {
+			if spec == locale {
+				return locale
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// fieldName reports fields by the name clients send them as rather than by the Go field name.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query", "params", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// FieldErrors translates the failed rules in err for locale. It returns nil when err does not come from validation.
+func FieldErrors(err error, locale string) []FieldError {
+	validationErrors := validator.ValidationErrors{}
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	if len(locale) == 0 {
+		locale = DefaultLocale
+	}
+	translator, _ := universalTranslator.GetTranslator(locale)
+
+	fieldErrors := make([]FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		// The namespace starts with the struct name, which means nothing to clients.
+		_, field, ok := strings.Cut(fieldError.Namespace(), ".")
+		if !ok {
+			field = fieldError.Field()
+		}
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   field,
+			Rule:    fieldError.Tag(),
+			Param:   fieldError.Param(),
+			Message: fieldError.Translate(translator),
+		})
+	}
+
+	return fieldErrors
+}
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type translationTestReq struct {
+	Username *string `json:"username" validate:"required"`
+	Rating   int     `json:"rating" validate:"min=1,max=5"`
+	Page     int     `query:"page" validate:"gte=1"`
+	Address  struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Note string `validate:"max=3"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	req := &translationTestReq{Rating: 6, Note: "long"}
+	err := Struct(req)
+
+	tests := []struct {
+		locale string
+		want   []FieldError
+	}{
+		{
+			locale: "en",
+			want: []FieldError{
+				{Field: "username", Rule: "required", Message: "username is a required field"},
+				{Field: "rating", Rule: "max", Param: "5", Message: "rating must be 5 or less"},
+				{Field: "page", Rule: "gte", Param: "1", Message: "page must be 1 or greater"},
+				{Field: "address.city", Rule: "required", Message: "city is a required field"},
+				{Field: "Note", Rule: "max", Param: "3", Message: "Note must be a maximum of 3 characters in length"},
+			},
+		},
+		{
+			locale: "id",
+			want: []FieldError{
+				{Field: "username", Rule: "required", Message: "username wajib diisi"},
+				{Field: "rating", Rule: "max", Param: "5", Message: "rating harus 5 atau kurang"},
+				{Field: "page", Rule: "gte", Param: "1", Message: "page harus 1 atau lebih besar"},
+				{Field: "address.city", Rule: "required", Message: "city wajib diisi"},
+				{Field: "Note", Rule: "max", Param: "3", Message: "panjang maksimal Note adalah 3 karakter"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			got := FieldErrors(err, tt.locale)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FieldErrors =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := FieldErrors(err, ""), FieldErrors(err, DefaultLocale); !reflect.DeepEqual(got, want) {
+		t.Errorf("an empty locale gave %+v, want the default locale", got)
+	}
+	if got := FieldErrors(errors.New("not a validation error"), "en"); got != nil {
+		t.Errorf("FieldErrors = %+v for a non-validation error, want nil", got)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                          DefaultLocale,
+		"id":                        "id",
+		"ID-id":                     "id",
+		"en-US,en;q=0.9":            "en",
+		"fr-FR, id;q=0.8, en;q=0.5": "id",
+		"fr, de":                    DefaultLocale,
+		"*":                         DefaultLocale,
+	}
+	for acceptLanguage, want := range tests {
+		if got := Locale(acceptLanguage); got != want {
+			t.Errorf("Locale(%q) = %q, want %q", acceptLanguage, got, want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"os"
 	"runtime"
 	"time"
 
@@ -10,20 +12,67 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	fiberlogger "github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"hilmy.dev/store/src/config"
 	"hilmy.dev/store/src/constants"
-	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/env"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/deadline"
 	"hilmy.dev/store/src/libs/gracefulshutdown"
 	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/metrics"
+	"hilmy.dev/store/src/libs/tracing"
+	"hilmy.dev/store/src/modules/log"
 )
 
 var logger = applogger.New("App")
 
 func main() {
-	appName := env.Get(env.APP_NAME)
-	appMode := env.Get(env.APP_MODE)
-	appAddress := env.Get(env.APP_ADDRESS)
-	webAddress := env.Get(env.WEB_ADDRESS)
+	command := "serve"
+	args := []string{}
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+		args = os.Args[2:]
+	}
+
+	switch command {
+	case "jwt":
+		jwtCommand(args)
+		return
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return
+	case "serve", "migrate", "user", "balance", "seed":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Only serve takes configuration flags; the other commands have flags of their own and read CONFIG_FILE instead.
+	if command == "serve" {
+		config.Load(command, args)
+	} else {
+		config.Load(command, nil)
+	}
+
+	switch command {
+	case "serve":
+		serveCommand()
+	case "migrate":
+		migrateCommand(args)
+	case "user":
+		userCommand(args)
+	case "balance":
+		balanceCommand(args)
+	case "seed":
+		seedCommand(args)
+	}
+}
+
+func serveCommand() {
+	conf := config.Get()
+	appName := conf.App.Name
+	appMode := conf.App.Mode
+	appAddress := conf.App.Address
+	webAddress := conf.App.WebAddress
 
 	logger.Log("starting " + appName + " in " + appMode + " on " + runtime.Version())
 
@@ -33,31 +82,20 @@ func main() {
 		JSONEncoder: sonic.Marshal,
 		JSONDecoder: sonic.Unmarshal,
 		ReadTimeout: 30 * time.Second,
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			status := fiber.StatusInternalServerError
-			statusString := fiber.ErrInternalServerError.Error()
-			if err != nil {
-				if fiberError, ok := err.(*fiber.Error); ok {
-					status = fiberError.Code
-					statusString = fiberError.Error()
-				}
-				logger.Error(err, &applogger.Options{IsPrintStack: false})
-				return c.Status(status).JSON(&contracts.Response{
-					Error: &contracts.Error{
-						Status:  statusString,
-						Message: err.Error(),
-					},
-				})
-			}
-			return c.Status(status).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  statusString,
-					Message: "unexpected error occurred",
-				},
-			})
-		},
+		BodyLimit:   conf.App.BodyLimit,
+		ErrorHandler: apperror.Handler(&apperror.Config{
+			IsHideInternal: appMode == constants.APP_MODE_RELEASE,
+			Log:            log.SaveLogService,
+			LogTimeout:     conf.App.RequestTimeout,
+		}),
 	})
 
+	app.Use(tracing.Middleware(apperror.Status))
+
+	app.Use(metrics.Middleware(apperror.Status))
+
+	app.Use(deadline.Middleware(conf.App.RequestTimeout))
+
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: appMode != constants.APP_MODE_RELEASE,
 	}))
@@ -75,7 +113,7 @@ func main() {
 		}(),
 	}))
 
-	if env.Get(env.APP_MODE) != constants.APP_MODE_RELEASE {
+	if appMode != constants.APP_MODE_RELEASE {
 		app.Use(fiberlogger.New())
 	}
 
@@ -84,15 +122,16 @@ func main() {
 
 	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
 		FnDescription: "shutting down app",
-		Fn: func() {
-			if err := app.Shutdown(); err != nil {
-				logger.Error(err)
-			}
-		},
+		Phase:         gracefulshutdown.PHASE_DRAIN_HTTP,
+		Fn:            app.ShutdownWithContext,
+	})
+	gracefulshutdown.Run(&gracefulshutdown.Config{
+		Timeout: conf.App.ShutdownTimeout,
 	})
-	gracefulshutdown.Run()
 
 	if err := app.Listen(appAddress); err != nil {
 		logger.Panic(err)
 	}
+
+	gracefulshutdown.Wait()
 }
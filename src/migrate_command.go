@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/migrations"
+)
+
+const migrateUsage = "usage: store migrate <up [steps] | down [steps] | status>"
+
+func migrateCommand(args []string) {
+	if len(args) == 0 {
+		exitWithUsage(migrateUsage)
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		exitOnError(err)
+		steps = n
+	}
+
+	pgDB := newPgDB()
+
+	switch args[0] {
+	case "up":
+		exitOnError(pg.MigrateUp(pgDB, migrations.FS, steps))
+	case "down":
+		exitOnError(pg.MigrateDown(pgDB, migrations.FS, steps))
+	case "status":
+		statuses, err := pg.GetMigrationStatus(pgDB, migrations.FS)
+		exitOnError(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		closeResources()
+		exitWithUsage(migrateUsage)
+	}
+
+	closeResources()
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS shopping_cart_items;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS product_categories;
+DROP TABLE IF EXISTS balance;
+DROP TABLE IF EXISTS account;
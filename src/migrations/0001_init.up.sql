@@ -0,0 +1,75 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases previously created by gorm AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS account (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    username text NOT NULL,
+    password text NOT NULL,
+    role text NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_username ON account (username);
+
+CREATE TABLE IF NOT EXISTS balance (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    amount bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_balance_user FOREIGN KEY (user_id) REFERENCES account (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_balance_user_id ON balance (user_id);
+
+CREATE TABLE IF NOT EXISTS product_categories (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_name ON product_categories (name);
+
+CREATE TABLE IF NOT EXISTS products (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    category_id uuid NOT NULL,
+    title text NOT NULL,
+    description text NOT NULL,
+    price bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES product_categories (id)
+);
+
+CREATE TABLE IF NOT EXISTS shopping_cart_items (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    product_id uuid NOT NULL,
+    amount bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_shopping_cart_items_user FOREIGN KEY (user_id) REFERENCES account (id),
+    CONSTRAINT fk_shopping_cart_items_product FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    status text NOT NULL,
+    price bigint NOT NULL,
+    data jsonb NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_transactions_user FOREIGN KEY (user_id) REFERENCES account (id)
+);
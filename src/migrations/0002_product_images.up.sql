@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS product_images (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    product_id uuid NOT NULL,
+    position bigint NOT NULL,
+    content_type text NOT NULL,
+    width bigint NOT NULL,
+    height bigint NOT NULL,
+    size bigint NOT NULL,
+    original_key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    medium_key text NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_products_images FOREIGN KEY (product_id) REFERENCES products (id)
+);
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id_position ON product_images (product_id, position);
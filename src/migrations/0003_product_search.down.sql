@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_product_categories_name_trgm;
+DROP INDEX IF EXISTS idx_product_categories_name_search;
+DROP INDEX IF EXISTS idx_products_title_trgm;
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
+-- pg_trgm is left installed, other objects in the database may rely on it.
@@ -0,0 +1,13 @@
+-- Full-text search over products, with trigram indexes for typo tolerance. The 'simple' configuration does no
+-- stemming, so it treats every language the catalogue may be written in the same way.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_products_title_trgm ON products USING GIN (title gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_product_categories_name_search ON product_categories USING GIN (to_tsvector('simple', name));
+CREATE INDEX IF NOT EXISTS idx_product_categories_name_trgm ON product_categories USING GIN (name gin_trgm_ops);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_products_created_at;
+DROP INDEX IF EXISTS idx_products_price;
+DROP INDEX IF EXISTS idx_products_category_id;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS chk_products_stock;
+ALTER TABLE products DROP COLUMN IF EXISTS stock;
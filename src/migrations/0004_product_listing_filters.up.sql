@@ -0,0 +1,7 @@
+-- A NULL stock means the stock of the product is not tracked, so it is always available.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock bigint;
+ALTER TABLE products ADD CONSTRAINT chk_products_stock CHECK (stock >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products (category_id);
+CREATE INDEX IF NOT EXISTS idx_products_price ON products (price);
+CREATE INDEX IF NOT EXISTS idx_products_created_at ON products (created_at);
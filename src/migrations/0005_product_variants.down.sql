@@ -0,0 +1,5 @@
+ALTER TABLE shopping_cart_items DROP CONSTRAINT IF EXISTS fk_shopping_cart_items_variant;
+ALTER TABLE shopping_cart_items DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variants;
+ALTER TABLE products DROP COLUMN IF EXISTS options;
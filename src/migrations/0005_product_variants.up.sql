@@ -0,0 +1,27 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS options jsonb NOT NULL DEFAULT '[]';
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    product_id uuid NOT NULL,
+    options jsonb NOT NULL,
+    sku text NOT NULL,
+    price bigint,
+    stock bigint,
+    image_id uuid,
+    position bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_products_variants FOREIGN KEY (product_id) REFERENCES products (id),
+    -- Images are deleted for good, which only unsets the image of their variants.
+    CONSTRAINT fk_product_variants_image FOREIGN KEY (image_id) REFERENCES product_images (id) ON DELETE SET NULL,
+    CONSTRAINT chk_product_variants_price CHECK (price >= 0),
+    CONSTRAINT chk_product_variants_stock CHECK (stock >= 0)
+);
+-- A SKU identifies one variant across every product, but can be reused once the variant is deleted.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_variants_sku ON product_variants (sku) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id_position ON product_variants (product_id, position);
+
+ALTER TABLE shopping_cart_items ADD COLUMN IF NOT EXISTS variant_id uuid;
+ALTER TABLE shopping_cart_items ADD CONSTRAINT fk_shopping_cart_items_variant FOREIGN KEY (variant_id) REFERENCES product_variants (id);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_product_categories_path;
+DROP INDEX IF EXISTS idx_product_categories_parent_id_position;
+DROP INDEX IF EXISTS idx_product_categories_slug;
+DROP INDEX IF EXISTS idx_product_categories_parent_id_name;
+DROP INDEX IF EXISTS idx_product_categories_name;
+-- Fails when subcategories in different parents share a name, which has to be resolved by hand first.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_name ON product_categories (name);
+
+ALTER TABLE product_categories DROP CONSTRAINT IF EXISTS fk_product_categories_parent;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS position;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS path;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS slug;
+ALTER TABLE product_categories DROP COLUMN IF EXISTS parent_id;
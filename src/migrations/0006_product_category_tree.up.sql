@@ -0,0 +1,24 @@
+ALTER TABLE product_categories ADD COLUMN IF NOT EXISTS parent_id uuid;
+ALTER TABLE product_categories ADD COLUMN IF NOT EXISTS slug text;
+ALTER TABLE product_categories ADD COLUMN IF NOT EXISTS path text;
+ALTER TABLE product_categories ADD COLUMN IF NOT EXISTS position bigint NOT NULL DEFAULT 0;
+
+-- Existing categories become top level ones, with a slug made from their name. Clashing slugs get the start of the id
+-- appended.
+UPDATE product_categories SET path = '/' || id || '/' WHERE path IS NULL;
+UPDATE product_categories SET slug = trim(BOTH '-' FROM regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g')) WHERE slug IS NULL;
+UPDATE product_categories SET slug = 'category' WHERE slug = '';
+UPDATE product_categories AS category SET slug = category.slug || '-' || left(category.id::text, 8)
+    WHERE EXISTS (SELECT 1 FROM product_categories AS other WHERE other.slug = category.slug AND other.id < category.id);
+
+ALTER TABLE product_categories ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE product_categories ALTER COLUMN path SET NOT NULL;
+ALTER TABLE product_categories ADD CONSTRAINT fk_product_categories_parent FOREIGN KEY (parent_id) REFERENCES product_categories (id);
+
+-- Names only have to be unique among siblings, while a slug identifies a category on its own.
+DROP INDEX IF EXISTS idx_product_categories_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_name ON product_categories (name) WHERE parent_id IS NULL AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_parent_id_name ON product_categories (parent_id, name) WHERE parent_id IS NOT NULL AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_slug ON product_categories (slug) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_product_categories_parent_id_position ON product_categories (parent_id, position);
+CREATE INDEX IF NOT EXISTS idx_product_categories_path ON product_categories (path text_pattern_ops);
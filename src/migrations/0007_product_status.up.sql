@@ -0,0 +1,7 @@
+-- Products were all public before, so the existing ones start out published while new ones start out as drafts.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'PUBLISHED';
+ALTER TABLE products ALTER COLUMN status SET DEFAULT 'DRAFT';
+ALTER TABLE products ADD COLUMN IF NOT EXISTS publish_at timestamptz;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS unpublish_at timestamptz;
+ALTER TABLE products ADD CONSTRAINT chk_products_status CHECK (status IN ('DRAFT', 'PUBLISHED', 'ARCHIVED'));
+CREATE INDEX IF NOT EXISTS idx_products_status ON products (status);
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku text;
+-- Like the SKU of a variant, it can be reused once the product is deleted. Products without one are not constrained.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku) WHERE deleted_at IS NULL;
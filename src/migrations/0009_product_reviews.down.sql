@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_data;
+DROP TABLE IF EXISTS product_review_images;
+DROP TABLE IF EXISTS product_reviews;
+ALTER TABLE products DROP COLUMN IF EXISTS rating_average;
+ALTER TABLE products DROP COLUMN IF EXISTS rating_total;
+ALTER TABLE products DROP COLUMN IF EXISTS rating_count;
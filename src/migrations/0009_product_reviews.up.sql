@@ -0,0 +1,47 @@
+-- The rating of a product is kept up to date as reviews are approved and hidden, so that listing products does not
+-- have to aggregate their reviews.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_count bigint NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_total bigint NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_average double precision NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS product_reviews (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    product_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    rating bigint NOT NULL,
+    text text NOT NULL,
+    status text NOT NULL DEFAULT 'PENDING',
+    PRIMARY KEY (id),
+    CONSTRAINT fk_products_reviews FOREIGN KEY (product_id) REFERENCES products (id),
+    CONSTRAINT fk_product_reviews_user FOREIGN KEY (user_id) REFERENCES account (id),
+    CONSTRAINT chk_product_reviews_rating CHECK (rating BETWEEN 1 AND 5),
+    CONSTRAINT chk_product_reviews_status CHECK (status IN ('PENDING', 'APPROVED', 'HIDDEN'))
+);
+-- A user reviews a product once, but may review it again after the review is deleted.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_reviews_product_id_user_id ON product_reviews (product_id, user_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_product_reviews_status ON product_reviews (status);
+
+CREATE TABLE IF NOT EXISTS product_review_images (
+    id uuid DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    review_id uuid NOT NULL,
+    position bigint NOT NULL,
+    content_type text NOT NULL,
+    width bigint NOT NULL,
+    height bigint NOT NULL,
+    size bigint NOT NULL,
+    original_key text NOT NULL,
+    thumbnail_key text NOT NULL,
+    medium_key text NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_product_reviews_images FOREIGN KEY (review_id) REFERENCES product_reviews (id)
+);
+CREATE INDEX IF NOT EXISTS idx_product_review_images_review_id_position ON product_review_images (review_id, position);
+
+-- Reviews are only open to buyers, who are found through the items of their completed transactions.
+CREATE INDEX IF NOT EXISTS idx_transactions_data ON transactions USING GIN (data jsonb_path_ops);
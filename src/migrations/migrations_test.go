@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"testing"
+
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+func TestMigrationsAreComplete(t *testing.T) {
+	migrations, err := pg.LoadMigrations(FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations are embedded")
+	}
+
+	for i, migration := range migrations {
+		if want := int64(i + 1); migration.Version != want {
+			t.Errorf("migration %d_%s has version %d, want %d so that none is skipped", migration.Version, migration.Name, migration.Version, want)
+		}
+		if len(migration.Down) == 0 {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
@@ -2,9 +2,10 @@ package account
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
 	"hilmy.dev/store/src/libs/hash/argon2"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
@@ -12,43 +13,39 @@ import (
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/account", m.getAccountDetail)
-	m.App.Patch("/api/v1/account", m.updateAccount)
-	m.App.Delete("/api/v1/account", m.deleteAccount)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/account", &openapi.Operation{
+		Summary:  "Get the signed in account",
+		Tags:     []string{"account"},
+		IsAuth:   true,
+		Response: acc.AccountModel{},
+	}, m.getAccountDetail)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/account", &openapi.Operation{
+		Summary:  "Update the signed in account",
+		Tags:     []string{"account"},
+		IsAuth:   true,
+		Body:     updateAccountReq{},
+		Response: acc.AccountModel{},
+	}, m.updateAccount)
+	openapi.Route(m.App, fiber.MethodDelete, "/api/v1/account", &openapi.Operation{
+		Summary:  "Delete the signed in account",
+		Tags:     []string{"account"},
+		IsAuth:   true,
+		Response: uuid.UUID{},
+	}, m.deleteAccount)
 }
 
 func (m *Module) getAccountDetail(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	accountDetailData, err := m.getAccountDetailService(token.ID)
+	accountDetailData, err := m.getAccountDetailService(c.UserContext(), token.ID)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusUnauthorized
-			statusString = fiber.ErrUnauthorized.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: accountDetailData,
 	})
@@ -57,24 +54,12 @@ func (m *Module) getAccountDetail(c *fiber.Ctx) error {
 func (m *Module) updateAccount(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(updateAccountReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	accountDetailData := &acc.AccountModel{
@@ -85,37 +70,17 @@ func (m *Module) updateAccount(c *fiber.Ctx) error {
 	if req.Password != nil && len(*req.Password) > 0 {
 		encodedHash, err := argon2.GetEncodedHash(req.Password)
 		if err != nil {
-			log.SaveLogService(c.OriginalURL(), err.Error(), true)
-			return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrInternalServerError.Error(),
-					Message: err.Error(),
-				},
-			})
+			return err
 		}
 		accountDetailData.Password = encodedHash
 	}
 
-	accountDetailData, err := m.updateAccountService(token.ID, accountDetailData)
+	accountDetailData, err := m.updateAccountService(c.UserContext(), token.ID, accountDetailData)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: accountDetailData,
 	})
@@ -124,34 +89,14 @@ func (m *Module) updateAccount(c *fiber.Ctx) error {
 func (m *Module) deleteAccount(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	if err := m.deleteAccountService(token.ID); err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+	if err := m.deleteAccountService(c.UserContext(), token.ID); err != nil {
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: token.ID,
 	})
@@ -1,8 +1,8 @@
 package accountentity
 
 import (
+	"hilmy.dev/store/src/config"
 	"hilmy.dev/store/src/libs/db/pg"
-	"hilmy.dev/store/src/libs/env"
 	"hilmy.dev/store/src/libs/hash/argon2"
 	applogger "hilmy.dev/store/src/libs/logger"
 )
@@ -48,7 +48,8 @@ func AccountRepository() *accountDB {
 }
 
 func CreateInitialAccount() {
-	accountRole := Role(env.Get(env.INITIAL_ACCOUNT_ROLE, env.Option{MustExist: true}))
+	initialAccount := config.Get().Initial
+	accountRole := Role(initialAccount.Role)
 
 	count, err := AccountRepository().Count(&pg.CountOptions{
 		Where: &[]pg.Where{
@@ -65,16 +66,13 @@ func CreateInitialAccount() {
 		return
 	}
 
-	accountName := env.Get(env.INITIAL_ACCOUNT_NAME, env.Option{MustExist: true})
-	accountUsername := env.Get(env.INITIAL_ACCOUNT_USERNAME, env.Option{MustExist: true})
-	accountPassword := env.Get(env.INITIAL_ACCOUNT_PASSWORD, env.Option{MustExist: true})
-	encodedHash, err := argon2.GetEncodedHash(&accountPassword)
+	encodedHash, err := argon2.GetEncodedHash(&initialAccount.Password)
 	if err != nil {
 		logger.Panic(err)
 	}
 	if _, err := AccountRepository().Create(&AccountModel{
-		Name:     &accountName,
-		Username: &accountUsername,
+		Name:     &initialAccount.Name,
+		Username: &initialAccount.Username,
 		Password: encodedHash,
 		Role:     &accountRole,
 	}); err != nil {
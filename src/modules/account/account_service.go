@@ -1,13 +1,16 @@
 package account
 
 import (
+	"context"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
 	a "hilmy.dev/store/src/modules/account/account_entity"
 )
 
-func (*Module) getAccountDetailService(id *uuid.UUID) (*a.AccountModel, error) {
-	return a.AccountRepository().FindOne(&pg.FindOneOptions{
+func (*Module) getAccountDetailService(ctx context.Context, id *uuid.UUID) (*a.AccountModel, error) {
+	data, err := a.AccountRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
@@ -15,10 +18,15 @@ func (*Module) getAccountDetailService(id *uuid.UUID) (*a.AccountModel, error) {
 			},
 		},
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "account not found")
+	}
+
+	return data, nil
 }
 
-func (*Module) updateAccountService(id *uuid.UUID, data *a.AccountModel) (*a.AccountModel, error) {
-	if _, err := a.AccountRepository().Update(data, &pg.UpdateOptions{
+func (*Module) updateAccountService(ctx context.Context, id *uuid.UUID, data *a.AccountModel) (*a.AccountModel, error) {
+	if _, err := a.AccountRepository().WithContext(ctx).Update(data, &pg.UpdateOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
@@ -26,28 +34,33 @@ func (*Module) updateAccountService(id *uuid.UUID, data *a.AccountModel) (*a.Acc
 			},
 		},
 	}); err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "account not found")
 	}
 
-	data, err := a.AccountRepository().FindOne(&pg.FindOneOptions{
+	data, err := a.AccountRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+		IsPrimary: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "account not found")
 	}
 
 	return data, nil
 }
 
-func (*Module) deleteAccountService(id *uuid.UUID) error {
-	return a.AccountRepository().Destroy(&a.AccountModel{
+func (*Module) deleteAccountService(ctx context.Context, id *uuid.UUID) error {
+	if err := a.AccountRepository().WithContext(ctx).Destroy(&a.AccountModel{
 		Model: pg.Model{
 			ID: id,
 		},
-	})
+	}); err != nil {
+		return apperror.NotFoundOr(err, "account not found")
+	}
+
+	return nil
 }
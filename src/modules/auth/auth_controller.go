@@ -1,14 +1,14 @@
 package auth
 
 import (
-	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/hash/argon2"
 	"hilmy.dev/store/src/libs/jwx/jwt"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
@@ -16,75 +16,62 @@ import (
 	"hilmy.dev/store/src/modules/log"
 )
 
+var errIncorrectCredentials = apperror.Unauthorized("incorrect username or password")
+
 func (m *Module) controller() {
-	m.App.Post("/api/v1/signup", m.signup)
-	m.App.Post("/api/v1/signin", m.signin)
-	m.App.Get("/api/v1/auth", m.auth)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/signup", &openapi.Operation{
+		Summary:  "Sign up",
+		Tags:     []string{"auth"},
+		Body:     signupReq{},
+		Response: acc.AccountModel{},
+	}, m.signup)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/signin", &openapi.Operation{
+		Summary:  "Sign in",
+		Tags:     []string{"auth"},
+		Body:     signinReq{},
+		Response: signinRes{},
+	}, m.signin)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/auth", &openapi.Operation{
+		Summary:  "Renew the token of the signed in account",
+		Tags:     []string{"auth"},
+		IsAuth:   true,
+		Response: accountRes{},
+	}, m.auth)
 }
 
 func (m *Module) signup(c *fiber.Ctx) error {
 	req := new(signupReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	encodedHash, err := argon2.GetEncodedHash(req.Password)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	accountRole := acc.ROLE_USER
-	accountDetailData, err := m.addAccountService(&acc.AccountModel{
+	accountDetailData, err := m.addAccountService(c.UserContext(), &acc.AccountModel{
 		Name:     req.Name,
 		Username: req.Username,
 		Password: encodedHash,
 		Role:     &accountRole,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 	balanceAmount := 0
-	if _, err := m.createBalanceService(&balanceentity.BalanceModel{
+	if _, err := m.createBalanceService(c.UserContext(), &balanceentity.BalanceModel{
 		UserID: accountDetailData.ID,
 		Amount: &balanceAmount,
 	}); err != nil {
-		if err := m.deleteAccountService(accountDetailData.ID); err != nil {
-			log.SaveLogService(c.OriginalURL(), err.Error(), true)
-			return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrInternalServerError.Error(),
-					Message: err.Error(),
-				},
-			})
+		if err := m.deleteAccountService(c.UserContext(), accountDetailData.ID); err != nil {
+			return err
 		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: accountDetailData,
 	})
@@ -93,53 +80,24 @@ func (m *Module) signup(c *fiber.Ctx) error {
 func (m *Module) signin(c *fiber.Ctx) error {
 	req := new(signinReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	accountDetailData, err := m.getAccountDetailByUsernameService(req.Username)
+	// An unknown username gets the same answer as a wrong password, so that signin cannot be used to probe for accounts.
+	accountDetailData, err := m.getAccountDetailByUsernameService(c.UserContext(), req.Username)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
+		if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+			return errIncorrectCredentials
 		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	isAuthorized, err := argon2.CompareStringAndEncodedHash(req.Password, accountDetailData.Password)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 	if !isAuthorized {
-		err := errors.New("incorrect username or password")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return errIncorrectCredentials
 	}
 
 	jwtToken, err := jwt.Create(&a.JWTPayload{
@@ -147,16 +105,10 @@ func (m *Module) signin(c *fiber.Ctx) error {
 		Role: accountDetailData.Role,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: &signinRes{
 			Token: jwtToken,
@@ -170,60 +122,27 @@ func (m *Module) signin(c *fiber.Ctx) error {
 func (m *Module) auth(c *fiber.Ctx) error {
 	tokenString, err := parser.GetReqBearerToken(c)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	tokenExp := time.Unix(*token.Expiration, 0)
 	renewToken, err := jwt.Renew[a.JWTPayload](tokenString, &tokenExp)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return apperror.Wrap(apperror.KIND_UNAUTHORIZED, "failed to renew token", err)
 	}
 	tokenString = renewToken
 
-	accountDetailData, err := m.getAccountDetailService(token.ID)
+	accountDetailData, err := m.getAccountDetailService(c.UserContext(), token.ID)
 	if err != nil {
-		if pg.IsErrRecordNotFound(err) {
-			log.SaveLogService(c.OriginalURL(), err.Error(), false)
-			return c.Status(fiber.StatusNotFound).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrNotFound.Error(),
-					Message: err.Error(),
-				},
-			})
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: &accountRes{
 			Token: tokenString,
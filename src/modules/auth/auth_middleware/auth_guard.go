@@ -2,7 +2,7 @@ package authmiddleware
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"hilmy.dev/store/src/contracts"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
@@ -16,12 +16,7 @@ func AuthGuard(role ...acc.Role) fiber.Handler {
 
 		token := new(a.JWTPayload)
 		if err := parser.ParseReqBearerToken(c, token); err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrUnauthorized.Error(),
-					Message: err.Error(),
-				},
-			})
+			return err
 		}
 
 		isAuthorized := false
@@ -32,12 +27,7 @@ func AuthGuard(role ...acc.Role) fiber.Handler {
 		}
 
 		if !isAuthorized {
-			return c.Status(fiber.StatusForbidden).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrForbidden.Error(),
-					Message: "you are prohibited from accessing this resource",
-				},
-			})
+			return apperror.Forbidden("you are prohibited from accessing this resource")
 		}
 
 		return c.Next()
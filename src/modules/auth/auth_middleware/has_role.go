@@ -0,0 +1,29 @@
+package authmiddleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/parser"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	a "hilmy.dev/store/src/modules/auth/auth_entity"
+)
+
+// HasRole reports whether the request carries a bearer token with one of the roles, for public routes that show more
+// to some roles. A request without a token has no role, while one with an invalid token is rejected.
+func HasRole(c *fiber.Ctx, role ...acc.Role) (bool, error) {
+	if len(c.Get("authorization")) == 0 {
+		return false, nil
+	}
+
+	token := new(a.JWTPayload)
+	if err := parser.ParseReqBearerToken(c, token); err != nil {
+		return false, err
+	}
+
+	for i := range role {
+		if role[i] == *token.Role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
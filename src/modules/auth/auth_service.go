@@ -1,14 +1,17 @@
 package auth
 
 import (
+	"context"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
 	a "hilmy.dev/store/src/modules/account/account_entity"
 	b "hilmy.dev/store/src/modules/balance/balance_entity"
 )
 
-func (m *Module) getAccountDetailService(id *uuid.UUID) (*a.AccountModel, error) {
-	return a.AccountRepository().FindOne(&pg.FindOneOptions{
+func (m *Module) getAccountDetailService(ctx context.Context, id *uuid.UUID) (*a.AccountModel, error) {
+	data, err := a.AccountRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
@@ -16,10 +19,15 @@ func (m *Module) getAccountDetailService(id *uuid.UUID) (*a.AccountModel, error)
 			},
 		},
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "account not found")
+	}
+
+	return data, nil
 }
 
-func (m *Module) getAccountDetailByUsernameService(username *string) (*a.AccountModel, error) {
-	return a.AccountRepository().FindOne(&pg.FindOneOptions{
+func (m *Module) getAccountDetailByUsernameService(ctx context.Context, username *string) (*a.AccountModel, error) {
+	data, err := a.AccountRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "username = ?",
@@ -27,20 +35,29 @@ func (m *Module) getAccountDetailByUsernameService(username *string) (*a.Account
 			},
 		},
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "account not found")
+	}
+
+	return data, nil
 }
 
-func (m *Module) addAccountService(data *a.AccountModel) (*a.AccountModel, error) {
-	return a.AccountRepository().Create(data)
+func (m *Module) addAccountService(ctx context.Context, data *a.AccountModel) (*a.AccountModel, error) {
+	return a.AccountRepository().WithContext(ctx).Create(data)
 }
 
-func (m *Module) deleteAccountService(id *uuid.UUID) error {
-	return a.AccountRepository().Destroy(&a.AccountModel{
+func (m *Module) deleteAccountService(ctx context.Context, id *uuid.UUID) error {
+	if err := a.AccountRepository().WithContext(ctx).Destroy(&a.AccountModel{
 		Model: pg.Model{
 			ID: id,
 		},
-	})
+	}); err != nil {
+		return apperror.NotFoundOr(err, "account not found")
+	}
+
+	return nil
 }
 
-func (m *Module) createBalanceService(data *b.BalanceModel) (*b.BalanceModel, error) {
-	return b.BalanceRepository().Create(data)
+func (m *Module) createBalanceService(ctx context.Context, data *b.BalanceModel) (*b.BalanceModel, error) {
+	return b.BalanceRepository().WithContext(ctx).Create(data)
 }
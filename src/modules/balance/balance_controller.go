@@ -3,7 +3,7 @@ package balance
 import (
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
@@ -13,42 +13,33 @@ import (
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/balance", am.AuthGuard(acc.ROLE_USER), m.getBalance)
-	m.App.Post("/api/v1/balance/add", am.AuthGuard(acc.ROLE_USER), m.addBalance)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/balance", &openapi.Operation{
+		Summary:  "Get the balance of the signed in account",
+		Tags:     []string{"balance"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Response: balanceentity.BalanceModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.getBalance)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/balance/add", &openapi.Operation{
+		Summary:  "Top up the balance of the signed in account",
+		Tags:     []string{"balance"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Body:     addBalanceReq{},
+		Response: balanceentity.BalanceModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.addBalance)
 }
 
 func (m *Module) getBalance(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	balanceDetailData, err := m.getBalanceByUserIDService(token.ID)
+	balanceDetailData, err := m.getBalanceByUserIDService(c.UserContext(), token.ID, false)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: balanceDetailData,
 	})
@@ -57,60 +48,22 @@ func (m *Module) getBalance(c *fiber.Ctx) error {
 func (m *Module) addBalance(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(addBalanceReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	balanceDetailData, err := m.getBalanceByUserIDService(token.ID)
+	balanceDetailData, err := m.addBalanceByUserIDService(c.UserContext(), token.ID, *req.Amount)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
+	balanceTopUpsTotal.Inc()
+	balanceTopUpAmountTotal.Add(float64(*req.Amount))
 
-	balanceAmount := *balanceDetailData.Amount + *req.Amount
-	balanceDetailData, err = m.updateBalanceByUserIDService(token.ID, &balanceentity.BalanceModel{
-		Amount: &balanceAmount,
-	})
-	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
-	}
-
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: balanceDetailData,
 	})
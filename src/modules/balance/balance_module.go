@@ -3,6 +3,7 @@ package balance
 import (
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/metrics"
 	b "hilmy.dev/store/src/modules/balance/balance_entity"
 )
 
@@ -11,6 +12,16 @@ type Module struct {
 	DB  *pg.DB
 }
 
+var balanceTopUpsTotal = metrics.NewCounter(
+	"balance_top_ups_total",
+	"Total number of successful balance top-ups.",
+)
+
+var balanceTopUpAmountTotal = metrics.NewCounter(
+	"balance_top_up_amount_total",
+	"Sum of all successfully topped-up balance amounts.",
+)
+
 func Load(module *Module) {
 	b.InitRepository(module.DB)
 	module.controller()
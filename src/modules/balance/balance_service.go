@@ -1,45 +1,51 @@
 package balance
 
 import (
+	"context"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
 	b "hilmy.dev/store/src/modules/balance/balance_entity"
 )
 
-func (*Module) getBalanceByUserIDService(userID *uuid.UUID) (*b.BalanceModel, error) {
-	return b.BalanceRepository().FindOne(&pg.FindOneOptions{
+// getBalanceByUserIDService reads from the primary when isPrimary is set, for reading the balance back after updating
+// it.
+func (*Module) getBalanceByUserIDService(ctx context.Context, userID *uuid.UUID, isPrimary bool) (*b.BalanceModel, error) {
+	data, err := b.BalanceRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ?",
 				Args:  []interface{}{userID},
 			},
 		},
+		IsPrimary: isPrimary,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "balance not found")
+	}
+
+	return data, nil
 }
 
-func (m *Module) updateBalanceByUserIDService(userID *uuid.UUID, data *b.BalanceModel) (*b.BalanceModel, error) {
-	if _, err := b.BalanceRepository().Update(data, &pg.UpdateOptions{
-		Where: &[]pg.Where{
-			{
-				Query: "user_id = ?",
-				Args:  []interface{}{userID},
-			},
+// addBalanceByUserIDService adds amount to the balance in place, so that a concurrent top-up or payment is never
+// overwritten, and returns the balance as it is afterwards.
+func (m *Module) addBalanceByUserIDService(ctx context.Context, userID *uuid.UUID, amount int) (*b.BalanceModel, error) {
+	if err := b.BalanceRepository().WithContext(ctx).UpdateExpr(map[string]pg.Where{
+		"amount": {
+			Query: "amount + ?",
+			Args:  []interface{}{amount},
 		},
-	}); err != nil {
-		return nil, err
-	}
-
-	data, err := b.BalanceRepository().FindOne(&pg.FindOneOptions{
+	}, &pg.UpdateOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ?",
 				Args:  []interface{}{userID},
 			},
 		},
-	})
-	if err != nil {
-		return nil, err
+	}); err != nil {
+		return nil, apperror.NotFoundOr(err, "balance not found")
 	}
 
-	return data, nil
+	return m.getBalanceByUserIDService(ctx, userID, true)
 }
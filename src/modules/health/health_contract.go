@@ -0,0 +1,27 @@
+package health
+
+type dependencyStatus string
+
+const (
+	STATUS_UP   dependencyStatus = "UP"
+	STATUS_DOWN dependencyStatus = "DOWN"
+)
+
+type buildRes struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+type dependencyRes struct {
+	Status    dependencyStatus `json:"status"`
+	LatencyMs int64            `json:"latencyMs"`
+	Error     string           `json:"error,omitempty"`
+}
+
+type healthRes struct {
+	Status       dependencyStatus          `json:"status"`
+	Build        *buildRes                 `json:"build"`
+	Dependencies map[string]*dependencyRes `json:"dependencies,omitempty"`
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/contracts"
+)
+
+func (m *Module) controller() {
+	m.App.Get("/healthz", m.getLiveness)
+	m.App.Get("/readyz", m.getReadiness)
+}
+
+func (m *Module) getLiveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: &healthRes{
+			Status: STATUS_UP,
+			Build:  m.getBuildInfoService(),
+		},
+	})
+}
+
+func (m *Module) getReadiness(c *fiber.Ctx) error {
+	if m.isDraining.Load() {
+		err := errors.New("server is shutting down")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(&contracts.Response{
+			Error: &contracts.Error{
+				Status:  fiber.ErrServiceUnavailable.Error(),
+				Message: err.Error(),
+			},
+			Data: &healthRes{
+				Status: STATUS_DOWN,
+				Build:  m.getBuildInfoService(),
+			},
+		})
+	}
+
+	dependencies := m.checkDependenciesService()
+	for name := range dependencies {
+		if dependencies[name].Status != STATUS_UP {
+			err := errors.New(name + " is not ready")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(&contracts.Response{
+				Error: &contracts.Error{
+					Status:  fiber.ErrServiceUnavailable.Error(),
+					Message: err.Error(),
+				},
+				Data: &healthRes{
+					Status:       STATUS_DOWN,
+					Build:        m.getBuildInfoService(),
+					Dependencies: dependencies,
+				},
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: &healthRes{
+			Status:       STATUS_UP,
+			Build:        m.getBuildInfoService(),
+			Dependencies: dependencies,
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/db/mongo"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/gracefulshutdown"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+// Version is overridden at build time with -ldflags "-X hilmy.dev/store/src/modules/health.Version=<version>".
+var Version = "dev"
+
+var logger = applogger.New("HealthModule")
+
+type Module struct {
+	App      *fiber.App
+	DB       *pg.DB
+	DBClient *mongo.Client
+	// DrainDelay is how long readiness fails before the server stops taking requests on shutdown, which gives load
+	// balancers time to notice and send traffic elsewhere.
+	DrainDelay time.Duration
+	// IsHideErrors keeps the errors of the dependencies out of the readiness response, leaving them to the server log.
+	IsHideErrors bool
+
+	isDraining atomic.Bool
+}
+
+func Load(module *Module) {
+	module.controller()
+
+	gracefulshutdown.Add(gracefulshutdown.FnRunInShutdown{
+		FnDescription: "failing readiness before draining",
+		Phase:         gracefulshutdown.PHASE_STOP_ACCEPTING,
+		Fn: func(ctx context.Context) error {
+			module.isDraining.Store(true)
+
+			timer := time.NewTimer(module.DrainDelay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	applogger "hilmy.dev/store/src/libs/logger"
+)
+
+const pingTimeout = 2 * time.Second
+
+func (*Module) getBuildInfoService() *buildRes {
+	build := &buildRes{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.Revision = setting.Value
+			case "vcs.time":
+				build.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return build
+}
+
+func (m *Module) checkDependenciesService() map[string]*dependencyRes {
+	checks := map[string]func(ctx context.Context) error{
+		"postgres": func(ctx context.Context) error {
+			sqlDB, err := m.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+		"mongo": func(ctx context.Context) error {
+			return m.DBClient.Ping(ctx, readpref.Primary())
+		},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	dependencies := make(map[string]*dependencyRes, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			dependency := &dependencyRes{
+				Status:    STATUS_UP,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				logger.Error(fmt.Errorf("%s is down: %w", name, err), &applogger.Options{IsPrintStack: false})
+				dependency.Status = STATUS_DOWN
+				if !m.IsHideErrors {
+					dependency.Error = err.Error()
+				}
+			}
+
+			mu.Lock()
+			dependencies[name] = dependency
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return dependencies
+}
@@ -1,8 +1,8 @@
 package log
 
 import (
+	"hilmy.dev/store/src/config"
 	"hilmy.dev/store/src/libs/db/mongo"
-	"hilmy.dev/store/src/libs/env"
 )
 
 type logModel struct {
@@ -13,7 +13,7 @@ type logModel struct {
 }
 
 func (logModel) DatabaseName() string {
-	return env.Get(env.MONGO_DATABASE_NAME)
+	return config.Get().Mongo.DatabaseName
 }
 
 func (logModel) CollectionName() string {
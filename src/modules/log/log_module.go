@@ -3,6 +3,7 @@ package log
 import (
 	"hilmy.dev/store/src/libs/db/mongo"
 	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/metrics"
 )
 
 type Module struct {
@@ -11,6 +12,12 @@ type Module struct {
 
 var logger = applogger.New("LogModule")
 
+var logWritesTotal = metrics.NewCounterVec(
+	"log_writes_total",
+	"Total number of log documents written to MongoDB by result.",
+	"result",
+)
+
 func Load(module *Module) {
 	module.initRepository()
 }
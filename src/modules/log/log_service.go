@@ -1,22 +1,27 @@
 package log
 
 import (
+	"context"
 	"runtime/debug"
 )
 
-func SaveLogService(location string, message string, printStack bool) error {
+// SaveLogService inserts the log with ctx, which is the request context, so that the insert shares its deadline and
+// shows up in its trace.
+func SaveLogService(ctx context.Context, location string, message string, printStack bool) error {
 	stack := new(string)
 	if printStack {
 		_stack := string(debug.Stack())
 		stack = &_stack
 	}
-	if _, err := LogRepository().Create(&logModel{
+	if _, err := LogRepository().WithContext(ctx).Create(&logModel{
 		Location: &location,
 		Message:  &message,
 		Stack:    stack,
 	}); err != nil {
+		logWritesTotal.WithLabelValues("error").Inc()
 		logger.Error(err)
 		return err
 	}
+	logWritesTotal.WithLabelValues("success").Inc()
 	return nil
 }
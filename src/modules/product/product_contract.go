@@ -1,11 +1,104 @@
 package product
 
-import "github.com/google/uuid"
+import (
+	"mime/multipart"
+	"time"
+
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
+	"hilmy.dev/store/src/libs/validator"
+	p "hilmy.dev/store/src/modules/product/product_entity"
+)
 
 type getProductListReqQuery struct {
-	SearchByCategoryID *uuid.UUID `query:"category_id"`
-	Limit              *int       `query:"limit"`
-	Page               *int       `query:"page"`
+	Query *string `query:"q"`
+	// SearchByCategoryIDs is given by repeating category_id, and matches products in any of the categories or their
+	// subcategories.
+	SearchByCategoryIDs []string `query:"category_id" validate:"lte=100,dive,uuid"`
+	MinPrice            *int     `query:"min_price" validate:"omitempty,gte=0"`
+	MaxPrice            *int     `query:"max_price" validate:"omitempty,gte=0"`
+	IsInStock           *bool    `query:"in_stock"`
+	// SearchByStatus is only accepted from admins, who see products of every status when it is not given. Everyone
+	// else only sees the live products.
+	SearchByStatus *p.ProductStatus `query:"status" validate:"omitempty,oneof=DRAFT PUBLISHED ARCHIVED"`
+	Limit          *int             `query:"limit"`
+	Page           *int             `query:"page"`
+	Cursor         *string          `query:"cursor"`
+	IncludeTotal   *bool            `query:"include_total"`
+	IncludeFacets  *bool            `query:"include_facets"`
+}
+
+type productFacets struct {
+	Categories []*productCategoryFacet `json:"categories"`
+	Prices     []*productPriceFacet    `json:"prices"`
+}
+
+type productCategoryFacet struct {
+	CategoryID *uuid.UUID `json:"categoryId"`
+	Name       *string    `json:"name,omitempty"`
+	Count      int64      `json:"count"`
+}
+
+// productPriceFacet counts the products priced from Min, inclusive, up to Max, exclusive. The first bucket has no Min
+// and the last no Max.
+type productPriceFacet struct {
+	Min   *int  `json:"min,omitempty"`
+	Max   *int  `json:"max,omitempty"`
+	Count int64 `json:"count"`
+}
+
+// productListQueryFields whitelists the fields accepted in filter[...] and sort.
+var productListQueryFields = parser.QueryFields{
+	"title": {
+		Column:     "title",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"price": {
+		Column:     "price",
+		Type:       parser.FIELD_TYPE_INT,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"category_id": {
+		Column:    "category_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_IN},
+	},
+	"newest": {
+		Column:     "created_at",
+		IsSortable: true,
+		IsReversed: true,
+	},
+	"created_at": {
+		Column:     "created_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"updated_at": {
+		Column:     "updated_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+}
+
+type getProductSuggestionsReqQuery struct {
+	Query *string `query:"q" validate:"required"`
+	Limit *int    `query:"limit" validate:"omitempty,gt=0,lte=20"`
+}
+
+type productSuggestions struct {
+	Products   []*productSuggestion `json:"products"`
+	Categories []*productSuggestion `json:"categories"`
+}
+
+type productSuggestion struct {
+	ID        *uuid.UUID `json:"id"`
+	Text      *string    `json:"text"`
+	Highlight *string    `json:"highlight,omitempty"`
 }
 
 type getProductDetailReqParam struct {
@@ -14,9 +107,15 @@ type getProductDetailReqParam struct {
 
 type addProductReq struct {
 	CategoryID  *uuid.UUID `json:"category_id" validate:"required"`
+	SKU         *string    `json:"sku" validate:"omitempty,gt=0,lte=64"`
 	Title       *string    `json:"title" validate:"required"`
 	Description *string    `json:"description" validate:"required"`
 	Price       *int       `json:"price" validate:"required"`
+	Stock       *int       `json:"stock" validate:"omitempty,gte=0"`
+	// Status defaults to DRAFT.
+	Status      *p.ProductStatus `json:"status" validate:"omitempty,oneof=DRAFT PUBLISHED ARCHIVED"`
+	PublishAt   *time.Time       `json:"publish_at"`
+	UnpublishAt *time.Time       `json:"unpublish_at"`
 }
 
 type updateProductReqParam struct {
@@ -24,12 +123,135 @@ type updateProductReqParam struct {
 }
 
 type updateProductReq struct {
-	CategoryID  *uuid.UUID `json:"category_id"`
-	Title       *string    `json:"title"`
-	Description *string    `json:"description"`
-	Price       *int       `json:"price"`
+	CategoryID  *uuid.UUID       `json:"category_id"`
+	SKU         *string          `json:"sku" validate:"omitempty,gt=0,lte=64"`
+	Title       *string          `json:"title"`
+	Description *string          `json:"description"`
+	Price       *int             `json:"price"`
+	Stock       *int             `json:"stock" validate:"omitempty,gte=0"`
+	Status      *p.ProductStatus `json:"status" validate:"omitempty,oneof=DRAFT PUBLISHED ARCHIVED"`
+	PublishAt   *time.Time       `json:"publish_at"`
+	UnpublishAt *time.Time       `json:"unpublish_at"`
+	// IsClearPublishAt and IsClearUnpublishAt remove a bound of the publishing window, since leaving out publish_at
+	// or unpublish_at keeps the stored one.
+	IsClearPublishAt   *bool `json:"clear_publish_at"`
+	IsClearUnpublishAt *bool `json:"clear_unpublish_at"`
 }
 
 type deleteProductReqParam struct {
 	ID *uuid.UUID `params:"id" validate:"required"`
 }
+
+type addProductImageReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type addProductImageReq struct {
+	Image *multipart.FileHeader `form:"image" validate:"required"`
+}
+
+type reorderProductImagesReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type reorderProductImagesReq struct {
+	ImageIDs *[]uuid.UUID `json:"image_ids" validate:"required,gt=0"`
+}
+
+type deleteProductImageReqParam struct {
+	ID      *uuid.UUID `params:"id" validate:"required"`
+	ImageID *uuid.UUID `params:"image_id" validate:"required"`
+}
+
+type replaceProductVariantsReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+// replaceProductVariantsReq holds the whole option matrix of a product. Variants are matched to the existing ones by
+// their option values, so that a variant keeps its id, and the shopping cart items pointing at it, across updates.
+type replaceProductVariantsReq struct {
+	Options  *[]productOptionReq  `json:"options" validate:"required,lte=3,dive"`
+	Variants *[]productVariantReq `json:"variants" validate:"required,lte=100,dive"`
+}
+
+type productOptionReq struct {
+	Name   *string   `json:"name" validate:"required,gt=0,lte=50"`
+	Values *[]string `json:"values" validate:"required,gt=0,lte=50,unique,dive,gt=0,lte=50"`
+}
+
+type productVariantReq struct {
+	// Options maps the name of every option of the product to the value of the variant.
+	Options map[string]string `json:"options" validate:"required"`
+	SKU     *string           `json:"sku" validate:"required,gt=0,lte=64"`
+	Price   *int              `json:"price" validate:"omitempty,gte=0"`
+	Stock   *int              `json:"stock" validate:"omitempty,gte=0"`
+	ImageID *uuid.UUID        `json:"image_id"`
+}
+
+type updateProductVariantsReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type updateProductVariantsReq struct {
+	Variants *[]updateProductVariantReq `json:"variants" validate:"required,gt=0,lte=100,dive"`
+}
+
+type updateProductVariantReq struct {
+	ID      *uuid.UUID `json:"id" validate:"required"`
+	SKU     *string    `json:"sku" validate:"omitempty,gt=0,lte=64"`
+	Price   *int       `json:"price" validate:"omitempty,gte=0"`
+	Stock   *int       `json:"stock" validate:"omitempty,gte=0"`
+	ImageID *uuid.UUID `json:"image_id"`
+}
+
+const (
+	PRODUCT_FILE_FORMAT_CSV   = "csv"
+	PRODUCT_FILE_FORMAT_JSONL = "jsonl"
+)
+
+type importProductsReqQuery struct {
+	// Format defaults to the one given by the extension of the file, .csv or .jsonl.
+	Format *string `query:"format" validate:"omitempty,oneof=csv jsonl"`
+	// IsDryRun checks every row and reports what would be imported without writing anything.
+	IsDryRun *bool `query:"dry_run"`
+}
+
+type importProductsReq struct {
+	File *multipart.FileHeader `form:"file" validate:"required"`
+}
+
+// productRow is a product in an import or export file. CSV files have a header row naming the same columns as the
+// JSON fields. Category is the slug or the name of the category, and export writes the slug. Every column is written,
+// so a missing stock leaves the stock untracked and a missing status makes a draft.
+type productRow struct {
+	SKU         *string          `json:"sku" validate:"required,gt=0,lte=64"`
+	Category    *string          `json:"category" validate:"required"`
+	Title       *string          `json:"title" validate:"required"`
+	Description *string          `json:"description" validate:"required"`
+	Price       *int             `json:"price" validate:"required,gte=0"`
+	Stock       *int             `json:"stock" validate:"omitempty,gte=0"`
+	Status      *p.ProductStatus `json:"status" validate:"omitempty,oneof=DRAFT PUBLISHED ARCHIVED"`
+	PublishAt   *time.Time       `json:"publish_at"`
+	UnpublishAt *time.Time       `json:"unpublish_at"`
+}
+
+// importProductsRes reports an import. Rows are upserted by SKU, and nothing is written unless every row is valid.
+type importProductsRes struct {
+	IsDryRun   bool                     `json:"isDryRun"`
+	IsImported bool                     `json:"isImported"`
+	Created    int                      `json:"created"`
+	Updated    int                      `json:"updated"`
+	Errors     []*importProductRowError `json:"errors"`
+}
+
+type importProductRowError struct {
+	// Line is the line of the row in the file, which is one more than the row number in CSV files.
+	Line    int                    `json:"line"`
+	SKU     *string                `json:"sku,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Fields  []validator.FieldError `json:"fields,omitempty"`
+}
+
+type exportProductsReqQuery struct {
+	Format *string `query:"format" validate:"omitempty,oneof=csv jsonl"`
+}
@@ -1,12 +1,21 @@
 package product
 
 import (
-	"errors"
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/imaging"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
+	"hilmy.dev/store/src/libs/validator"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	am "hilmy.dev/store/src/modules/auth/auth_middleware"
 	"hilmy.dev/store/src/modules/log"
@@ -14,23 +23,128 @@ import (
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/products", m.getProductList)
-	m.App.Get("/api/v1/product/:id", m.getProductDetail)
-	m.App.Post("/api/v1/product", am.AuthGuard(acc.ROLE_ADMIN), m.addProduct)
-	m.App.Patch("/api/v1/product/:id", am.AuthGuard(acc.ROLE_ADMIN), m.updateProduct)
-	m.App.Delete("/api/v1/product/:id", am.AuthGuard(acc.ROLE_ADMIN), m.deleteProduct)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/products", &openapi.Operation{
+		Summary:   "List the live products, or every product for admins",
+		Tags:      []string{"product"},
+		Query:     getProductListReqQuery{},
+		ListQuery: productListQueryFields,
+		Response:  p.ProductModel{},
+		IsList:    true,
+		Facets:    productFacets{},
+	}, m.getProductList)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/products/suggest", &openapi.Operation{
+		Summary:  "Suggest product titles and categories for a partial search",
+		Tags:     []string{"product"},
+		Query:    getProductSuggestionsReqQuery{},
+		Response: productSuggestions{},
+	}, m.getProductSuggestions)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product/:id", &openapi.Operation{
+		Summary:  "Get a product",
+		Tags:     []string{"product"},
+		Params:   getProductDetailReqParam{},
+		Response: p.ProductModel{},
+	}, m.getProductDetail)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product", &openapi.Operation{
+		Summary:  "Add a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Body:     addProductReq{},
+		Status:   fiber.StatusCreated,
+		Response: p.ProductModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.addProduct)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/product/:id", &openapi.Operation{
+		Summary:  "Update a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   updateProductReqParam{},
+		Body:     updateProductReq{},
+		Response: p.ProductModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.updateProduct)
+	openapi.Route(m.App, fiber.MethodDelete, "/api/v1/product/:id", &openapi.Operation{
+		Summary:  "Delete a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   deleteProductReqParam{},
+		Response: uuid.UUID{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.deleteProduct)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product/:id/image", &openapi.Operation{
+		Summary:  "Upload a product image",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   addProductImageReqParam{},
+		Form:     addProductImageReq{},
+		Status:   fiber.StatusCreated,
+		Response: p.ProductImageModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.addProductImage)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/product/:id/images", &openapi.Operation{
+		Summary:  "Reorder the images of a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   reorderProductImagesReqParam{},
+		Body:     reorderProductImagesReq{},
+		Response: []*p.ProductImageModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.reorderProductImages)
+	openapi.Route(m.App, fiber.MethodDelete, "/api/v1/product/:id/image/:image_id", &openapi.Operation{
+		Summary:  "Delete a product image",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   deleteProductImageReqParam{},
+		Response: uuid.UUID{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.deleteProductImage)
+	openapi.Route(m.App, fiber.MethodPut, "/api/v1/product/:id/variants", &openapi.Operation{
+		Summary:  "Replace the options and variants of a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   replaceProductVariantsReqParam{},
+		Body:     replaceProductVariantsReq{},
+		Response: p.ProductModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.replaceProductVariants)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/product/:id/variants", &openapi.Operation{
+		Summary:  "Update several variants of a product",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   updateProductVariantsReqParam{},
+		Body:     updateProductVariantsReq{},
+		Response: p.ProductModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.updateProductVariants)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/admin/products/import", &openapi.Operation{
+		Summary:  "Import products from a CSV or JSON lines file, upserting them by SKU",
+		Tags:     []string{"product"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Query:    importProductsReqQuery{},
+		Form:     importProductsReq{},
+		Response: importProductsRes{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.importProducts)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/admin/products/export", &openapi.Operation{
+		Summary: "Export every product as a CSV or JSON lines file, in the format accepted by the import",
+		Tags:    []string{"product"},
+		Roles:   []string{string(acc.ROLE_ADMIN)},
+		Query:   exportProductsReqQuery{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.exportProducts)
 }
 
 func (m *Module) getProductList(c *fiber.Ctx) error {
 	query := new(getProductListReqQuery)
 	if err := parser.ParseReqQuery(c, query); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+
+	// Searches are ordered by relevance unless a sort is given.
+	defaultSort := "title"
+	if query.Query != nil && len(*query.Query) > 0 {
+		defaultSort = ""
+	}
+	listQuery, err := parser.ParseReqListQuery(c, productListQueryFields, defaultSort)
+	if err != nil {
+		return err
+	}
+
+	isAdmin, err := am.HasRole(c, acc.ROLE_ADMIN)
+	if err != nil {
+		return err
+	}
+	if !isAdmin && query.SearchByStatus != nil {
+		return apperror.Forbidden("only admins can filter products by status")
 	}
 
 	offset := 0
@@ -38,66 +152,102 @@ func (m *Module) getProductList(c *fiber.Ctx) error {
 		offset = (*query.Page - 1) * *query.Limit
 	}
 
-	productListData, page, err := m.getProductListService(&paginationOptions{
-		limit:  query.Limit,
-		offset: &offset,
-	}, &searchOptions{
-		byCategoryID: query.SearchByCategoryID,
-	})
+	// The query parser cannot decode a slice of uuid.UUID, so the ids arrive as strings already validated as uuids.
+	categoryIDs := make([]uuid.UUID, 0, len(query.SearchByCategoryIDs))
+	for _, categoryID := range query.SearchByCategoryIDs {
+		categoryIDs = append(categoryIDs, uuid.MustParse(categoryID))
+	}
+
+	search := &searchOptions{
+		filters:       listQuery.Where,
+		byCategoryIDs: categoryIDs,
+		minPrice:      query.MinPrice,
+		maxPrice:      query.MaxPrice,
+		isInStock:     query.IsInStock != nil && *query.IsInStock,
+		byStatus:      query.SearchByStatus,
+		isLiveOnly:    !isAdmin,
+		query:         query.Query,
+	}
+
+	productListData, page, err := m.getProductListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
+	}, search)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+
+	// A nil *productFacets would still be encoded as null, so facets is only set when asked for.
+	var facets interface{}
+	if query.IncludeFacets != nil && *query.IncludeFacets {
+		productFacetsData, err := m.getProductFacetsService(c.UserContext(), search)
+		if err != nil {
+			return err
+		}
+		facets = productFacetsData
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Pagination: &contracts.Pagination{
 			Limit: page.limit,
 			Count: page.count,
 			Page:  query.Page,
 			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
 		},
-		Data: productListData,
+		Data:   productListData,
+		Facets: facets,
+	})
+}
+
+func (m *Module) getProductSuggestions(c *fiber.Ctx) error {
+	query := new(getProductSuggestionsReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	limit := 5
+	if query.Limit != nil {
+		limit = *query.Limit
+	}
+
+	productSuggestionsData, err := m.getProductSuggestionsService(c.UserContext(), *query.Query, limit)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productSuggestionsData,
 	})
 }
 
 func (m *Module) getProductDetail(c *fiber.Ctx) error {
 	param := new(getProductDetailReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	productDetailData, err := m.getProductDetailService(param.ID)
+	isAdmin, err := am.HasRole(c, acc.ROLE_ADMIN)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	productDetailData, err := m.getProductDetailService(c.UserContext(), param.ID, false)
+	if err != nil {
+		return err
+	}
+	if !isAdmin && !productDetailData.IsVisible(time.Now()) {
+		return apperror.NotFound("product not found")
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: productDetailData,
 	})
@@ -106,53 +256,36 @@ func (m *Module) getProductDetail(c *fiber.Ctx) error {
 func (m *Module) addProduct(c *fiber.Ctx) error {
 	req := new(addProductReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+	if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		return apperror.Validation("unpublish_at must be after publish_at")
 	}
 
-	pcCount, err := m.getProductCategoryCountByProductID(req.CategoryID)
+	pcCount, err := m.getProductCategoryCountByProductID(c.UserContext(), req.CategoryID)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 	if *pcCount == 0 {
-		err := errors.New("category does not exist")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return apperror.Validation("category does not exist")
 	}
 
-	productDetailData, err := m.addProductService(&p.ProductModel{
+	productDetailData, err := m.addProductService(c.UserContext(), &p.ProductModel{
 		CategoryID:  req.CategoryID,
+		SKU:         req.SKU,
 		Title:       req.Title,
 		Description: req.Description,
 		Price:       req.Price,
+		Stock:       req.Stock,
+		Status:      req.Status,
+		PublishAt:   req.PublishAt,
+		UnpublishAt: req.UnpublishAt,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
 		Data: productDetailData,
 	})
@@ -161,72 +294,76 @@ func (m *Module) addProduct(c *fiber.Ctx) error {
 func (m *Module) updateProduct(c *fiber.Ctx) error {
 	param := new(updateProductReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(updateProductReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+	isClearPublishAt := req.IsClearPublishAt != nil && *req.IsClearPublishAt
+	isClearUnpublishAt := req.IsClearUnpublishAt != nil && *req.IsClearUnpublishAt
+	if (isClearPublishAt && req.PublishAt != nil) || (isClearUnpublishAt && req.UnpublishAt != nil) {
+		return apperror.Validation("a publishing time cannot be both set and cleared")
+	}
+	clearColumns := []string{}
+	if isClearPublishAt {
+		clearColumns = append(clearColumns, "publish_at")
+	}
+	if isClearUnpublishAt {
+		clearColumns = append(clearColumns, "unpublish_at")
 	}
 
-	pcCount, err := m.getProductCategoryCountByProductID(req.CategoryID)
-	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+	if req.PublishAt != nil || req.UnpublishAt != nil {
+		// The publishing window is checked against the stored bound that is not being updated.
+		productDetailData, err := m.getProductDetailService(c.UserContext(), param.ID, true)
+		if err != nil {
+			return err
+		}
+		publishAt, unpublishAt := productDetailData.PublishAt, productDetailData.UnpublishAt
+		if req.PublishAt != nil {
+			publishAt = req.PublishAt
+		}
+		if req.UnpublishAt != nil {
+			unpublishAt = req.UnpublishAt
+		}
+		if isClearPublishAt {
+			publishAt = nil
+		}
+		if isClearUnpublishAt {
+			unpublishAt = nil
+		}
+		if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+			return apperror.Validation("unpublish_at must be after publish_at")
+		}
 	}
-	if *pcCount == 0 {
-		err := errors.New("category does not exist")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+
+	if req.CategoryID != nil {
+		pcCount, err := m.getProductCategoryCountByProductID(c.UserContext(), req.CategoryID)
+		if err != nil {
+			return err
+		}
+		if *pcCount == 0 {
+			return apperror.Validation("category does not exist")
+		}
 	}
 
-	productDetailData, err := m.updateProductService(param.ID, &p.ProductModel{
+	productDetailData, err := m.updateProductService(c.UserContext(), param.ID, &p.ProductModel{
 		CategoryID:  req.CategoryID,
+		SKU:         req.SKU,
 		Title:       req.Title,
 		Description: req.Description,
 		Price:       req.Price,
-	})
+		Stock:       req.Stock,
+		Status:      req.Status,
+		PublishAt:   req.PublishAt,
+		UnpublishAt: req.UnpublishAt,
+	}, clearColumns)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: productDetailData,
 	})
@@ -235,35 +372,266 @@ func (m *Module) updateProduct(c *fiber.Ctx) error {
 func (m *Module) deleteProduct(c *fiber.Ctx) error {
 	param := new(deleteProductReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
+		return err
+	}
+
+	if err := m.deleteProductService(c.UserContext(), param.ID); err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: param.ID,
+	})
+}
+
+func (m *Module) addProductImage(c *fiber.Ctx) error {
+	param := new(addProductImageReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(addProductImageReq)
+	if err := parser.ParseReqMultipartForm(c, req); err != nil {
+		return err
+	}
+	if req.Image.Size > int64(m.ImageMaxSize) {
+		return apperror.Validation(fmt.Sprintf("image must not be larger than %d bytes", m.ImageMaxSize))
+	}
+
+	productCount, err := m.getProductCountByID(c.UserContext(), param.ID)
+	if err != nil {
+		return err
+	}
+	if *productCount == 0 {
+		return apperror.NotFound("product not found")
+	}
+
+	imageCount, err := m.getProductImageCountService(c.UserContext(), param.ID)
+	if err != nil {
+		return err
+	}
+	if *imageCount >= int64(m.ImageMaxCount) {
+		return apperror.Conflict(fmt.Sprintf("a product can have at most %d images", m.ImageMaxCount))
+	}
+
+	original, _, err := parser.ParseMultipartFileToBytes(req.Image)
+	if err != nil {
+		return err
+	}
+
+	// The declared content type is ignored, the format is sniffed from the data instead.
+	img, err := imaging.Decode(*original)
+	if err != nil {
+		return apperror.Validation(err.Error())
+	}
+
+	productImageData, err := m.addProductImageService(c.UserContext(), param.ID, *original, img)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
+		Data: productImageData,
+	})
+}
+
+func (m *Module) reorderProductImages(c *fiber.Ctx) error {
+	param := new(reorderProductImagesReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(reorderProductImagesReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	productImageListData, err := m.reorderProductImagesService(c.UserContext(), param.ID, *req.ImageIDs)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productImageListData,
+	})
+}
+
+func (m *Module) deleteProductImage(c *fiber.Ctx) error {
+	param := new(deleteProductImageReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	if err := m.deleteProductImageService(c.UserContext(), param.ID, param.ImageID); err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: param.ImageID,
+	})
+}
+
+func (m *Module) replaceProductVariants(c *fiber.Ctx) error {
+	param := new(replaceProductVariantsReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(replaceProductVariantsReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	productCount, err := m.getProductCountByID(c.UserContext(), param.ID)
+	if err != nil {
+		return err
+	}
+	if *productCount == 0 {
+		return apperror.NotFound("product not found")
+	}
+
+	options := make([]p.ProductOption, 0, len(*req.Options))
+	for _, option := range *req.Options {
+		options = append(options, p.ProductOption{
+			Name:   *option.Name,
+			Values: *option.Values,
 		})
 	}
 
-	if err := m.deleteProductService(param.ID); err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
+	variants := make([]*p.ProductVariantModel, 0, len(*req.Variants))
+	for i, variant := range *req.Variants {
+		variantOptions, err := newVariantOptions(options, variant.Options)
+		if err != nil {
+			return err
 		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
+		position := i
+		variants = append(variants, &p.ProductVariantModel{
+			ProductID: param.ID,
+			Options:   variantOptions,
+			SKU:       variant.SKU,
+			Price:     variant.Price,
+			Stock:     variant.Stock,
+			ImageID:   variant.ImageID,
+			Position:  &position,
 		})
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	productDetailData, err := m.replaceProductVariantsService(c.UserContext(), param.ID, options, variants)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
-		Data: param.ID,
+		Data: productDetailData,
 	})
 }
+
+func (m *Module) updateProductVariants(c *fiber.Ctx) error {
+	param := new(updateProductVariantsReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(updateProductVariantsReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	variants := make([]*p.ProductVariantModel, 0, len(*req.Variants))
+	for _, variant := range *req.Variants {
+		variantData := &p.ProductVariantModel{
+			SKU:     variant.SKU,
+			Price:   variant.Price,
+			Stock:   variant.Stock,
+			ImageID: variant.ImageID,
+		}
+		variantData.ID = variant.ID
+		variants = append(variants, variantData)
+	}
+
+	productDetailData, err := m.updateProductVariantsService(c.UserContext(), param.ID, variants)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productDetailData,
+	})
+}
+
+func (m *Module) importProducts(c *fiber.Ctx) error {
+	query := new(importProductsReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	req := new(importProductsReq)
+	if err := parser.ParseReqMultipartForm(c, req); err != nil {
+		return err
+	}
+
+	format := ""
+	if query.Format != nil {
+		format = *query.Format
+	} else {
+		switch strings.ToLower(filepath.Ext(req.File.Filename)) {
+		case ".csv":
+			format = PRODUCT_FILE_FORMAT_CSV
+		case ".jsonl", ".ndjson":
+			format = PRODUCT_FILE_FORMAT_JSONL
+		default:
+			return apperror.Validation("format must be given for files without a .csv or .jsonl extension")
+		}
+	}
+
+	data, _, err := parser.ParseMultipartFileToBytes(req.File)
+	if err != nil {
+		return err
+	}
+
+	locale := validator.Locale(c.Get(fiber.HeaderAcceptLanguage))
+	importProductsData, err := m.importProductsService(c.UserContext(), *data, format, query.IsDryRun != nil && *query.IsDryRun, locale)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: importProductsData,
+	})
+}
+
+func (m *Module) exportProducts(c *fiber.Ctx) error {
+	query := new(exportProductsReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	format := PRODUCT_FILE_FORMAT_CSV
+	if query.Format != nil {
+		format = *query.Format
+	}
+
+	c.Attachment("products." + format)
+	if format == PRODUCT_FILE_FORMAT_JSONL {
+		c.Set(fiber.HeaderContentType, "application/jsonl")
+	}
+
+	// The body is written after the handler returns, so the export is not bound by the request deadline. It stops
+	// when the client goes away, since flushing then fails.
+	ctx := context.WithoutCancel(c.UserContext())
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := m.exportProductsService(ctx, w, format); err != nil {
+			logger.Error(err)
+		}
+	})
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return nil
+}
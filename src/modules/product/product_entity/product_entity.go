@@ -1,28 +1,144 @@
 package productentity
 
 import (
+	"html"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"hilmy.dev/store/src/libs/db/pg"
 	applogger "hilmy.dev/store/src/libs/logger"
 	pc "hilmy.dev/store/src/modules/product_category/product_category_entity"
 )
 
+type ProductStatus string
+
+const (
+	STATUS_DRAFT     ProductStatus = "DRAFT"
+	STATUS_PUBLISHED ProductStatus = "PUBLISHED"
+	STATUS_ARCHIVED  ProductStatus = "ARCHIVED"
+)
+
 type ProductModel struct {
 	pg.Model
-	CategoryID  *uuid.UUID               `gorm:"not null" json:"categoryId,omitempty"`
-	Category    *pc.ProductCategoryModel `json:"category,omitempty"`
-	Title       *string                  `gorm:"not null" json:"title,omitempty"`
-	Description *string                  `gorm:"not null" json:"description,omitempty"`
-	Price       *int                     `gorm:"not null" json:"price,omitempty"`
+	CategoryID *uuid.UUID               `gorm:"not null" json:"categoryId,omitempty"`
+	Category   *pc.ProductCategoryModel `json:"category,omitempty"`
+	// SKU is optional, and is what product imports match the existing products on.
+	SKU         *string `gorm:"column:sku" json:"sku,omitempty"`
+	Title       *string `gorm:"not null" json:"title,omitempty"`
+	Description *string `gorm:"not null" json:"description,omitempty"`
+	Price       *int    `gorm:"not null" json:"price,omitempty"`
+	// Stock is nil when the stock of the product is not tracked. Products with variants use the stock of the variants
+	// instead.
+	Stock  *int           `gorm:"check:chk_products_stock,stock >= 0" json:"stock,omitempty"`
+	Status *ProductStatus `gorm:"not null;default:'DRAFT'" json:"status,omitempty"`
+	// A published product is only live from PublishAt and until UnpublishAt, when they are set.
+	PublishAt   *time.Time           `json:"publishAt,omitempty"`
+	UnpublishAt *time.Time           `json:"unpublishAt,omitempty"`
+	Images      []*ProductImageModel `gorm:"foreignKey:ProductID" json:"images,omitempty"`
+	// A product with options is only sold as one of its variants.
+	Options  datatypes.JSONSlice[ProductOption] `gorm:"type:jsonb;not null;default:'[]'" json:"options,omitempty"`
+	Variants []*ProductVariantModel             `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
+	// The rating only counts approved reviews, and is only ever changed by moderating them, so it is left out of
+	// inserts and upserts.
+	RatingCount   *int     `gorm:"<-:update;not null;default:0" json:"ratingCount,omitempty"`
+	RatingTotal   *int     `gorm:"<-:update;not null;default:0" json:"-"`
+	RatingAverage *float64 `gorm:"<-:update;not null;default:0" json:"ratingAverage,omitempty"`
+	// The search fields are computed by the query when searching, and are empty otherwise. The highlights wrap the
+	// matched words in <mark> once EscapeHighlights has escaped the rest of the text.
+	SearchRank           *float64 `gorm:"->;-:migration" json:"searchRank,omitempty"`
+	TitleHighlight       *string  `gorm:"->;-:migration" json:"titleHighlight,omitempty"`
+	DescriptionHighlight *string  `gorm:"->;-:migration" json:"descriptionHighlight,omitempty"`
 }
 
 func (ProductModel) TableName() string {
 	return "products"
 }
 
+// IsLive reports whether the product is published and inside its publishing window at now, which is when it is listed
+// and can be bought.
+func (m *ProductModel) IsLive(now time.Time) bool {
+	return m.Status != nil && *m.Status == STATUS_PUBLISHED &&
+		(m.PublishAt == nil || !m.PublishAt.After(now)) &&
+		(m.UnpublishAt == nil || m.UnpublishAt.After(now))
+}
+
+// IsVisible reports whether anyone can look the product up at now. A product stays visible once it has been
+// published, even after being archived or unpublished, so that past transactions can still link to it.
+func (m *ProductModel) IsVisible(now time.Time) bool {
+	return m.Status != nil && *m.Status != STATUS_DRAFT && (m.PublishAt == nil || !m.PublishAt.After(now))
+}
+
+// StockOf returns the stock an item of the product is sold from, which is the stock of variant for products with
+// variants. It is nil when the stock is not tracked.
+func (m *ProductModel) StockOf(variant *ProductVariantModel) *int {
+	if variant != nil {
+		return variant.Stock
+	}
+	return m.Stock
+}
+
+// TakeStockExprs takes amount off the stock of a product or a variant in place, and the returned condition keeps the
+// stock from going below zero, so that the update affects no row when too little is left. An untracked stock stays
+// NULL.
+func TakeStockExprs(amount int) (map[string]pg.Where, pg.Where) {
+	exprs := map[string]pg.Where{
+		"stock": {
+			Query: "stock - ?",
+			Args:  []interface{}{amount},
+		},
+	}
+	where := pg.Where{
+		Query: "(stock IS NULL OR stock >= ?)",
+		Args:  []interface{}{amount},
+	}
+	return exprs, where
+}
+
+// RatingExprs updates the rating columns in place to add a rating (change 1) or remove one (change -1), so that
+// concurrent moderations of reviews of the same product never overwrite each other.
+func RatingExprs(change int, rating int) map[string]pg.Where {
+	return map[string]pg.Where{
+		"rating_count": {
+			Query: "rating_count + ?",
+			Args:  []interface{}{change},
+		},
+		"rating_total": {
+			Query: "rating_total + ?",
+			Args:  []interface{}{change * rating},
+		},
+		"rating_average": {
+			Query: "CASE WHEN rating_count + ? > 0 THEN (rating_total + ?)::double precision / (rating_count + ?) ELSE 0 END",
+			Args:  []interface{}{change, change * rating, change},
+		},
+	}
+}
+
+// The search highlights are made with these delimiters, which no HTML escaping touches, and which EscapeHighlights turns
+// into <mark> tags.
+const (
+	HIGHLIGHT_START = "\x01"
+	HIGHLIGHT_STOP  = "\x02"
+)
+
+var highlightReplacer = strings.NewReplacer(HIGHLIGHT_START, "<mark>", HIGHLIGHT_STOP, "</mark>")
+
+// EscapeHighlights escapes the HTML in the title and description highlights, so that the only tags left in them are
+// the ones around the matched words.
+func (m *ProductModel) EscapeHighlights() {
+	for _, highlight := range []*string{m.TitleHighlight, m.DescriptionHighlight} {
+		if highlight != nil {
+			*highlight = highlightReplacer.Replace(html.EscapeString(*highlight))
+		}
+	}
+}
+
 type productDB = pg.Service[ProductModel]
 
 var productRepo *productDB
+var productImageRepo *productImageDB
+var productVariantRepo *productVariantDB
 var logger = applogger.New("ProductModule")
 
 func InitRepository(db *pg.DB) {
@@ -31,6 +147,8 @@ func InitRepository(db *pg.DB) {
 	}
 
 	productRepo = pg.NewService[ProductModel](db)
+	productImageRepo = pg.NewService[ProductImageModel](db)
+	productVariantRepo = pg.NewService[ProductVariantModel](db)
 }
 
 func ProductRepository() *productDB {
@@ -0,0 +1,126 @@
+package productentity
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+func TestEscapeHighlights(t *testing.T) {
+	title := `<script>alert(1)</script> ` + HIGHLIGHT_START + `red` + HIGHLIGHT_STOP + ` & "blue"`
+	description := HIGHLIGHT_START + `<b>` + HIGHLIGHT_STOP + `old`
+	m := &ProductModel{TitleHighlight: &title, DescriptionHighlight: &description}
+
+	m.EscapeHighlights()
+
+	if want := `&lt;script&gt;alert(1)&lt;/script&gt; <mark>red</mark> &amp; &#34;blue&#34;`; *m.TitleHighlight != want {
+		t.Errorf("TitleHighlight = %q, want %q", *m.TitleHighlight, want)
+	}
+	if want := `<mark>&lt;b&gt;</mark>old`; *m.DescriptionHighlight != want {
+		t.Errorf("DescriptionHighlight = %q, want %q", *m.DescriptionHighlight, want)
+	}
+
+	(&ProductModel{}).EscapeHighlights()
+}
+
+func TestRatingExprs(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		NowFunc: func() time.Time {
+			return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := pg.NewService[ProductModel](db)
+
+	tests := []struct {
+		name   string
+		change int
+		rating int
+		want   string
+	}{
+		{
+			name:   "add",
+			change: 1,
+			rating: 4,
+			want: `UPDATE "products" SET "rating_average"=CASE WHEN rating_count + 1 > 0 THEN (rating_total + 4)::double precision / (rating_count + 1) ELSE 0 END,` +
+				`"rating_count"=rating_count + 1,"rating_total"=rating_total + 4,"updated_at"='2026-01-02 03:04:05' WHERE id = 7`,
+		},
+		{
+			// Removing the last rating leaves an average of 0 instead of dividing by zero.
+			name:   "remove",
+			change: -1,
+			rating: 4,
+			want: `UPDATE "products" SET "rating_average"=CASE WHEN rating_count + -1 > 0 THEN (rating_total + -4)::double precision / (rating_count + -1) ELSE 0 END,` +
+				`"rating_count"=rating_count + -1,"rating_total"=rating_total + -4,"updated_at"='2026-01-02 03:04:05' WHERE id = 7`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := repo.UpdateExprTx(db, RatingExprs(tt.change, tt.rating), &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{7},
+					},
+				},
+				IsUnscoped: true,
+			})
+			if tx.Error != nil {
+				t.Fatal(tx.Error)
+			}
+			if got := db.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsLiveAndIsVisible(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		status      ProductStatus
+		publishAt   *time.Time
+		unpublishAt *time.Time
+		wantLive    bool
+		wantVisible bool
+	}{
+		{name: "published", status: STATUS_PUBLISHED, wantLive: true, wantVisible: true},
+		{name: "draft", status: STATUS_DRAFT},
+		{name: "draft in its window", status: STATUS_DRAFT, publishAt: &before, unpublishAt: &after},
+		// Archived products stay visible for the transactions that link to them, but cannot be bought.
+		{name: "archived", status: STATUS_ARCHIVED, wantVisible: true},
+		{name: "archived before publishing", status: STATUS_ARCHIVED, publishAt: &after},
+		{name: "inside the window", status: STATUS_PUBLISHED, publishAt: &before, unpublishAt: &after, wantLive: true, wantVisible: true},
+		{name: "publishing now", status: STATUS_PUBLISHED, publishAt: &now, wantLive: true, wantVisible: true},
+		{name: "scheduled", status: STATUS_PUBLISHED, publishAt: &after},
+		{name: "unpublishing now", status: STATUS_PUBLISHED, unpublishAt: &now, wantVisible: true},
+		{name: "unpublished", status: STATUS_PUBLISHED, publishAt: &before, unpublishAt: &before, wantVisible: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ProductModel{Status: &tt.status, PublishAt: tt.publishAt, UnpublishAt: tt.unpublishAt}
+			if got := m.IsLive(now); got != tt.wantLive {
+				t.Errorf("IsLive = %v, want %v", got, tt.wantLive)
+			}
+			if got := m.IsVisible(now); got != tt.wantVisible {
+				t.Errorf("IsVisible = %v, want %v", got, tt.wantVisible)
+			}
+		})
+	}
+
+	if (&ProductModel{}).IsLive(now) || (&ProductModel{}).IsVisible(now) {
+		t.Error("a product without a status is live or visible")
+	}
+}
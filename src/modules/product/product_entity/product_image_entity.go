@@ -0,0 +1,38 @@
+package productentity
+
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+// ProductImageModel is an uploaded image of a product, stored as the original file and resized variants. Only the
+// storage keys are persisted; the URLs are filled in from the storage when the image is returned.
+type ProductImageModel struct {
+	pg.Model
+	ProductID    *uuid.UUID `gorm:"not null" json:"productId,omitempty"`
+	Position     *int       `gorm:"not null" json:"position,omitempty"`
+	ContentType  *string    `gorm:"not null" json:"contentType,omitempty"`
+	Width        *int       `gorm:"not null" json:"width,omitempty"`
+	Height       *int       `gorm:"not null" json:"height,omitempty"`
+	Size         *int64     `gorm:"not null" json:"size,omitempty"`
+	OriginalKey  *string    `gorm:"not null" json:"-"`
+	ThumbnailKey *string    `gorm:"not null" json:"-"`
+	MediumKey    *string    `gorm:"not null" json:"-"`
+	URL          *string    `gorm:"-" json:"url,omitempty"`
+	ThumbnailURL *string    `gorm:"-" json:"thumbnailUrl,omitempty"`
+	MediumURL    *string    `gorm:"-" json:"mediumUrl,omitempty"`
+}
+
+func (ProductImageModel) TableName() string {
+	return "product_images"
+}
+
+type productImageDB = pg.Service[ProductImageModel]
+
+func ProductImageRepository() *productImageDB {
+	if productImageRepo == nil {
+		logger.Panic("productImageRepo is nil")
+	}
+
+	return productImageRepo
+}
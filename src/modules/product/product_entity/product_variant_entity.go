@@ -0,0 +1,49 @@
+package productentity
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+// ProductOption is a choice a product is sold with, such as its size, and the values it can take.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductVariantOption is the value a variant takes for one of the options of its product.
+type ProductVariantOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ProductVariantModel is a combination of option values that is sold as its own item, with one value for each option
+// of the product, in the order of the options.
+type ProductVariantModel struct {
+	pg.Model
+	ProductID *uuid.UUID                                `gorm:"not null" json:"productId,omitempty"`
+	Options   datatypes.JSONSlice[ProductVariantOption] `gorm:"type:jsonb;not null" json:"options,omitempty"`
+	SKU       *string                                   `gorm:"column:sku;not null" json:"sku,omitempty"`
+	// Price overrides the price of the product when set.
+	Price *int `gorm:"check:chk_product_variants_price,price >= 0" json:"price,omitempty"`
+	// Stock is nil when the stock of the variant is not tracked.
+	Stock    *int               `gorm:"check:chk_product_variants_stock,stock >= 0" json:"stock,omitempty"`
+	ImageID  *uuid.UUID         `json:"imageId,omitempty"`
+	Image    *ProductImageModel `json:"image,omitempty"`
+	Position *int               `gorm:"not null" json:"position,omitempty"`
+}
+
+func (ProductVariantModel) TableName() string {
+	return "product_variants"
+}
+
+type productVariantDB = pg.Service[ProductVariantModel]
+
+func ProductVariantRepository() *productVariantDB {
+	if productVariantRepo == nil {
+		logger.Panic("productVariantRepo is nil")
+	}
+
+	return productVariantRepo
+}
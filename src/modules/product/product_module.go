@@ -3,12 +3,20 @@ package product
 import (
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/libs/db/pg"
+	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/storage"
 	p "hilmy.dev/store/src/modules/product/product_entity"
 )
 
+var logger = applogger.New("ProductModule")
+
 type Module struct {
-	App *fiber.App
-	DB  *pg.DB
+	App     *fiber.App
+	DB      *pg.DB
+	Storage storage.Storage
+	// ImageMaxSize is the largest accepted image in bytes, and ImageMaxCount the number of images a product may have.
+	ImageMaxSize  int
+	ImageMaxCount int
 }
 
 func Load(module *Module) {
@@ -1,42 +1,73 @@
 package product
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/imaging"
+	"hilmy.dev/store/src/libs/validator"
 	p "hilmy.dev/store/src/modules/product/product_entity"
 	pc "hilmy.dev/store/src/modules/product_category/product_category_entity"
 )
 
 type searchOptions struct {
-	byCategoryID *uuid.UUID
+	filters       *[]pg.FindAllWhere
+	byCategoryIDs []uuid.UUID
+	minPrice      *int
+	maxPrice      *int
+	isInStock     bool
+	byStatus      *p.ProductStatus
+	isLiveOnly    bool
+	query         *string
 }
 
 type paginationOptions struct {
-	limit  *int
-	offset *int
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
 }
 
 type paginationQuery struct {
 	limit *int
 	count *int
 	total *int
+	next  *string
+	prev  *string
 }
 
-func (*Module) getProductListService(pagination *paginationOptions, search *searchOptions) (*[]*p.ProductModel, *paginationQuery, error) {
+func (m *Module) getProductListService(ctx context.Context, pagination *paginationOptions, search *searchOptions) (*[]*p.ProductModel, *paginationQuery, error) {
 	where := []pg.FindAllWhere{}
 	limit := 0
 	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
 
-	if search != nil {
-		if search.byCategoryID != nil && len(*search.byCategoryID) > 0 {
-			where = append(where, pg.FindAllWhere{
-				Where: pg.Where{
-					Query: "category_id = ?",
-					Args:  []interface{}{search.byCategoryID},
-				},
-				IncludeInCount: true,
-			})
-		}
+	conditions := newProductConditions(search)
+	for _, condition := range conditions.all() {
+		where = append(where, pg.FindAllWhere{
+			Where:          condition,
+			IncludeInCount: true,
+		})
 	}
 
 	if pagination != nil {
@@ -46,27 +77,350 @@ func (*Module) getProductListService(pagination *paginationOptions, search *sear
 		if pagination.offset != nil && *pagination.offset > 0 {
 			offset = *pagination.offset
 		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
+	}
+
+	var selectQuery *pg.Where
+	if conditions.search != nil {
+		selectQuery = productSearchSelect(conditions.search.tsQuery, conditions.search.text)
+		// Without an explicit sort, the best matches come first.
+		if keyset == nil && order == nil {
+			order = &[]string{"search_rank DESC", "id"}
+		}
+	} else if keyset == nil && order == nil {
+		order = &[]string{"title", "id"}
 	}
 
-	data, page, err := p.ProductRepository().FindAll(&pg.FindAllOptions{
+	data, page, err := p.ProductRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
 		Where:  &where,
 		Limit:  &limit,
 		Offset: &offset,
-		Order:  &[]string{"title asc"},
+		Keyset: keyset,
+		Order:  order,
+		Cursor: cursor,
+		Select: selectQuery,
+		IncludeTables: &[]pg.IncludeTables{
+			{
+				Query: "Images",
+				Args:  []interface{}{imagesByPosition},
+			},
+		},
+		IsSkipCount: isSkipCount,
 	})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	for _, product := range *data {
+		m.setImageURLs(product.Images)
+		product.EscapeHighlights()
+	}
+
 	return data, &paginationQuery{
 		limit: &page.Limit,
 		count: &page.Count,
-		total: &page.Total,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
 	}, nil
 }
 
-func (*Module) getProductDetailService(id *uuid.UUID) (*p.ProductModel, error) {
-	return p.ProductRepository().FindOne(&pg.FindOneOptions{
+type productSearch struct {
+	tsQuery string
+	text    string
+}
+
+// productConditions keeps the conditions on the category and on the price apart from the rest, so that each facet
+// can count as if its own filter was not applied.
+type productConditions struct {
+	common   []pg.Where
+	category []pg.Where
+	price    []pg.Where
+	search   *productSearch
+}
+
+func newProductConditions(search *searchOptions) *productConditions {
+	conditions := &productConditions{}
+	if search == nil {
+		return conditions
+	}
+
+	if search.filters != nil {
+		for _, filter := range *search.filters {
+			conditions.common = append(conditions.common, filter.Where)
+		}
+	}
+	if search.query != nil {
+		if tsQuery, ok := pg.PrefixTSQuery(*search.query); ok {
+			conditions.search = &productSearch{tsQuery: tsQuery, text: *search.query}
+			conditions.common = append(conditions.common, productSearchWhere(tsQuery, *search.query))
+		}
+	}
+	if search.isLiveOnly {
+		conditions.common = append(conditions.common, productLiveWhere())
+	}
+	if search.byStatus != nil {
+		conditions.common = append(conditions.common, pg.Where{
+			Query: "products.status = ?",
+			Args:  []interface{}{*search.byStatus},
+		})
+	}
+	if search.isInStock {
+		// A product with variants is in stock when any of its variants is.
+		conditions.common = append(conditions.common, pg.Where{
+			Query: "(CASE WHEN jsonb_array_length(products.options) > 0 " +
+				"THEN EXISTS (SELECT 1 FROM product_variants WHERE product_variants.product_id = products.id AND product_variants.deleted_at IS NULL AND (product_variants.stock IS NULL OR product_variants.stock > 0)) " +
+				"ELSE (products.stock IS NULL OR products.stock > 0) END)",
+		})
+	}
+	if len(search.byCategoryIDs) > 0 {
+		// A category matches the products of its subcategories too.
+		conditions.category = append(conditions.category, pg.Where{
+			Query: "category_id IN (SELECT descendant.id FROM product_categories AS descendant " +
+				"JOIN product_categories AS ancestor ON descendant.path LIKE ancestor.path || '%' WHERE ancestor.id IN ?)",
+			Args: []interface{}{search.byCategoryIDs},
+		})
+	}
+	if search.minPrice != nil {
+		conditions.price = append(conditions.price, pg.Where{
+			Query: "price >= ?",
+			Args:  []interface{}{*search.minPrice},
+		})
+	}
+	if search.maxPrice != nil {
+		conditions.price = append(conditions.price, pg.Where{
+			Query: "price <= ?",
+			Args:  []interface{}{*search.maxPrice},
+		})
+	}
+
+	return conditions
+}
+
+// productLiveWhere matches the products that ProductModel.IsLive reports as live.
+func productLiveWhere() pg.Where {
+	return pg.Where{
+		Query: "(products.status = ? AND (products.publish_at IS NULL OR products.publish_at <= now()) " +
+			"AND (products.unpublish_at IS NULL OR products.unpublish_at > now()))",
+		Args: []interface{}{p.STATUS_PUBLISHED},
+	}
+}
+
+func (c *productConditions) all() []pg.Where {
+	return slices.Concat(c.common, c.category, c.price)
+}
+
+// priceFacetBounds split prices into the buckets counted by the price facet.
+var priceFacetBounds = []int{50_000, 100_000, 250_000, 500_000, 1_000_000}
+
+// maxCategoryFacets bounds the category facet to the categories with the most matches.
+const maxCategoryFacets = 50
+
+func (*Module) getProductFacetsService(ctx context.Context, search *searchOptions) (*productFacets, error) {
+	conditions := newProductConditions(search)
+	facets := &productFacets{
+		Categories: []*productCategoryFacet{},
+		Prices:     []*productPriceFacet{},
+	}
+
+	categoryWhere := slices.Concat(conditions.common, conditions.price)
+	categoryCounts, err := p.ProductRepository().WithContext(ctx).CountBy(&pg.CountByOptions{
+		Where: &categoryWhere,
+		GroupBy: pg.Where{
+			Query: "category_id::text",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(*categoryCounts, func(a *pg.GroupCount, b *pg.GroupCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	if len(*categoryCounts) > maxCategoryFacets {
+		*categoryCounts = (*categoryCounts)[:maxCategoryFacets]
+	}
+
+	categoryIDs := make([]uuid.UUID, 0, len(*categoryCounts))
+	for _, categoryCount := range *categoryCounts {
+		categoryID, err := uuid.Parse(categoryCount.Key)
+		if err != nil {
+			return nil, err
+		}
+		categoryIDs = append(categoryIDs, categoryID)
+		facets.Categories = append(facets.Categories, &productCategoryFacet{
+			CategoryID: &categoryID,
+			Count:      categoryCount.Count,
+		})
+	}
+	if len(categoryIDs) > 0 {
+		limit := maxCategoryFacets
+		categories, _, err := pc.ProductCategoryRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where: &[]pg.FindAllWhere{
+				{
+					Where: pg.Where{
+						Query: "id IN ?",
+						Args:  []interface{}{categoryIDs},
+					},
+					IncludeInCount: true,
+				},
+			},
+			Limit:       &limit,
+			IsSkipCount: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[uuid.UUID]*string, len(*categories))
+		for _, category := range *categories {
+			names[*category.ID] = category.Name
+		}
+		for _, facet := range facets.Categories {
+			facet.Name = names[*facet.CategoryID]
+		}
+	}
+
+	// The bucket of a price is the number of bounds it reaches.
+	bucketQuery := "CASE"
+	bucketArgs := []interface{}{}
+	for i, bound := range priceFacetBounds {
+		bucketQuery += fmt.Sprintf(" WHEN price < ? THEN %d", i)
+		bucketArgs = append(bucketArgs, bound)
+	}
+	bucketQuery += fmt.Sprintf(" ELSE %d END", len(priceFacetBounds))
+
+	priceWhere := slices.Concat(conditions.common, conditions.category)
+	priceCounts, err := p.ProductRepository().WithContext(ctx).CountBy(&pg.CountByOptions{
+		Where: &priceWhere,
+		GroupBy: pg.Where{
+			Query: bucketQuery,
+			Args:  bucketArgs,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	bucketCounts := make(map[string]int64, len(*priceCounts))
+	for _, priceCount := range *priceCounts {
+		bucketCounts[priceCount.Key] = priceCount.Count
+	}
+	for i := 0; i <= len(priceFacetBounds); i++ {
+		facet := &productPriceFacet{Count: bucketCounts[strconv.Itoa(i)]}
+		if i > 0 {
+			facet.Min = &priceFacetBounds[i-1]
+		}
+		if i < len(priceFacetBounds) {
+			facet.Max = &priceFacetBounds[i]
+		}
+		facets.Prices = append(facets.Prices, facet)
+	}
+
+	return facets, nil
+}
+
+// productSearchWhere matches products containing every word of the search as a prefix, or whose title is close to it
+// despite typos.
+func productSearchWhere(tsQuery string, text string) pg.Where {
+	return pg.Where{
+		Query: "(search_vector @@ to_tsquery('simple', ?) OR ? <% title)",
+		Args:  []interface{}{tsQuery, text},
+	}
+}
+
+// productSearchSelect adds the rank that search results are ordered by and the highlighted title and description. The
+// highlights are delimited with p.HIGHLIGHT_START and p.HIGHLIGHT_STOP rather than tags, since the text around them is
+// not escaped yet, which ProductModel.EscapeHighlights does.
+func productSearchSelect(tsQuery string, text string) *pg.Where {
+	delimiters := `StartSel="` + p.HIGHLIGHT_START + `", StopSel="` + p.HIGHLIGHT_STOP + `"`
+	return &pg.Where{
+		Query: "products.*, " +
+			"ts_rank_cd(search_vector, to_tsquery('simple', ?)) + word_similarity(?, title) AS search_rank, " +
+			"ts_headline('simple', title, to_tsquery('simple', ?), ?) AS title_highlight, " +
+			"ts_headline('simple', description, to_tsquery('simple', ?), ?) AS description_highlight",
+		Args: []interface{}{
+			tsQuery, text,
+			tsQuery, delimiters + ", HighlightAll=true",
+			tsQuery, delimiters + ", MaxFragments=2, MaxWords=20, MinWords=5",
+		},
+	}
+}
+
+func (*Module) getProductSuggestionsService(ctx context.Context, text string, limit int) (*productSuggestions, error) {
+	suggestions := &productSuggestions{
+		Products:   []*productSuggestion{},
+		Categories: []*productSuggestion{},
+	}
+
+	tsQuery, ok := pg.PrefixTSQuery(text)
+	if !ok {
+		return suggestions, nil
+	}
+
+	products, _, err := p.ProductRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where: &[]pg.FindAllWhere{
+			{
+				Where:          productSearchWhere(tsQuery, text),
+				IncludeInCount: true,
+			},
+			{
+				Where:          productLiveWhere(),
+				IncludeInCount: true,
+			},
+		},
+		Select:      productSearchSelect(tsQuery, text),
+		Order:       &[]string{"search_rank DESC", "id"},
+		Limit:       &limit,
+		IsSkipCount: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range *products {
+		product.EscapeHighlights()
+		suggestions.Products = append(suggestions.Products, &productSuggestion{
+			ID:        product.ID,
+			Text:      product.Title,
+			Highlight: product.TitleHighlight,
+		})
+	}
+
+	categories, _, err := pc.ProductCategoryRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where: &[]pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "(to_tsvector('simple', name) @@ to_tsquery('simple', ?) OR ? <% name)",
+					Args:  []interface{}{tsQuery, text},
+				},
+				IncludeInCount: true,
+			},
+		},
+		Select: &pg.Where{
+			Query: "product_categories.*, word_similarity(?, name) AS search_rank",
+			Args:  []interface{}{text},
+		},
+		Order:       &[]string{"search_rank DESC", "name"},
+		Limit:       &limit,
+		IsSkipCount: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, category := range *categories {
+		suggestions.Categories = append(suggestions.Categories, &productSuggestion{
+			ID:   category.ID,
+			Text: category.Name,
+		})
+	}
+
+	return suggestions, nil
+}
+
+// getProductDetailService reads from the primary when isPrimary is set, for when a write is based on the product or
+// has just been made to it.
+func (m *Module) getProductDetailService(ctx context.Context, id *uuid.UUID, isPrimary bool) (*p.ProductModel, error) {
+	data, err := p.ProductRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
@@ -77,52 +431,135 @@ func (*Module) getProductDetailService(id *uuid.UUID) (*p.ProductModel, error) {
 			{
 				Query: "Category",
 			},
+			{
+				Query: "Images",
+				Args:  []interface{}{imagesByPosition},
+			},
+			{
+				Query: "Variants",
+				Args:  []interface{}{variantsByPosition},
+			},
+			{
+				Query: "Variants.Image",
+			},
 		},
 		IsUnscoped: true,
+		IsPrimary:  isPrimary,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product not found")
+	}
+
+	m.setImageURLs(data.Images)
+	for _, variant := range data.Variants {
+		if variant.Image != nil {
+			m.setImageURLs([]*p.ProductImageModel{variant.Image})
+		}
+	}
+
+	return data, nil
 }
 
-func (*Module) addProductService(data *p.ProductModel) (*p.ProductModel, error) {
-	return p.ProductRepository().Create(data)
+func (*Module) addProductService(ctx context.Context, data *p.ProductModel) (*p.ProductModel, error) {
+	return p.ProductRepository().WithContext(ctx).Create(data)
 }
 
-func (*Module) updateProductService(id *uuid.UUID, data *p.ProductModel) (*p.ProductModel, error) {
-	if _, err := p.ProductRepository().Update(data, &pg.UpdateOptions{
+// updateProductService writes the fields that are set in data, and sets the columns of clearColumns to NULL, in one
+// transaction.
+func (m *Module) updateProductService(ctx context.Context, id *uuid.UUID, data *p.ProductModel, clearColumns []string) (*p.ProductModel, error) {
+	byID := &pg.UpdateOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
-	}); err != nil {
-		return nil, err
+	}
+	txs := []func(tx *pg.DB) *pg.DB{
+		func(tx *pg.DB) *pg.DB {
+			result := p.ProductRepository().UpdateTx(tx, data, byID)
+			if result.Error == nil && result.RowsAffected == 0 {
+				result.AddError(pg.ErrRecordNotFound)
+			}
+			return result
+		},
+	}
+	if len(clearColumns) > 0 {
+		exprs := make(map[string]pg.Where, len(clearColumns))
+		for _, column := range clearColumns {
+			exprs[column] = pg.Where{Query: "NULL"}
+		}
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductRepository().UpdateExprTx(tx, exprs, byID)
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		return nil, apperror.NotFoundOr(err, "product not found")
 	}
 
-	data, err := p.ProductRepository().FindOne(&pg.FindOneOptions{
+	data, err := p.ProductRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+		IsPrimary: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "product not found")
 	}
 
 	return data, nil
 }
 
-func (*Module) deleteProductService(id *uuid.UUID) error {
-	return p.ProductRepository().Destroy(&p.ProductModel{
+func (*Module) deleteProductService(ctx context.Context, id *uuid.UUID) error {
+	if err := p.ProductRepository().WithContext(ctx).Destroy(&p.ProductModel{
 		Model: pg.Model{
 			ID: id,
 		},
+	}); err != nil {
+		return apperror.NotFoundOr(err, "product not found")
+	}
+
+	return nil
+}
+
+func (*Module) getProductCategoryCountByProductID(ctx context.Context, id *uuid.UUID) (*int64, error) {
+	return pc.ProductCategoryRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{id},
+			},
+		},
 	})
 }
 
-func (*Module) getProductCategoryCountByProductID(id *uuid.UUID) (*int64, error) {
-	return pc.ProductCategoryRepository().Count(&pg.CountOptions{
+// Every uploaded image gets resized variants whose longest side is at most these sizes in pixels.
+const (
+	thumbnailImageSize = 320
+	mediumImageSize    = 960
+)
+
+func imagesByPosition(db *pg.DB) *pg.DB {
+	return db.Order("position").Order("created_at")
+}
+
+func (m *Module) setImageURLs(images []*p.ProductImageModel) {
+	for _, image := range images {
+		url := m.Storage.URL(*image.OriginalKey)
+		thumbnailURL := m.Storage.URL(*image.ThumbnailKey)
+		mediumURL := m.Storage.URL(*image.MediumKey)
+		image.URL = &url
+		image.ThumbnailURL = &thumbnailURL
+		image.MediumURL = &mediumURL
+	}
+}
+
+func (*Module) getProductCountByID(ctx context.Context, id *uuid.UUID) (*int64, error) {
+	return p.ProductRepository().WithContext(ctx).Count(&pg.CountOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
@@ -131,3 +568,888 @@ func (*Module) getProductCategoryCountByProductID(id *uuid.UUID) (*int64, error)
 		},
 	})
 }
+
+func (*Module) getProductImageCountService(ctx context.Context, productID *uuid.UUID) (*int64, error) {
+	return p.ProductImageRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ?",
+				Args:  []interface{}{productID},
+			},
+		},
+	})
+}
+
+// addProductImageService stores the original image with its variants and appends it to the images of the product.
+// Stored files are removed again when a later step fails.
+func (m *Module) addProductImageService(ctx context.Context, productID *uuid.UUID, original []byte, img *imaging.Image) (*p.ProductImageModel, error) {
+	thumbnail, err := imaging.EncodeVariant(imaging.Fit(img, thumbnailImageSize))
+	if err != nil {
+		return nil, err
+	}
+	medium, err := imaging.EncodeVariant(imaging.Fit(img, mediumImageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	last, err := p.ProductImageRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ?",
+				Args:  []interface{}{productID},
+			},
+		},
+		Order:     &[]string{"position DESC"},
+		IsPrimary: true,
+	})
+	if err != nil && !pg.IsErrRecordNotFound(err) {
+		return nil, err
+	}
+	if last != nil {
+		position = *last.Position + 1
+	}
+
+	imageID := uuid.New()
+	prefix := "products/" + productID.String() + "/" + imageID.String() + "/"
+	files := []struct {
+		key         string
+		body        []byte
+		contentType string
+	}{
+		{key: prefix + "original" + img.Extension, body: original, contentType: img.ContentType},
+		{key: prefix + "thumbnail" + thumbnail.Extension, body: thumbnail.Data, contentType: thumbnail.ContentType},
+		{key: prefix + "medium" + medium.Extension, body: medium.Data, contentType: medium.ContentType},
+	}
+
+	stored := []string{}
+	removeStored := func() {
+		for _, key := range stored {
+			m.Storage.Delete(context.WithoutCancel(ctx), key)
+		}
+	}
+	for _, file := range files {
+		if err := m.Storage.Put(ctx, file.key, file.body, file.contentType); err != nil {
+			removeStored()
+			return nil, err
+		}
+		stored = append(stored, file.key)
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	size := int64(len(original))
+	data, err := p.ProductImageRepository().WithContext(ctx).Create(&p.ProductImageModel{
+		Model: pg.Model{
+			ID: &imageID,
+		},
+		ProductID:    productID,
+		Position:     &position,
+		ContentType:  &img.ContentType,
+		Width:        &width,
+		Height:       &height,
+		Size:         &size,
+		OriginalKey:  &files[0].key,
+		ThumbnailKey: &files[1].key,
+		MediumKey:    &files[2].key,
+	})
+	if err != nil {
+		removeStored()
+		return nil, err
+	}
+
+	m.setImageURLs([]*p.ProductImageModel{data})
+	return data, nil
+}
+
+// reorderProductImagesService sets the position of every image of the product to its index in imageIDs, which has to
+// list each of them exactly once.
+func (m *Module) reorderProductImagesService(ctx context.Context, productID *uuid.UUID, imageIDs []uuid.UUID) ([]*p.ProductImageModel, error) {
+	limit := pg.FindAllMaximumLimit
+	images, _, err := p.ProductImageRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where: &[]pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "product_id = ?",
+					Args:  []interface{}{productID},
+				},
+				IncludeInCount: true,
+			},
+		},
+		Limit:       &limit,
+		IsSkipCount: true,
+		IsPrimary:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	imagesByID := make(map[uuid.UUID]*p.ProductImageModel, len(*images))
+	for _, image := range *images {
+		imagesByID[*image.ID] = image
+	}
+	if len(imageIDs) != len(imagesByID) {
+		return nil, apperror.Validation("image_ids must list every image of the product exactly once")
+	}
+
+	ordered := make([]*p.ProductImageModel, 0, len(imageIDs))
+	txs := make([]func(tx *pg.DB) *pg.DB, 0, len(imageIDs))
+	for i, imageID := range imageIDs {
+		image, ok := imagesByID[imageID]
+		if !ok {
+			return nil, apperror.Validation("image_ids must list every image of the product exactly once")
+		}
+		delete(imagesByID, imageID)
+
+		position := i
+		image.Position = &position
+		ordered = append(ordered, image)
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductImageRepository().UpdateTx(tx.WithContext(ctx), &p.ProductImageModel{Position: &position}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{image.ID},
+					},
+				},
+			})
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		return nil, err
+	}
+
+	m.setImageURLs(ordered)
+	return ordered, nil
+}
+
+// deleteProductImageService removes the image record for good and then its files. A file that cannot be removed is
+// only logged, since the image is already gone for clients.
+func (m *Module) deleteProductImageService(ctx context.Context, productID *uuid.UUID, imageID *uuid.UUID) error {
+	image, err := p.ProductImageRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{imageID},
+			},
+			{
+				Query: "product_id = ?",
+				Args:  []interface{}{productID},
+			},
+		},
+		IsPrimary: true,
+	})
+	if err != nil {
+		return apperror.NotFoundOr(err, "product image not found")
+	}
+
+	if err := p.ProductImageRepository().WithContext(ctx).Destroy(image, &pg.DestroyOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{imageID},
+			},
+		},
+		IsUnscoped: true,
+	}); err != nil {
+		return apperror.NotFoundOr(err, "product image not found")
+	}
+
+	for _, key := range []*string{image.OriginalKey, image.ThumbnailKey, image.MediumKey} {
+		m.Storage.Delete(context.WithoutCancel(ctx), *key)
+	}
+
+	return nil
+}
+
+// variantsByPosition also leaves out deleted variants, which the unscoped product detail would preload otherwise.
+func variantsByPosition(db *pg.DB) *pg.DB {
+	return db.Where("deleted_at IS NULL").Order("position")
+}
+
+// newVariantOptions puts the option values chosen by a variant in the order of the options of its product, checking
+// that there is a known value for every option.
+func newVariantOptions(options []p.ProductOption, values map[string]string) (datatypes.JSONSlice[p.ProductVariantOption], error) {
+	if len(values) != len(options) {
+		return nil, apperror.Validation("a variant must have exactly one value for every option of the product")
+	}
+
+	variantOptions := make(datatypes.JSONSlice[p.ProductVariantOption], 0, len(options))
+	for _, option := range options {
+		value, ok := values[option.Name]
+		if !ok {
+			return nil, apperror.Validation(fmt.Sprintf("a variant has no value for the option %q", option.Name))
+		}
+		if !slices.Contains(option.Values, value) {
+			return nil, apperror.Validation(fmt.Sprintf("%q is not a value of the option %q", value, option.Name))
+		}
+		variantOptions = append(variantOptions, p.ProductVariantOption{Name: option.Name, Value: value})
+	}
+
+	return variantOptions, nil
+}
+
+// variantKey identifies a variant by its option values within its product.
+func variantKey(options datatypes.JSONSlice[p.ProductVariantOption]) string {
+	parts := make([]string, 0, len(options))
+	for _, option := range options {
+		parts = append(parts, option.Name+"\x00"+option.Value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// checkProductImageIDs makes sure the images chosen for variants belong to their product.
+func (*Module) checkProductImageIDs(ctx context.Context, productID *uuid.UUID, variants []*p.ProductVariantModel) error {
+	imageIDs := []uuid.UUID{}
+	for _, variant := range variants {
+		if variant.ImageID != nil && !slices.Contains(imageIDs, *variant.ImageID) {
+			imageIDs = append(imageIDs, *variant.ImageID)
+		}
+	}
+	if len(imageIDs) == 0 {
+		return nil
+	}
+
+	imageCount, err := p.ProductImageRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ? AND id IN ?",
+				Args:  []interface{}{productID, imageIDs},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if *imageCount != int64(len(imageIDs)) {
+		return apperror.Validation("the image of a variant must be an image of the product")
+	}
+
+	return nil
+}
+
+// replaceProductVariantsService sets the options of the product and replaces its variants with the given ones in a
+// single transaction. Existing variants with the same option values are updated in place, and the others are deleted,
+// which makes shopping cart items pointing at them fail at checkout.
+func (m *Module) replaceProductVariantsService(ctx context.Context, productID *uuid.UUID, options []p.ProductOption, variants []*p.ProductVariantModel) (*p.ProductModel, error) {
+	optionNames := map[string]bool{}
+	for _, option := range options {
+		if optionNames[option.Name] {
+			return nil, apperror.Validation(fmt.Sprintf("the option %q is given more than once", option.Name))
+		}
+		optionNames[option.Name] = true
+	}
+	if (len(options) == 0) != (len(variants) == 0) {
+		return nil, apperror.Validation("a product with options must have variants, and a product without options cannot have any")
+	}
+
+	keys := map[string]bool{}
+	skus := map[string]bool{}
+	for _, variant := range variants {
+		key := variantKey(variant.Options)
+		if keys[key] {
+			return nil, apperror.Validation("two variants have the same option values")
+		}
+		keys[key] = true
+		if skus[*variant.SKU] {
+			return nil, apperror.Validation(fmt.Sprintf("the sku %q is given more than once", *variant.SKU))
+		}
+		skus[*variant.SKU] = true
+	}
+
+	if err := m.checkProductImageIDs(ctx, productID, variants); err != nil {
+		return nil, err
+	}
+
+	limit := pg.FindAllMaximumLimit
+	existingVariants, _, err := p.ProductVariantRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where: &[]pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "product_id = ?",
+					Args:  []interface{}{productID},
+				},
+				IncludeInCount: true,
+			},
+		},
+		Limit:       &limit,
+		IsSkipCount: true,
+		IsPrimary:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	existingIDs := make(map[string]*uuid.UUID, len(*existingVariants))
+	for _, variant := range *existingVariants {
+		existingIDs[variantKey(variant.Options)] = variant.ID
+	}
+
+	txs := []func(tx *pg.DB) *pg.DB{
+		func(tx *pg.DB) *pg.DB {
+			return p.ProductRepository().UpdateTx(tx, &p.ProductModel{Options: options}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{productID},
+					},
+				},
+				Columns: &[]string{"options"},
+			})
+		},
+	}
+
+	// Deleted variants go first, so that their SKUs are free for the remaining ones.
+	removedIDs := []*uuid.UUID{}
+	for key, id := range existingIDs {
+		if !keys[key] {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	if len(removedIDs) > 0 {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductVariantRepository().DestroyTx(tx, &p.ProductVariantModel{}, &pg.DestroyOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id IN ?",
+						Args:  []interface{}{removedIDs},
+					},
+				},
+			})
+		})
+	}
+
+	createdVariants := []*p.ProductVariantModel{}
+	for _, variant := range variants {
+		id, ok := existingIDs[variantKey(variant.Options)]
+		if !ok {
+			createdVariants = append(createdVariants, variant)
+			continue
+		}
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			// Every column is written, so that unset prices, stocks and images are cleared.
+			return p.ProductVariantRepository().UpdateTx(tx, variant, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{id},
+					},
+				},
+				Columns: &[]string{"options", "sku", "price", "stock", "image_id", "position"},
+			})
+		})
+	}
+	if len(createdVariants) > 0 {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductVariantRepository().BulkCreateTx(tx, &createdVariants)
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		return nil, err
+	}
+
+	return m.getProductDetailService(ctx, productID, true)
+}
+
+// updateProductVariantsService updates the given fields of several variants of the product at once, such as their
+// stock, in a single transaction.
+func (m *Module) updateProductVariantsService(ctx context.Context, productID *uuid.UUID, variants []*p.ProductVariantModel) (*p.ProductModel, error) {
+	ids := make([]uuid.UUID, 0, len(variants))
+	for _, variant := range variants {
+		if slices.Contains(ids, *variant.ID) {
+			return nil, apperror.Validation("a variant is given more than once")
+		}
+		ids = append(ids, *variant.ID)
+	}
+
+	variantCount, err := p.ProductVariantRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ? AND id IN ?",
+				Args:  []interface{}{productID, ids},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if *variantCount != int64(len(ids)) {
+		return nil, apperror.NotFound("product variant not found")
+	}
+
+	if err := m.checkProductImageIDs(ctx, productID, variants); err != nil {
+		return nil, err
+	}
+
+	txs := make([]func(tx *pg.DB) *pg.DB, 0, len(variants))
+	for _, variant := range variants {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductVariantRepository().UpdateTx(tx, &p.ProductVariantModel{
+				SKU:     variant.SKU,
+				Price:   variant.Price,
+				Stock:   variant.Stock,
+				ImageID: variant.ImageID,
+			}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{variant.ID},
+					},
+				},
+			})
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		return nil, err
+	}
+
+	return m.getProductDetailService(ctx, productID, true)
+}
+
+// maxImportRows bounds an import, which is written in a single statement.
+const maxImportRows = 1000
+
+var productRowColumns = []string{"sku", "category", "title", "description", "price", "stock", "status", "publish_at", "unpublish_at"}
+
+type importedProductRow struct {
+	line int
+	row  *productRow
+	// fieldErrors are the columns that could not be decoded.
+	fieldErrors []validator.FieldError
+}
+
+// parseProductCSV reads the rows of a CSV file. A row that cannot be read is reported in the returned row errors, while
+// the error is for a file that cannot be read at all.
+func parseProductCSV(data []byte) ([]*importedProductRow, []*importProductRowError, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, apperror.Validation("the file is empty")
+		}
+		return nil, nil, apperror.Validation(err.Error())
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		if !slices.Contains(productRowColumns, header[i]) {
+			return nil, nil, apperror.Validation(fmt.Sprintf("unknown column %q, expected %s", header[i], strings.Join(productRowColumns, ", ")))
+		}
+	}
+
+	rows := []*importedProductRow{}
+	rowErrors := []*importProductRowError{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if !errors.As(err, &parseError) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, &importProductRowError{
+				Line:    parseError.StartLine,
+				Message: parseError.Err.Error(),
+			})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rowErrors = append(rowErrors, &importProductRowError{
+				Line:    line,
+				Message: fmt.Sprintf("the row has %d columns instead of %d", len(record), len(header)),
+			})
+			continue
+		}
+
+		imported := &importedProductRow{line: line, row: new(productRow)}
+		for i, value := range record {
+			if len(value) == 0 {
+				continue
+			}
+			if fieldError := setProductRowColumn(imported.row, header[i], value); fieldError != nil {
+				imported.fieldErrors = append(imported.fieldErrors, *fieldError)
+			}
+		}
+		rows = append(rows, imported)
+	}
+
+	return rows, rowErrors, nil
+}
+
+func setProductRowColumn(row *productRow, column string, value string) *validator.FieldError {
+	switch column {
+	case "sku":
+		row.SKU = &value
+	case "category":
+		row.Category = &value
+	case "title":
+		row.Title = &value
+	case "description":
+		row.Description = &value
+	case "price", "stock":
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return &validator.FieldError{Field: column, Rule: "number", Message: column + " must be a whole number"}
+		}
+		if column == "price" {
+			row.Price = &number
+		} else {
+			row.Stock = &number
+		}
+	case "status":
+		status := p.ProductStatus(strings.ToUpper(strings.TrimSpace(value)))
+		row.Status = &status
+	case "publish_at", "unpublish_at":
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			return &validator.FieldError{Field: column, Rule: "datetime", Message: column + " must be an RFC 3339 time"}
+		}
+		if column == "publish_at" {
+			row.PublishAt = &t
+		} else {
+			row.UnpublishAt = &t
+		}
+	}
+	return nil
+}
+
+// parseProductJSONL reads the rows of a file with a JSON object on each line. Blank lines are skipped.
+func parseProductJSONL(data []byte) ([]*importedProductRow, []*importProductRowError, error) {
+	rows := []*importedProductRow{}
+	rowErrors := []*importProductRowError{}
+
+	for i, text := range bytes.Split(data, []byte("\n")) {
+		text = bytes.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+
+		row := new(productRow)
+		if err := sonic.Unmarshal(text, row); err != nil {
+			rowErrors = append(rowErrors, &importProductRowError{
+				Line:    i + 1,
+				Message: "the line is not a valid product object",
+			})
+			continue
+		}
+		rows = append(rows, &importedProductRow{line: i + 1, row: row})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// importProductsService upserts the products of the file by SKU. Every row is checked first, and nothing is written
+// when any row fails or when isDryRun is set.
+func (m *Module) importProductsService(ctx context.Context, data []byte, format string, isDryRun bool, locale string) (*importProductsRes, error) {
+	var rows []*importedProductRow
+	var rowErrors []*importProductRowError
+	var err error
+	if format == PRODUCT_FILE_FORMAT_CSV {
+		rows, rowErrors, err = parseProductCSV(data)
+	} else {
+		rows, rowErrors, err = parseProductJSONL(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows)+len(rowErrors) == 0 {
+		return nil, apperror.Validation("the file has no rows")
+	}
+	if len(rows)+len(rowErrors) > maxImportRows {
+		return nil, apperror.Validation(fmt.Sprintf("an import can have at most %d rows", maxImportRows))
+	}
+
+	categoryIDs, err := m.resolveImportCategoriesService(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := map[string]int{}
+	skus := []string{}
+	for _, imported := range rows {
+		row := imported.row
+		rowError := &importProductRowError{Line: imported.line, SKU: row.SKU, Fields: imported.fieldErrors}
+
+		// A column that could not be decoded is left empty, and is not reported again as missing.
+		if err := validator.Struct(row); err != nil {
+			for _, fieldError := range validator.FieldErrors(err, locale) {
+				if !slices.ContainsFunc(rowError.Fields, func(decoded validator.FieldError) bool {
+					return decoded.Field == fieldError.Field
+				}) {
+					rowError.Fields = append(rowError.Fields, fieldError)
+				}
+			}
+		}
+
+		switch {
+		case row.SKU != nil && lines[*row.SKU] > 0:
+			rowError.Message = fmt.Sprintf("the sku is already used on line %d", lines[*row.SKU])
+		case row.Category != nil && len(categoryIDs[*row.Category].message) > 0:
+			rowError.Message = categoryIDs[*row.Category].message
+		case row.PublishAt != nil && row.UnpublishAt != nil && !row.UnpublishAt.After(*row.PublishAt):
+			rowError.Message = "unpublish_at must be after publish_at"
+		}
+		if row.SKU != nil && lines[*row.SKU] == 0 {
+			lines[*row.SKU] = imported.line
+			skus = append(skus, *row.SKU)
+		}
+
+		if len(rowError.Fields) > 0 || len(rowError.Message) > 0 {
+			rowErrors = append(rowErrors, rowError)
+		}
+	}
+	slices.SortFunc(rowErrors, func(a *importProductRowError, b *importProductRowError) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+
+	existingCount, err := p.ProductRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "sku IN ?",
+				Args:  []interface{}{skus},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &importProductsRes{
+		IsDryRun: isDryRun,
+		Created:  len(skus) - int(*existingCount),
+		Updated:  int(*existingCount),
+		Errors:   rowErrors,
+	}
+	if isDryRun || len(rowErrors) > 0 {
+		return res, nil
+	}
+
+	products := make([]*p.ProductModel, 0, len(rows))
+	for _, imported := range rows {
+		row := imported.row
+		products = append(products, &p.ProductModel{
+			CategoryID:  categoryIDs[*row.Category].id,
+			SKU:         row.SKU,
+			Title:       row.Title,
+			Description: row.Description,
+			Price:       row.Price,
+			Stock:       row.Stock,
+			Status:      row.Status,
+			PublishAt:   row.PublishAt,
+			UnpublishAt: row.UnpublishAt,
+		})
+	}
+	if _, err := p.ProductRepository().WithContext(ctx).BulkCreate(&products, &pg.CreateOptions{
+		IsUpsert:        true,
+		ConflictColumns: []string{"sku"},
+		ConflictWhere: &pg.Where{
+			Query: "deleted_at IS NULL",
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	res.IsImported = true
+	return res, nil
+}
+
+type importCategory struct {
+	id *uuid.UUID
+	// message explains why the category could not be resolved.
+	message string
+}
+
+// resolveImportCategoriesService finds the categories named in the rows. A category is matched by its slug, which is
+// unique, or else by its name, which is only unique among its siblings.
+func (*Module) resolveImportCategoriesService(ctx context.Context, rows []*importedProductRow) (map[string]*importCategory, error) {
+	values := []string{}
+	for _, imported := range rows {
+		if imported.row.Category != nil && !slices.Contains(values, *imported.row.Category) {
+			values = append(values, *imported.row.Category)
+		}
+	}
+
+	bySlug := map[string]*uuid.UUID{}
+	byName := map[string][]*uuid.UUID{}
+	var lastID *uuid.UUID
+	for len(values) > 0 {
+		where := []pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "(slug IN ? OR name IN ?)",
+					Args:  []interface{}{values, values},
+				},
+				IncludeInCount: true,
+			},
+		}
+		if lastID != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "id > ?",
+					Args:  []interface{}{lastID},
+				},
+				IncludeInCount: true,
+			})
+		}
+
+		limit := pg.FindAllMaximumLimit
+		categories, _, err := pc.ProductCategoryRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where:       &where,
+			Order:       &[]string{"id"},
+			Limit:       &limit,
+			IsSkipCount: true,
+			IsPrimary:   true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, category := range *categories {
+			bySlug[*category.Slug] = category.ID
+			byName[*category.Name] = append(byName[*category.Name], category.ID)
+		}
+
+		if len(*categories) < limit {
+			break
+		}
+		lastID = (*categories)[len(*categories)-1].ID
+	}
+
+	resolved := make(map[string]*importCategory, len(values))
+	for _, value := range values {
+		switch {
+		case bySlug[value] != nil:
+			resolved[value] = &importCategory{id: bySlug[value]}
+		case len(byName[value]) == 1:
+			resolved[value] = &importCategory{id: byName[value][0]}
+		case len(byName[value]) > 1:
+			resolved[value] = &importCategory{message: fmt.Sprintf("several categories are named %q, use the slug of one instead", value)}
+		default:
+			resolved[value] = &importCategory{message: fmt.Sprintf("category %q does not exist", value)}
+		}
+	}
+
+	return resolved, nil
+}
+
+func newProductRow(product *p.ProductModel) *productRow {
+	row := &productRow{
+		SKU:         product.SKU,
+		Title:       product.Title,
+		Description: product.Description,
+		Price:       product.Price,
+		Stock:       product.Stock,
+		Status:      product.Status,
+		PublishAt:   product.PublishAt,
+		UnpublishAt: product.UnpublishAt,
+	}
+	if product.Category != nil {
+		row.Category = product.Category.Slug
+	}
+	return row
+}
+
+// productRowRecord writes row as the CSV columns of productRowColumns.
+func productRowRecord(row *productRow) []string {
+	record := make([]string, len(productRowColumns))
+	for i, value := range []*string{row.SKU, row.Category, row.Title, row.Description} {
+		if value != nil {
+			record[i] = *value
+		}
+	}
+	if row.Price != nil {
+		record[4] = strconv.Itoa(*row.Price)
+	}
+	if row.Stock != nil {
+		record[5] = strconv.Itoa(*row.Stock)
+	}
+	if row.Status != nil {
+		record[6] = string(*row.Status)
+	}
+	if row.PublishAt != nil {
+		record[7] = row.PublishAt.Format(time.RFC3339)
+	}
+	if row.UnpublishAt != nil {
+		record[8] = row.UnpublishAt.Format(time.RFC3339)
+	}
+	return record
+}
+
+// exportProductsService writes every product to w, a page at a time, flushing after each page so that the export is
+// streamed to the client.
+func (*Module) exportProductsService(ctx context.Context, w *bufio.Writer, format string) error {
+	csvWriter := csv.NewWriter(w)
+	if format == PRODUCT_FILE_FORMAT_CSV {
+		if err := csvWriter.Write(productRowColumns); err != nil {
+			return err
+		}
+	}
+
+	var lastID *uuid.UUID
+	for {
+		where := []pg.FindAllWhere{}
+		if lastID != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "id > ?",
+					Args:  []interface{}{lastID},
+				},
+				IncludeInCount: true,
+			})
+		}
+
+		limit := pg.FindAllMaximumLimit
+		products, _, err := p.ProductRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where: &where,
+			Order: &[]string{"id"},
+			Limit: &limit,
+			IncludeTables: &[]pg.IncludeTables{
+				{
+					Query: "Category",
+				},
+			},
+			IsSkipCount: true,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, product := range *products {
+			row := newProductRow(product)
+			if format == PRODUCT_FILE_FORMAT_CSV {
+				if err := csvWriter.Write(productRowRecord(row)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			line, err := sonic.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if len(*products) < limit {
+			return nil
+		}
+		lastID = (*products)[len(*products)-1].ID
+	}
+}
@@ -0,0 +1,221 @@
+package product
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+	p "hilmy.dev/store/src/modules/product/product_entity"
+)
+
+func TestParseProductCSV(t *testing.T) {
+	data := "\ufeffSKU, Title ,category,price,stock,status,publish_at\n" +
+		"shirt-1,Shirt,clothes,1000,5,published,2026-01-02T03:04:05Z\n" +
+		"\"mug-1\",\"Mug, large\",kitchen,250,,,\n" +
+		"hat-1,Hat,clothes,cheap,-1,DRAFT,tomorrow\n" +
+		"short-1,Short\n" +
+		"quote-1,\"Quote\"d,clothes,1,,,\n"
+
+	rows, rowErrors, err := parseProductCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	shirt := rows[0]
+	published := p.STATUS_PUBLISHED
+	publishAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := &productRow{
+		SKU:       stringPtr("shirt-1"),
+		Title:     stringPtr("Shirt"),
+		Category:  stringPtr("clothes"),
+		Price:     intPtr(1000),
+		Stock:     intPtr(5),
+		Status:    &published,
+		PublishAt: &publishAt,
+	}
+	if shirt.line != 2 || len(shirt.fieldErrors) != 0 || !reflect.DeepEqual(shirt.row, want) {
+		t.Errorf("row = line %d %+v with %v, want line 2 %+v", shirt.line, shirt.row, shirt.fieldErrors, want)
+	}
+
+	// Empty columns are left unset rather than set to zero values.
+	mug := rows[1]
+	if *mug.row.Title != "Mug, large" || mug.row.Stock != nil || mug.row.Status != nil || mug.row.PublishAt != nil {
+		t.Errorf("row = %+v, want the quoted title and no stock, status or publish time", mug.row)
+	}
+
+	// Columns that cannot be decoded are reported on the row, and the range checks are left to the validation.
+	hat := rows[2]
+	wantFieldErrors := []string{"price", "publish_at"}
+	gotFieldErrors := []string{}
+	for _, fieldError := range hat.fieldErrors {
+		gotFieldErrors = append(gotFieldErrors, fieldError.Field)
+	}
+	if hat.line != 4 || !reflect.DeepEqual(gotFieldErrors, wantFieldErrors) || *hat.row.Stock != -1 {
+		t.Errorf("row = line %d with errors on %v and stock %v, want line 4 with errors on %v", hat.line,
+			gotFieldErrors, hat.row.Stock, wantFieldErrors)
+	}
+
+	wantLines := []int{5, 6}
+	gotLines := []int{}
+	for _, rowError := range rowErrors {
+		gotLines = append(gotLines, rowError.Line)
+	}
+	if !reflect.DeepEqual(gotLines, wantLines) {
+		t.Errorf("got row errors on lines %v, want %v", gotLines, wantLines)
+	}
+	if !strings.Contains(rowErrors[0].Message, "2 columns instead of 7") {
+		t.Errorf("message = %q, want it to give the column counts", rowErrors[0].Message)
+	}
+}
+
+func TestParseProductCSVRejectsTheFile(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":          "",
+		"bom only":       "\ufeff",
+		"unknown column": "sku,title,colour\nshirt-1,Shirt,red\n",
+	} {
+		if _, _, err := parseProductCSV([]byte(data)); err == nil {
+			t.Errorf("%s: the file was accepted", name)
+		}
+	}
+}
+
+func TestParseProductJSONL(t *testing.T) {
+	data := `{"sku":"shirt-1","title":"Shirt","price":1000,"publish_at":"2026-01-02T03:04:05Z"}` + "\r\n" +
+		"\n" +
+		`   ` + "\n" +
+		`{"sku":"mug-1","price":"cheap"}` + "\n" +
+		`not json` + "\n" +
+		`{"sku":"hat-1","stock":0}`
+
+	rows, rowErrors, err := parseProductJSONL([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	publishAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if rows[0].line != 1 || *rows[0].row.SKU != "shirt-1" || *rows[0].row.Price != 1000 ||
+		!rows[0].row.PublishAt.Equal(publishAt) {
+		t.Errorf("row = line %d %+v, want line 1 shirt-1", rows[0].line, rows[0].row)
+	}
+	// A zero stock is kept, unlike an empty CSV column.
+	if rows[1].line != 6 || rows[1].row.Stock == nil || *rows[1].row.Stock != 0 {
+		t.Errorf("row = line %d %+v, want line 6 with a stock of 0", rows[1].line, rows[1].row)
+	}
+
+	wantLines := []int{4, 5}
+	gotLines := []int{}
+	for _, rowError := range rowErrors {
+		gotLines = append(gotLines, rowError.Line)
+	}
+	if !reflect.DeepEqual(gotLines, wantLines) {
+		t.Errorf("got row errors on lines %v, want %v", gotLines, wantLines)
+	}
+}
+
+func TestProductRowRecordRoundTrips(t *testing.T) {
+	archived := p.STATUS_ARCHIVED
+	unpublishAt := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	row := &productRow{
+		SKU:         stringPtr("shirt-1"),
+		Category:    stringPtr("clothes"),
+		Title:       stringPtr("Shirt"),
+		Description: stringPtr("Cotton, \"soft\"\nand warm"),
+		Price:       intPtr(1000),
+		Status:      &archived,
+		UnpublishAt: &unpublishAt,
+	}
+
+	var data strings.Builder
+	data.WriteString(strings.Join(productRowColumns, ",") + "\n")
+	for i, value := range productRowRecord(row) {
+		if i > 0 {
+			data.WriteString(",")
+		}
+		data.WriteString(`"` + strings.ReplaceAll(value, `"`, `""`) + `"`)
+	}
+
+	rows, rowErrors, err := parseProductCSV([]byte(data.String()))
+	if err != nil || len(rowErrors) != 0 || len(rows) != 1 {
+		t.Fatalf("got %d rows, row errors %v and error %v, want a single row", len(rows), rowErrors, err)
+	}
+	if !reflect.DeepEqual(rows[0].row, row) {
+		t.Errorf("row = %+v, want %+v", rows[0].row, row)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestNewVariantOptions(t *testing.T) {
+	options := []p.ProductOption{
+		{Name: "size", Values: []string{"S", "M", "L"}},
+		{Name: "color", Values: []string{"red", "blue"}},
+	}
+
+	got, err := newVariantOptions(options, map[string]string{"color": "blue", "size": "M"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The values are put in the order of the options, whatever order they are sent in.
+	want := datatypes.JSONSlice[p.ProductVariantOption]{{Name: "size", Value: "M"}, {Name: "color", Value: "blue"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("options = %v, want %v", got, want)
+	}
+
+	for name, values := range map[string]map[string]string{
+		"missing option":   {"size": "M"},
+		"extra option":     {"size": "M", "color": "blue", "fit": "slim"},
+		"unknown option":   {"size": "M", "fit": "slim"},
+		"unknown value":    {"size": "XL", "color": "blue"},
+		"wrong case value": {"size": "m", "color": "blue"},
+		"no values":        {},
+	} {
+		if _, err := newVariantOptions(options, values); err == nil {
+			t.Errorf("%s: %v was accepted", name, values)
+		}
+	}
+
+	got, err = newVariantOptions(nil, map[string]string{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("got %v and %v for a product without options, want no options", got, err)
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	key := func(pairs ...string) string {
+		options := datatypes.JSONSlice[p.ProductVariantOption]{}
+		for i := 0; i < len(pairs); i += 2 {
+			options = append(options, p.ProductVariantOption{Name: pairs[i], Value: pairs[i+1]})
+		}
+		return variantKey(options)
+	}
+
+	if key("size", "M", "color", "blue") != key("size", "M", "color", "blue") {
+		t.Error("the same option values give different keys")
+	}
+	for name, other := range map[string]string{
+		"other value":            key("size", "L", "color", "blue"),
+		"values of other option": key("size", "blue", "color", "M"),
+		"fewer options":          key("size", "M"),
+		// The separator keeps names and values from running into each other.
+		"shifted separator": key("size", "Mcolor", "", "blue"),
+	} {
+		if other == key("size", "M", "color", "blue") {
+			t.Errorf("%s gives the same key", name)
+		}
+	}
+}
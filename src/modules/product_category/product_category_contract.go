@@ -1,18 +1,69 @@
 package productcategory
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
+)
 
 type getProductCategoryListReqQuery struct {
-	Limit *int `query:"limit"`
-	Page  *int `query:"page"`
+	// IsRoot limits the list to the top level categories.
+	IsRoot       *bool   `query:"is_root"`
+	Limit        *int    `query:"limit"`
+	Page         *int    `query:"page"`
+	Cursor       *string `query:"cursor"`
+	IncludeTotal *bool   `query:"include_total"`
+}
+
+// productCategoryListQueryFields whitelists the fields accepted in filter[...] and sort.
+var productCategoryListQueryFields = parser.QueryFields{
+	"name": {
+		Column:     "name",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"slug": {
+		Column:    "slug",
+		Type:      parser.FIELD_TYPE_STRING,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_IN},
+	},
+	"parent_id": {
+		Column:    "parent_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_IN},
+	},
+	"position": {
+		Column:     "position",
+		Type:       parser.FIELD_TYPE_INT,
+		IsSortable: true,
+	},
+	"created_at": {
+		Column:     "created_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"updated_at": {
+		Column:     "updated_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
 }
 
 type getProductCategoryDetailReqParam struct {
 	ID *uuid.UUID `params:"id" validate:"required"`
 }
 
+type getProductCategoryBySlugReqParam struct {
+	Slug *string `params:"slug" validate:"required"`
+}
+
 type addProductCategoryReq struct {
-	Name *string `json:"name" validate:"required"`
+	ParentID *uuid.UUID `json:"parent_id"`
+	Name     *string    `json:"name" validate:"required"`
+	// Slug is made from the name when it is left out.
+	Slug *string `json:"slug" validate:"omitempty,lte=100"`
 }
 
 type updateProductCategoryReqParam struct {
@@ -21,8 +72,35 @@ type updateProductCategoryReqParam struct {
 
 type updateProductCategoryReq struct {
 	Name *string `json:"name"`
+	Slug *string `json:"slug" validate:"omitempty,lte=100"`
+}
+
+type moveProductCategoryReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type moveProductCategoryReq struct {
+	// ParentID is the new parent of the category, or null to make it a top level category.
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+type reorderProductCategoriesReq struct {
+	// ParentID is the parent whose children are reordered, or null for the top level categories.
+	ParentID    *uuid.UUID   `json:"parent_id"`
+	CategoryIDs *[]uuid.UUID `json:"category_ids" validate:"required,gt=0"`
 }
 
 type deleteProductCategoryReqParam struct {
 	ID *uuid.UUID `params:"id" validate:"required"`
 }
+
+type deleteProductCategoryReqQuery struct {
+	// IsCascade also deletes the subcategories of the category. Without it, a category that still has subcategories
+	// is not deleted.
+	IsCascade *bool `query:"cascade"`
+	// ReassignTo moves the products of the deleted categories, deleted products included, to another category.
+	ReassignTo *uuid.UUID `query:"reassign_to"`
+	// IsDeleteProducts deletes the products of the deleted categories along with them. Deleted categories that products
+	// point at need either this or ReassignTo.
+	IsDeleteProducts *bool `query:"delete_products"`
+}
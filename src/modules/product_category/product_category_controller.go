@@ -1,12 +1,13 @@
 package productcategory
 
 import (
-	"errors"
-
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
+	"hilmy.dev/store/src/libs/slug"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	am "hilmy.dev/store/src/modules/auth/auth_middleware"
 	"hilmy.dev/store/src/modules/log"
@@ -14,23 +15,81 @@ import (
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/product-categories", m.getProductCategoryList)
-	m.App.Get("/api/v1/product-category/:id", m.getProductCategoryDetail)
-	m.App.Post("/api/v1/product-category", am.AuthGuard(acc.ROLE_ADMIN), m.addProductCategory)
-	m.App.Patch("/api/v1/product-category/:id", am.AuthGuard(acc.ROLE_ADMIN), m.updateProductCategory)
-	m.App.Delete("/api/v1/product-category/:id", am.AuthGuard(acc.ROLE_ADMIN), m.deleteProductCategory)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product-categories", &openapi.Operation{
+		Summary:   "List product categories",
+		Tags:      []string{"product category"},
+		Query:     getProductCategoryListReqQuery{},
+		ListQuery: productCategoryListQueryFields,
+		Response:  pc.ProductCategoryModel{},
+		IsList:    true,
+	}, m.getProductCategoryList)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product-categories/tree", &openapi.Operation{
+		Summary:  "Get the tree of product categories",
+		Tags:     []string{"product category"},
+		Response: []*pc.ProductCategoryModel{},
+	}, m.getProductCategoryTree)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/product-categories/order", &openapi.Operation{
+		Summary:  "Reorder the children of a product category",
+		Tags:     []string{"product category"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Body:     reorderProductCategoriesReq{},
+		Response: []*pc.ProductCategoryModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.reorderProductCategories)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product-category/slug/:slug", &openapi.Operation{
+		Summary:  "Get a product category by its slug",
+		Tags:     []string{"product category"},
+		Params:   getProductCategoryBySlugReqParam{},
+		Response: pc.ProductCategoryModel{},
+	}, m.getProductCategoryBySlug)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product-category/:id", &openapi.Operation{
+		Summary:  "Get a product category",
+		Tags:     []string{"product category"},
+		Params:   getProductCategoryDetailReqParam{},
+		Response: pc.ProductCategoryModel{},
+	}, m.getProductCategoryDetail)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product-category", &openapi.Operation{
+		Summary:  "Add a product category",
+		Tags:     []string{"product category"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Body:     addProductCategoryReq{},
+		Status:   fiber.StatusCreated,
+		Response: pc.ProductCategoryModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.addProductCategory)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/product-category/:id", &openapi.Operation{
+		Summary:  "Update a product category",
+		Tags:     []string{"product category"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   updateProductCategoryReqParam{},
+		Body:     updateProductCategoryReq{},
+		Response: pc.ProductCategoryModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.updateProductCategory)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product-category/:id/move", &openapi.Operation{
+		Summary:  "Move a product category under another parent",
+		Tags:     []string{"product category"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   moveProductCategoryReqParam{},
+		Body:     moveProductCategoryReq{},
+		Response: pc.ProductCategoryModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.moveProductCategory)
+	openapi.Route(m.App, fiber.MethodDelete, "/api/v1/product-category/:id", &openapi.Operation{
+		Summary:  "Delete a product category, or a whole branch of categories, deleting or reassigning their products",
+		Tags:     []string{"product category"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   deleteProductCategoryReqParam{},
+		Query:    deleteProductCategoryReqQuery{},
+		Response: uuid.UUID{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.deleteProductCategory)
 }
 
 func (m *Module) getProductCategoryList(c *fiber.Ctx) error {
 	query := new(getProductCategoryListReqQuery)
 	if err := parser.ParseReqQuery(c, query); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+
+	listQuery, err := parser.ParseReqListQuery(c, productCategoryListQueryFields, "name")
+	if err != nil {
+		return err
 	}
 
 	offset := 0
@@ -38,64 +97,76 @@ func (m *Module) getProductCategoryList(c *fiber.Ctx) error {
 		offset = (*query.Page - 1) * *query.Limit
 	}
 
-	productCategoryListData, page, err := m.getProductCategoryListService(&paginationOptions{
-		limit:  query.Limit,
-		offset: &offset,
+	productCategoryListData, page, err := m.getProductCategoryListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
+	}, &searchOptions{
+		filters: listQuery.Where,
+		isRoot:  query.IsRoot != nil && *query.IsRoot,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Pagination: &contracts.Pagination{
 			Limit: page.limit,
 			Count: page.count,
 			Page:  query.Page,
 			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
 		},
 		Data: productCategoryListData,
 	})
 }
 
+func (m *Module) getProductCategoryTree(c *fiber.Ctx) error {
+	productCategoryTreeData, err := m.getProductCategoryTreeService(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productCategoryTreeData,
+	})
+}
+
+func (m *Module) getProductCategoryBySlug(c *fiber.Ctx) error {
+	param := new(getProductCategoryBySlugReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	productCategoryDetailData, err := m.getProductCategoryBySlugService(c.UserContext(), param.Slug)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productCategoryDetailData,
+	})
+}
+
 func (m *Module) getProductCategoryDetail(c *fiber.Ctx) error {
 	param := new(getProductCategoryDetailReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	productCategoryDetailData, err := m.getProductCategoryDetailService(param.ID)
+	productCategoryDetailData, err := m.getProductCategoryDetailService(c.UserContext(), param.ID, false)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: productCategoryDetailData,
 	})
@@ -104,29 +175,24 @@ func (m *Module) getProductCategoryDetail(c *fiber.Ctx) error {
 func (m *Module) addProductCategory(c *fiber.Ctx) error {
 	req := new(addProductCategoryReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	productCategoryDetailData, err := m.addProductCategoryService(&pc.ProductCategoryModel{
-		Name: req.Name,
-	})
+	categorySlug, err := newSlug(req.Name, req.Slug)
+	if err != nil {
+		return err
+	}
+
+	productCategoryDetailData, err := m.addProductCategoryService(c.UserContext(), &pc.ProductCategoryModel{
+		ParentID: req.ParentID,
+		Name:     req.Name,
+		Slug:     categorySlug,
+	}, req.Slug == nil)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
 		Data: productCategoryDetailData,
 	})
@@ -135,48 +201,27 @@ func (m *Module) addProductCategory(c *fiber.Ctx) error {
 func (m *Module) updateProductCategory(c *fiber.Ctx) error {
 	param := new(updateProductCategoryReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(updateProductCategoryReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	productCategoryDetailData, err := m.updateProductCategoryService(param.ID, &pc.ProductCategoryModel{
+	if req.Slug != nil && !slug.IsValid(*req.Slug) {
+		return apperror.Validation(invalidSlugMessage)
+	}
+
+	productCategoryDetailData, err := m.updateProductCategoryService(c.UserContext(), param.ID, &pc.ProductCategoryModel{
 		Name: req.Name,
+		Slug: req.Slug,
 	})
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: productCategoryDetailData,
 	})
@@ -185,56 +230,104 @@ func (m *Module) updateProductCategory(c *fiber.Ctx) error {
 func (m *Module) deleteProductCategory(c *fiber.Ctx) error {
 	param := new(deleteProductCategoryReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	count, err := m.getProductCountByProductCategoryID(param.ID)
+	query := new(deleteProductCategoryReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	productCategoryDetailData, err := m.getProductCategoryDetailService(c.UserContext(), param.ID, true)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
-	}
-	if *count > 0 {
-		err := errors.New("failed to delete the category because there are items (or deleted items) with that category")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
-	}
-
-	if err := m.deleteProductCategoryService(param.ID); err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
+		return err
+	}
+
+	if query.ReassignTo != nil {
+		targetDetailData, err := m.getProductCategoryDetailService(c.UserContext(), query.ReassignTo, true)
+		if err != nil {
+			if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+				return apperror.Validation("the category to reassign the products to does not exist")
+			}
+			return err
+		}
+		if targetDetailData.IsInSubtreeOf(productCategoryDetailData) {
+			return apperror.Validation("products cannot be reassigned to a category that is being deleted")
 		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	isCascade := query.IsCascade != nil && *query.IsCascade
+	isDeleteProducts := query.IsDeleteProducts != nil && *query.IsDeleteProducts
+	if isDeleteProducts && query.ReassignTo != nil {
+		return apperror.Validation("products can either be reassigned with reassign_to or deleted with delete_products, not both")
+	}
+
+	if !isCascade {
+		childCount, err := m.getProductCategoryChildCountService(c.UserContext(), param.ID)
+		if err != nil {
+			return err
+		}
+		if *childCount > 0 {
+			return apperror.Conflict("failed to delete the category because it has subcategories, delete them first or use cascade")
+		}
+	}
+
+	// Products are only deleted along with their category when that is asked for, since a cascade can reach many of them.
+	if query.ReassignTo == nil && !isDeleteProducts {
+		count, err := m.getProductCountByProductCategoryService(c.UserContext(), productCategoryDetailData, isCascade)
+		if err != nil {
+			return err
+		}
+		if *count > 0 {
+			return apperror.Conflict("failed to delete the category because there are items (or deleted items) in it, reassign them with reassign_to or delete them with delete_products")
+		}
+	}
+
+	if err := m.deleteProductCategoryService(c.UserContext(), productCategoryDetailData, isCascade, query.ReassignTo); err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: param.ID,
 	})
 }
+
+func (m *Module) moveProductCategory(c *fiber.Ctx) error {
+	param := new(moveProductCategoryReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(moveProductCategoryReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	productCategoryDetailData, err := m.moveProductCategoryService(c.UserContext(), param.ID, req.ParentID)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productCategoryDetailData,
+	})
+}
+
+func (m *Module) reorderProductCategories(c *fiber.Ctx) error {
+	req := new(reorderProductCategoriesReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	productCategoryListData, err := m.reorderProductCategoriesService(c.UserContext(), req.ParentID, *req.CategoryIDs)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productCategoryListData,
+	})
+}
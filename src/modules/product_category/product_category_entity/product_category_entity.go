@@ -1,19 +1,45 @@
 package productcategoryentity
 
 import (
+	"strings"
+
+	"github.com/google/uuid"
 	"hilmy.dev/store/src/libs/db/pg"
 	applogger "hilmy.dev/store/src/libs/logger"
 )
 
 type ProductCategoryModel struct {
 	pg.Model
-	Name *string `gorm:"uniqueIndex;not null" json:"name,omitempty"`
+	// ParentID is nil for a top level category.
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+	Name     *string    `gorm:"not null" json:"name,omitempty"`
+	Slug     *string    `gorm:"not null" json:"slug,omitempty"`
+	// Path lists the ids from the top level category down to the category itself, as in /<root id>/<parent id>/<id>/,
+	// so that the descendants of a category are the categories whose path starts with its path.
+	Path *string `gorm:"not null" json:"path,omitempty"`
+	// Position orders the category among its siblings.
+	Position *int                    `gorm:"not null;default:0" json:"position,omitempty"`
+	Children []*ProductCategoryModel `gorm:"-" json:"children,omitempty"`
 }
 
 func (ProductCategoryModel) TableName() string {
 	return "product_categories"
 }
 
+// NewPath returns the path of the category with the given id under parent, which is nil for a top level category.
+func NewPath(parent *ProductCategoryModel, id uuid.UUID) string {
+	if parent == nil {
+		return "/" + id.String() + "/"
+	}
+	return *parent.Path + id.String() + "/"
+}
+
+// IsInSubtreeOf reports whether m is category itself or one of its descendants. The paths end with a slash, so an id
+// is never taken for the prefix of another.
+func (m *ProductCategoryModel) IsInSubtreeOf(category *ProductCategoryModel) bool {
+	return strings.HasPrefix(*m.Path, *category.Path)
+}
+
 type productCategoryDB = pg.Service[ProductCategoryModel]
 
 var productCategoryRepo *productCategoryDB
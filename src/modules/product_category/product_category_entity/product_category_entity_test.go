@@ -0,0 +1,89 @@
+package productcategoryentity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestCategory(parent *ProductCategoryModel, id string) *ProductCategoryModel {
+	categoryID := uuid.MustParse(id)
+	path := NewPath(parent, categoryID)
+	return &ProductCategoryModel{Path: &path}
+}
+
+func TestNewPath(t *testing.T) {
+	rootID := uuid.MustParse("6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11")
+	childID := uuid.MustParse("0b7e4a52-4f0e-4c0b-8f55-2f6ad4d8c6b2")
+
+	root := newTestCategory(nil, rootID.String())
+	if want := "/" + rootID.String() + "/"; *root.Path != want {
+		t.Errorf("top level path = %q, want %q", *root.Path, want)
+	}
+
+	child := newTestCategory(root, childID.String())
+	if want := "/" + rootID.String() + "/" + childID.String() + "/"; *child.Path != want {
+		t.Errorf("child path = %q, want %q", *child.Path, want)
+	}
+}
+
+func TestIsInSubtreeOf(t *testing.T) {
+	root := newTestCategory(nil, "6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11")
+	child := newTestCategory(root, "0b7e4a52-4f0e-4c0b-8f55-2f6ad4d8c6b2")
+	grandchild := newTestCategory(child, "c3a9d0e1-2b5f-4a7c-9e8d-1f2a3b4c5d6e")
+	sibling := newTestCategory(root, "5d1e8f0a-7c2b-4e3d-a6f9-8b0c1d2e3f4a")
+	other := newTestCategory(nil, "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d")
+
+	tests := []struct {
+		name     string
+		category *ProductCategoryModel
+		ancestor *ProductCategoryModel
+		want     bool
+	}{
+		{"itself", child, child, true},
+		{"child", child, root, true},
+		{"grandchild", grandchild, root, true},
+		{"parent", root, child, false},
+		{"sibling", sibling, child, false},
+		{"descendant of a sibling", grandchild, sibling, false},
+		{"other tree", other, root, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.category.IsInSubtreeOf(tt.ancestor); got != tt.want {
+				t.Errorf("IsInSubtreeOf = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The move rewrites the paths in SQL as newPath || substr(path, len(oldPath) + 1), which has to give every moved
+// category the path NewPath gives it under its new parent.
+func TestMovedPathsMatchNewPath(t *testing.T) {
+	root := newTestCategory(nil, "6f1c0f4e-8a63-4d8e-9d4e-3b1f4f0f2a11")
+	moved := newTestCategory(root, "0b7e4a52-4f0e-4c0b-8f55-2f6ad4d8c6b2")
+	movedID := uuid.MustParse("0b7e4a52-4f0e-4c0b-8f55-2f6ad4d8c6b2")
+	grandchildID := uuid.MustParse("c3a9d0e1-2b5f-4a7c-9e8d-1f2a3b4c5d6e")
+	grandchild := newTestCategory(moved, grandchildID.String())
+	target := newTestCategory(nil, "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d")
+
+	for _, parent := range []*ProductCategoryModel{target, nil} {
+		oldPath := *moved.Path
+		newPath := NewPath(parent, movedID)
+		rewrite := func(path string) string {
+			if !strings.HasPrefix(path, oldPath) {
+				t.Fatalf("%q is not under the moved category", path)
+			}
+			return newPath + path[len(oldPath):]
+		}
+
+		movedCategory := &ProductCategoryModel{Path: &newPath}
+		if got, want := rewrite(*grandchild.Path), NewPath(movedCategory, grandchildID); got != want {
+			t.Errorf("moved grandchild path = %q, want %q", got, want)
+		}
+		if rewrite(oldPath) != newPath {
+			t.Errorf("moved category path = %q, want %q", rewrite(oldPath), newPath)
+		}
+	}
+}
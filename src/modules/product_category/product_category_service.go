@@ -1,26 +1,61 @@
 package productcategory
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/slug"
 	p "hilmy.dev/store/src/modules/product/product_entity"
 	pc "hilmy.dev/store/src/modules/product_category/product_category_entity"
 )
 
+type searchOptions struct {
+	filters *[]pg.FindAllWhere
+	isRoot  bool
+}
+
 type paginationOptions struct {
-	limit  *int
-	offset *int
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
 }
 
 type paginationQuery struct {
 	limit *int
 	count *int
 	total *int
+	next  *string
+	prev  *string
 }
 
-func (*Module) getProductCategoryListService(pagination *paginationOptions) (*[]*pc.ProductCategoryModel, *paginationQuery, error) {
+func (*Module) getProductCategoryListService(ctx context.Context, pagination *paginationOptions, search *searchOptions) (*[]*pc.ProductCategoryModel, *paginationQuery, error) {
+	where := []pg.FindAllWhere{}
 	limit := 0
 	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
+
+	if search != nil {
+		if search.isRoot {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "parent_id IS NULL",
+				},
+				IncludeInCount: true,
+			})
+		}
+		if search.filters != nil {
+			where = append(where, *search.filters...)
+		}
+	}
 
 	if pagination != nil {
 		if pagination.limit != nil && *pagination.limit > 0 {
@@ -29,12 +64,20 @@ func (*Module) getProductCategoryListService(pagination *paginationOptions) (*[]
 		if pagination.offset != nil && *pagination.offset > 0 {
 			offset = *pagination.offset
 		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
 	}
 
-	data, page, err := pc.ProductCategoryRepository().FindAll(&pg.FindAllOptions{
-		Limit:  &limit,
-		Offset: &offset,
-		Order:  &[]string{"name asc"},
+	data, page, err := pc.ProductCategoryRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where:       &where,
+		Limit:       &limit,
+		Offset:      &offset,
+		Keyset:      keyset,
+		Order:       order,
+		Cursor:      cursor,
+		IsSkipCount: isSkipCount,
 	})
 	if err != nil {
 		return nil, nil, err
@@ -43,69 +86,465 @@ func (*Module) getProductCategoryListService(pagination *paginationOptions) (*[]
 	return data, &paginationQuery{
 		limit: &page.Limit,
 		count: &page.Count,
-		total: &page.Total,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
 	}, nil
 }
 
-func (*Module) getProductCategoryDetailService(id *uuid.UUID) (*pc.ProductCategoryModel, error) {
-	return pc.ProductCategoryRepository().FindOne(&pg.FindOneOptions{
+// getProductCategoryDetailService reads from the primary when isPrimary is set, for when a write follows.
+func (*Module) getProductCategoryDetailService(ctx context.Context, id *uuid.UUID, isPrimary bool) (*pc.ProductCategoryModel, error) {
+	data, err := pc.ProductCategoryRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
-		IsUnscoped: true,
+		IsPrimary: isPrimary,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product category not found")
+	}
+
+	return data, nil
+}
+
+// findAllProductCategories returns every category matching where, reading them page by page since a single FindAll
+// is capped at pg.FindAllMaximumLimit rows. They are read from the primary when isPrimary is set.
+func findAllProductCategories(ctx context.Context, where []pg.Where, isPrimary bool) ([]*pc.ProductCategoryModel, error) {
+	findAllWhere := make([]pg.FindAllWhere, 0, len(where))
+	for _, condition := range where {
+		findAllWhere = append(findAllWhere, pg.FindAllWhere{
+			Where:          condition,
+			IncludeInCount: true,
+		})
+	}
+
+	result := []*pc.ProductCategoryModel{}
+	for offset := 0; ; offset += pg.FindAllMaximumLimit {
+		limit := pg.FindAllMaximumLimit
+		data, _, err := pc.ProductCategoryRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where:       &findAllWhere,
+			Order:       &[]string{"position", "name", "id"},
+			Limit:       &limit,
+			Offset:      &offset,
+			IsSkipCount: true,
+			IsPrimary:   isPrimary,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *data...)
+		if len(*data) < limit {
+			return result, nil
+		}
+	}
 }
 
-func (*Module) addProductCategoryService(data *pc.ProductCategoryModel) (*pc.ProductCategoryModel, error) {
-	return pc.ProductCategoryRepository().Create(data)
+// getProductCategoryTreeService returns the top level categories with their subcategories nested in Children, each
+// level ordered by position.
+func (*Module) getProductCategoryTreeService(ctx context.Context) ([]*pc.ProductCategoryModel, error) {
+	categories, err := findAllProductCategories(ctx, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	categoriesByID := make(map[uuid.UUID]*pc.ProductCategoryModel, len(categories))
+	for _, category := range categories {
+		categoriesByID[*category.ID] = category
+	}
+
+	roots := []*pc.ProductCategoryModel{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		// Categories under a deleted parent are left out along with it.
+		if parent, ok := categoriesByID[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, category)
+		}
+	}
+
+	return roots, nil
 }
 
-func (*Module) updateProductCategoryService(id *uuid.UUID, data *pc.ProductCategoryModel) (*pc.ProductCategoryModel, error) {
-	if _, err := pc.ProductCategoryRepository().Update(data, &pg.UpdateOptions{
+func (*Module) getProductCategoryBySlugService(ctx context.Context, slug *string) (*pc.ProductCategoryModel, error) {
+	data, err := pc.ProductCategoryRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "slug = ?",
+				Args:  []interface{}{slug},
+			},
+		},
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product category not found")
+	}
+
+	return data, nil
+}
+
+const invalidSlugMessage = "slug must only contain lowercase letters and digits, separated by single hyphens"
+
+// newSlug returns the slug given by the client after checking it, or one made from the name.
+func newSlug(name *string, categorySlug *string) (*string, error) {
+	if categorySlug != nil {
+		if !slug.IsValid(*categorySlug) {
+			return nil, apperror.Validation(invalidSlugMessage)
+		}
+		return categorySlug, nil
+	}
+
+	madeSlug := slug.Make(*name)
+	if len(madeSlug) == 0 {
+		return nil, apperror.Validation("a slug cannot be made from the name, so one has to be given")
+	}
+	return &madeSlug, nil
+}
+
+// maxSlugAttempts bounds the search for a free slug, after which the last one is tried and may conflict.
+const maxSlugAttempts = 100
+
+// productCategorySlugCandidate is the slug to try for a category at each attempt: the slug made from its name, then
+// that slug prefixed with the slug of the parent, such as men-shoes, and then the last of these with a numeric suffix.
+func productCategorySlugCandidate(madeSlug string, parent *pc.ProductCategoryModel, attempt int) string {
+	base := madeSlug
+	if parent != nil {
+		if attempt == 1 {
+			return *parent.Slug + "-" + madeSlug
+		}
+		base = *parent.Slug + "-" + madeSlug
+		attempt--
+	}
+	if attempt == 0 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(attempt+1)
+}
+
+// uniqueProductCategorySlug returns the first candidate slug that no category has, since slugs are unique across the
+// whole tree while names only are among siblings.
+func uniqueProductCategorySlug(ctx context.Context, madeSlug string, parent *pc.ProductCategoryModel) (string, error) {
+	candidate := madeSlug
+	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
+		candidate = productCategorySlugCandidate(madeSlug, parent, attempt)
+		count, err := pc.ProductCategoryRepository().WithContext(ctx).Count(&pg.CountOptions{
+			Where: &[]pg.Where{
+				{
+					Query: "slug = ?",
+					Args:  []interface{}{candidate},
+				},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		if *count == 0 {
+			break
+		}
+	}
+
+	return candidate, nil
+}
+
+// getParentProductCategory returns the category that is to become a parent, which has to exist.
+func getParentProductCategory(ctx context.Context, id *uuid.UUID) (*pc.ProductCategoryModel, error) {
+	data, err := pc.ProductCategoryRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
-	}); err != nil {
+		IsPrimary: true,
+	})
+	if err != nil {
+		if pg.IsErrRecordNotFound(err) {
+			return nil, apperror.Validation("parent category does not exist")
+		}
 		return nil, err
 	}
 
-	data, err := pc.ProductCategoryRepository().FindOne(&pg.FindOneOptions{
+	return data, nil
+}
+
+// siblingsWhere matches the children of parentID, or the top level categories when it is nil.
+func siblingsWhere(parentID *uuid.UUID) pg.Where {
+	if parentID == nil {
+		return pg.Where{
+			Query: "parent_id IS NULL",
+		}
+	}
+	return pg.Where{
+		Query: "parent_id = ?",
+		Args:  []interface{}{parentID},
+	}
+}
+
+// nextProductCategoryPosition returns the position after the last child of parentID.
+func nextProductCategoryPosition(ctx context.Context, parentID *uuid.UUID) (int, error) {
+	last, err := pc.ProductCategoryRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where:     &[]pg.Where{siblingsWhere(parentID)},
+		Order:     &[]string{"position DESC"},
+		IsPrimary: true,
+	})
+	if err != nil {
+		if pg.IsErrRecordNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return *last.Position + 1, nil
+}
+
+// addProductCategoryService adds the category as the last child of its parent. A slug made from the name is changed
+// to one no other category has when isSlugMade is set, while a slug given by the client is kept and may conflict.
+func (*Module) addProductCategoryService(ctx context.Context, data *pc.ProductCategoryModel, isSlugMade bool) (*pc.ProductCategoryModel, error) {
+	var parent *pc.ProductCategoryModel
+	if data.ParentID != nil {
+		var err error
+		parent, err = getParentProductCategory(ctx, data.ParentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isSlugMade {
+		categorySlug, err := uniqueProductCategorySlug(ctx, *data.Slug, parent)
+		if err != nil {
+			return nil, err
+		}
+		data.Slug = &categorySlug
+	}
+
+	position, err := nextProductCategoryPosition(ctx, data.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New()
+	path := pc.NewPath(parent, id)
+	data.ID = &id
+	data.Path = &path
+	data.Position = &position
+
+	return pc.ProductCategoryRepository().WithContext(ctx).Create(data)
+}
+
+func (*Module) updateProductCategoryService(ctx context.Context, id *uuid.UUID, data *pc.ProductCategoryModel) (*pc.ProductCategoryModel, error) {
+	if _, err := pc.ProductCategoryRepository().WithContext(ctx).Update(data, &pg.UpdateOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+	}); err != nil {
+		return nil, apperror.NotFoundOr(err, "product category not found")
+	}
+
+	data, err := pc.ProductCategoryRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{id},
+			},
+		},
+		IsPrimary: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "product category not found")
 	}
 
 	return data, nil
 }
 
-func (*Module) deleteProductCategoryService(id *uuid.UUID) error {
-	return pc.ProductCategoryRepository().Destroy(&pc.ProductCategoryModel{
-		Model: pg.Model{
-			ID: id,
-		},
+// moveProductCategoryService makes the category the last child of parentID, or a top level category when it is nil,
+// and rewrites the paths of the category and of all its descendants, deleted ones included, in a single transaction.
+func (m *Module) moveProductCategoryService(ctx context.Context, id *uuid.UUID, parentID *uuid.UUID) (*pc.ProductCategoryModel, error) {
+	category, err := m.getProductCategoryDetailService(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent *pc.ProductCategoryModel
+	if parentID != nil {
+		parent, err = getParentProductCategory(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.IsInSubtreeOf(category) {
+			return nil, apperror.Validation("a category cannot be moved under itself or one of its subcategories")
+		}
+	}
+
+	position, err := nextProductCategoryPosition(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPath := *category.Path
+	newPath := pc.NewPath(parent, *category.ID)
+	if err := pg.Transaction(m.DB.WithContext(ctx), func(tx *pg.DB) *pg.DB {
+		return pc.ProductCategoryRepository().UpdateTx(tx, &pc.ProductCategoryModel{
+			ParentID: parentID,
+			Position: &position,
+		}, &pg.UpdateOptions{
+			Where: &[]pg.Where{
+				{
+					Query: "id = ?",
+					Args:  []interface{}{id},
+				},
+			},
+			Columns: &[]string{"parent_id", "position"},
+		})
+	}, func(tx *pg.DB) *pg.DB {
+		return pc.ProductCategoryRepository().UpdateExprTx(tx, map[string]pg.Where{
+			"path": {
+				Query: "? || substr(path, ?)",
+				Args:  []interface{}{newPath, len(oldPath) + 1},
+			},
+		}, &pg.UpdateOptions{
+			Where: &[]pg.Where{
+				{
+					Query: "path LIKE ?",
+					Args:  []interface{}{oldPath + "%"},
+				},
+			},
+			IsUnscoped: true,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return m.getProductCategoryDetailService(ctx, id, true)
+}
+
+// reorderProductCategoriesService sets the position of every child of parentID to its index in categoryIDs, which
+// has to list each of them exactly once.
+func (m *Module) reorderProductCategoriesService(ctx context.Context, parentID *uuid.UUID, categoryIDs []uuid.UUID) ([]*pc.ProductCategoryModel, error) {
+	categories, err := findAllProductCategories(ctx, []pg.Where{siblingsWhere(parentID)}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	categoriesByID := make(map[uuid.UUID]*pc.ProductCategoryModel, len(categories))
+	for _, category := range categories {
+		categoriesByID[*category.ID] = category
+	}
+	if len(categoryIDs) != len(categoriesByID) {
+		return nil, apperror.Validation("category_ids must list every child of the parent exactly once")
+	}
+
+	ordered := make([]*pc.ProductCategoryModel, 0, len(categoryIDs))
+	txs := make([]func(tx *pg.DB) *pg.DB, 0, len(categoryIDs))
+	for i, categoryID := range categoryIDs {
+		category, ok := categoriesByID[categoryID]
+		if !ok {
+			return nil, apperror.Validation("category_ids must list every child of the parent exactly once")
+		}
+		delete(categoriesByID, categoryID)
+
+		position := i
+		category.Position = &position
+		ordered = append(ordered, category)
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return pc.ProductCategoryRepository().UpdateTx(tx, &pc.ProductCategoryModel{Position: &position}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{category.ID},
+					},
+				},
+				Columns: &[]string{"position"},
+			})
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		return nil, err
+	}
+
+	return ordered, nil
+}
+
+// getProductCountByProductCategoryService counts the products of the category, deleted products included, along with
+// the products of its descendants when isCascade is set.
+func (*Module) getProductCountByProductCategoryService(ctx context.Context, category *pc.ProductCategoryModel, isCascade bool) (*int64, error) {
+	where := pg.Where{
+		Query: "category_id = ?",
+		Args:  []interface{}{category.ID},
+	}
+	if isCascade {
+		where = pg.Where{
+			Query: "category_id IN (SELECT id FROM product_categories WHERE path LIKE ?)",
+			Args:  []interface{}{*category.Path + "%"},
+		}
+	}
+
+	return p.ProductRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where:      &[]pg.Where{where},
+		IsUnscoped: true,
 	})
 }
 
-func (*Module) getProductCountByProductCategoryID(id *uuid.UUID) (*int64, error) {
-	return p.ProductRepository().Count(&pg.CountOptions{
+// deleteProductCategoryService deletes the category, along with its descendants when isCascade is set, in a single
+// transaction. The products of the deleted categories, deleted products included, are moved to reassignTo when it is
+// set, and are deleted along with the categories otherwise, which the caller has to make sure is asked for.
+func (m *Module) deleteProductCategoryService(ctx context.Context, category *pc.ProductCategoryModel, isCascade bool, reassignTo *uuid.UUID) error {
+	categoryWhere := pg.Where{
+		Query: "id = ?",
+		Args:  []interface{}{category.ID},
+	}
+	productWhere := pg.Where{
+		Query: "category_id = ?",
+		Args:  []interface{}{category.ID},
+	}
+	if isCascade {
+		categoryWhere = pg.Where{
+			Query: "path LIKE ?",
+			Args:  []interface{}{*category.Path + "%"},
+		}
+		productWhere = pg.Where{
+			Query: "category_id IN (SELECT id FROM product_categories WHERE path LIKE ?)",
+			Args:  []interface{}{*category.Path + "%"},
+		}
+	}
+
+	txs := []func(tx *pg.DB) *pg.DB{}
+	if reassignTo != nil {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductRepository().UpdateTx(tx, &p.ProductModel{CategoryID: reassignTo}, &pg.UpdateOptions{
+				Where:      &[]pg.Where{productWhere},
+				IsUnscoped: true,
+			})
+		})
+	} else {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductRepository().DestroyTx(tx, &p.ProductModel{}, &pg.DestroyOptions{
+				Where: &[]pg.Where{productWhere},
+			})
+		})
+	}
+	txs = append(txs, func(tx *pg.DB) *pg.DB {
+		return pc.ProductCategoryRepository().DestroyTx(tx, &pc.ProductCategoryModel{}, &pg.DestroyOptions{
+			Where: &[]pg.Where{categoryWhere},
+		})
+	})
+
+	return pg.Transaction(m.DB.WithContext(ctx), txs...)
+}
+
+func (*Module) getProductCategoryChildCountService(ctx context.Context, id *uuid.UUID) (*int64, error) {
+	return pc.ProductCategoryRepository().WithContext(ctx).Count(&pg.CountOptions{
 		Where: &[]pg.Where{
 			{
-				Query: "category_id = ?",
+				Query: "parent_id = ?",
 				Args:  []interface{}{id},
 			},
 		},
-		IsUnscoped: true,
 	})
 }
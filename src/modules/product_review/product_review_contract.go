@@ -0,0 +1,89 @@
+package productreview
+
+import (
+	"mime/multipart"
+
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
+)
+
+type getProductReviewListReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type getProductReviewListReqQuery struct {
+	Limit        *int    `query:"limit"`
+	Page         *int    `query:"page"`
+	Cursor       *string `query:"cursor"`
+	IncludeTotal *bool   `query:"include_total"`
+}
+
+// productReviewListQueryFields whitelists the fields accepted in filter[...] and sort.
+var productReviewListQueryFields = parser.QueryFields{
+	"rating": {
+		Column:     "rating",
+		Type:       parser.FIELD_TYPE_INT,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_GTE, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"created_at": {
+		Column:     "created_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+}
+
+type getAdminProductReviewListReqQuery struct {
+	Limit        *int    `query:"limit"`
+	Page         *int    `query:"page"`
+	Cursor       *string `query:"cursor"`
+	IncludeTotal *bool   `query:"include_total"`
+}
+
+// adminProductReviewListQueryFields also lets admins find the reviews waiting for moderation, and the reviews of a
+// product or by a user.
+var adminProductReviewListQueryFields = parser.QueryFields{
+	"status": {
+		Column:    "status",
+		Type:      parser.FIELD_TYPE_STRING,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_IN},
+	},
+	"product_id": {
+		Column:    "product_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ},
+	},
+	"user_id": {
+		Column:    "user_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ},
+	},
+	"rating":     productReviewListQueryFields["rating"],
+	"created_at": productReviewListQueryFields["created_at"],
+}
+
+type addProductReviewReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type addProductReviewReq struct {
+	Rating *int    `json:"rating" validate:"required,gte=1,lte=5"`
+	Text   *string `json:"text" validate:"required,gt=0,lte=5000"`
+}
+
+type addProductReviewImageReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type addProductReviewImageReq struct {
+	Image *multipart.FileHeader `form:"image" validate:"required"`
+}
+
+type approveProductReviewReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+type hideProductReviewReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
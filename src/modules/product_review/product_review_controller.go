@@ -0,0 +1,333 @@
+package productreview
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/contracts"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/imaging"
+	"hilmy.dev/store/src/libs/openapi"
+	"hilmy.dev/store/src/libs/parser"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	a "hilmy.dev/store/src/modules/auth/auth_entity"
+	am "hilmy.dev/store/src/modules/auth/auth_middleware"
+	"hilmy.dev/store/src/modules/log"
+	pr "hilmy.dev/store/src/modules/product_review/product_review_entity"
+)
+
+func (m *Module) controller() {
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/product/:id/reviews", &openapi.Operation{
+		Summary:   "List the approved reviews of a product",
+		Tags:      []string{"product review"},
+		Params:    getProductReviewListReqParam{},
+		Query:     getProductReviewListReqQuery{},
+		ListQuery: productReviewListQueryFields,
+		Response:  pr.ProductReviewModel{},
+		IsList:    true,
+	}, m.getProductReviewList)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product/:id/review", &openapi.Operation{
+		Summary:  "Review a bought product, which is shown once an admin approves it",
+		Tags:     []string{"product review"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   addProductReviewReqParam{},
+		Body:     addProductReviewReq{},
+		Status:   fiber.StatusCreated,
+		Response: pr.ProductReviewModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.addProductReview)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/product-review/:id/image", &openapi.Operation{
+		Summary:  "Add an image to an own review that is waiting for moderation",
+		Tags:     []string{"product review"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   addProductReviewImageReqParam{},
+		Form:     addProductReviewImageReq{},
+		Status:   fiber.StatusCreated,
+		Response: pr.ProductReviewImageModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.addProductReviewImage)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/admin/product-reviews", &openapi.Operation{
+		Summary:   "List the reviews of every product and status",
+		Tags:      []string{"product review"},
+		Roles:     []string{string(acc.ROLE_ADMIN)},
+		Query:     getAdminProductReviewListReqQuery{},
+		ListQuery: adminProductReviewListQueryFields,
+		Response:  pr.ProductReviewModel{},
+		IsList:    true,
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.getAdminProductReviewList)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/admin/product-review/:id/approve", &openapi.Operation{
+		Summary:  "Approve a product review, counting it towards the rating of the product",
+		Tags:     []string{"product review"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   approveProductReviewReqParam{},
+		Response: pr.ProductReviewModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.approveProductReview)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/admin/product-review/:id/hide", &openapi.Operation{
+		Summary:  "Hide a product review, removing it from the rating of the product",
+		Tags:     []string{"product review"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   hideProductReviewReqParam{},
+		Response: pr.ProductReviewModel{},
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.hideProductReview)
+}
+
+func (m *Module) getProductReviewList(c *fiber.Ctx) error {
+	param := new(getProductReviewListReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	query := new(getProductReviewListReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	listQuery, err := parser.ParseReqListQuery(c, productReviewListQueryFields, "-created_at")
+	if err != nil {
+		return err
+	}
+
+	isAdmin, err := am.HasRole(c, acc.ROLE_ADMIN)
+	if err != nil {
+		return err
+	}
+
+	productDetailData, err := m.getProductDetailService(c.UserContext(), param.ID, false)
+	if err != nil {
+		return err
+	}
+	if !isAdmin && !productDetailData.IsVisible(time.Now()) {
+		return apperror.NotFound("product not found")
+	}
+
+	offset := 0
+	if query.Page != nil && query.Limit != nil && *query.Page > 0 && *query.Limit > 0 {
+		offset = (*query.Page - 1) * *query.Limit
+	}
+
+	status := pr.STATUS_APPROVED
+	productReviewListData, page, err := m.getProductReviewListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
+	}, &searchOptions{
+		filters:     listQuery.Where,
+		byProductID: param.ID,
+		byStatus:    &status,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Pagination: &contracts.Pagination{
+			Limit: page.limit,
+			Count: page.count,
+			Page:  query.Page,
+			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
+		},
+		Data: productReviewListData,
+	})
+}
+
+func (m *Module) addProductReview(c *fiber.Ctx) error {
+	token := new(a.JWTPayload)
+	if err := parser.ParseReqBearerToken(c, token); err != nil {
+		return err
+	}
+
+	param := new(addProductReviewReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(addProductReviewReq)
+	if err := parser.ParseReqBody(c, req); err != nil {
+		return err
+	}
+
+	productDetailData, err := m.getProductDetailService(c.UserContext(), param.ID, true)
+	if err != nil {
+		return err
+	}
+	if !productDetailData.IsVisible(time.Now()) {
+		return apperror.NotFound("product not found")
+	}
+
+	isBuyer, err := m.hasBoughtProductService(c.UserContext(), token.ID, param.ID)
+	if err != nil {
+		return err
+	}
+	if !isBuyer {
+		return apperror.Forbidden("only buyers of the product can review it")
+	}
+
+	reviewCount, err := m.getProductReviewCountByUserIDService(c.UserContext(), token.ID, param.ID)
+	if err != nil {
+		return err
+	}
+	if *reviewCount > 0 {
+		return apperror.Conflict("the product is already reviewed")
+	}
+
+	status := pr.STATUS_PENDING
+	productReviewDetailData, err := m.addProductReviewService(c.UserContext(), &pr.ProductReviewModel{
+		ProductID: param.ID,
+		UserID:    token.ID,
+		Rating:    req.Rating,
+		Text:      req.Text,
+		Status:    &status,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
+		Data: productReviewDetailData,
+	})
+}
+
+func (m *Module) addProductReviewImage(c *fiber.Ctx) error {
+	token := new(a.JWTPayload)
+	if err := parser.ParseReqBearerToken(c, token); err != nil {
+		return err
+	}
+
+	param := new(addProductReviewImageReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	req := new(addProductReviewImageReq)
+	if err := parser.ParseReqMultipartForm(c, req); err != nil {
+		return err
+	}
+	if req.Image.Size > int64(m.ImageMaxSize) {
+		return apperror.Validation(fmt.Sprintf("image must not be larger than %d bytes", m.ImageMaxSize))
+	}
+
+	productReviewDetailData, err := m.getProductReviewDetailService(c.UserContext(), param.ID)
+	if err != nil {
+		return err
+	}
+	if *productReviewDetailData.UserID != *token.ID {
+		return apperror.NotFound("product review not found")
+	}
+	// An approved review is already public, so its images could otherwise skip moderation.
+	if *productReviewDetailData.Status != pr.STATUS_PENDING {
+		return apperror.InvalidState("images can only be added to a review before it is moderated")
+	}
+
+	imageCount, err := m.getProductReviewImageCountService(c.UserContext(), param.ID)
+	if err != nil {
+		return err
+	}
+	if *imageCount >= int64(m.ImageMaxCount) {
+		return apperror.Conflict(fmt.Sprintf("a review can have at most %d images", m.ImageMaxCount))
+	}
+
+	original, _, err := parser.ParseMultipartFileToBytes(req.Image)
+	if err != nil {
+		return err
+	}
+
+	// The declared content type is ignored, the format is sniffed from the data instead.
+	img, err := imaging.Decode(*original)
+	if err != nil {
+		return apperror.Validation(err.Error())
+	}
+
+	productReviewImageData, err := m.addProductReviewImageService(c.UserContext(), param.ID, *original, img)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
+		Data: productReviewImageData,
+	})
+}
+
+func (m *Module) getAdminProductReviewList(c *fiber.Ctx) error {
+	query := new(getAdminProductReviewListReqQuery)
+	if err := parser.ParseReqQuery(c, query); err != nil {
+		return err
+	}
+
+	listQuery, err := parser.ParseReqListQuery(c, adminProductReviewListQueryFields, "-created_at")
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	if query.Page != nil && query.Limit != nil && *query.Page > 0 && *query.Limit > 0 {
+		offset = (*query.Page - 1) * *query.Limit
+	}
+
+	productReviewListData, page, err := m.getProductReviewListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
+	}, &searchOptions{
+		filters: listQuery.Where,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Pagination: &contracts.Pagination{
+			Limit: page.limit,
+			Count: page.count,
+			Page:  query.Page,
+			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
+		},
+		Data: productReviewListData,
+	})
+}
+
+func (m *Module) approveProductReview(c *fiber.Ctx) error {
+	param := new(approveProductReviewReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	productReviewDetailData, err := m.moderateProductReviewService(c.UserContext(), param.ID, pr.STATUS_APPROVED)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productReviewDetailData,
+	})
+}
+
+func (m *Module) hideProductReview(c *fiber.Ctx) error {
+	param := new(hideProductReviewReqParam)
+	if err := parser.ParseReqParam(c, param); err != nil {
+		return err
+	}
+
+	productReviewDetailData, err := m.moderateProductReviewService(c.UserContext(), param.ID, pr.STATUS_HIDDEN)
+	if err != nil {
+		return err
+	}
+
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+		Data: productReviewDetailData,
+	})
+}
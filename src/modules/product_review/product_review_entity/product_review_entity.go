@@ -0,0 +1,68 @@
+package productreviewentity
+
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/db/pg"
+	applogger "hilmy.dev/store/src/libs/logger"
+	a "hilmy.dev/store/src/modules/account/account_entity"
+)
+
+type ReviewStatus string
+
+const (
+	STATUS_PENDING  ReviewStatus = "PENDING"
+	STATUS_APPROVED ReviewStatus = "APPROVED"
+	STATUS_HIDDEN   ReviewStatus = "HIDDEN"
+)
+
+// ProductReviewModel is the review of a product by one of its buyers. It is only shown, and only counts towards the
+// rating of the product, once an admin approves it.
+type ProductReviewModel struct {
+	pg.Model
+	ProductID *uuid.UUID                 `gorm:"not null" json:"productId,omitempty"`
+	UserID    *uuid.UUID                 `gorm:"not null" json:"userId,omitempty"`
+	User      *a.AccountModel            `json:"user,omitempty"`
+	Rating    *int                       `gorm:"not null;check:chk_product_reviews_rating,rating BETWEEN 1 AND 5" json:"rating,omitempty"`
+	Text      *string                    `gorm:"not null" json:"text,omitempty"`
+	Status    *ReviewStatus              `gorm:"not null;default:'PENDING'" json:"status,omitempty"`
+	Images    []*ProductReviewImageModel `gorm:"foreignKey:ReviewID" json:"images,omitempty"`
+}
+
+func (ProductReviewModel) TableName() string {
+	return "product_reviews"
+}
+
+// RatingChange is 1 when moving a review from one status to the other adds it to the rating of the product, -1 when it
+// removes it, and 0 otherwise.
+func RatingChange(from ReviewStatus, to ReviewStatus) int {
+	switch {
+	case from != STATUS_APPROVED && to == STATUS_APPROVED:
+		return 1
+	case from == STATUS_APPROVED && to != STATUS_APPROVED:
+		return -1
+	}
+	return 0
+}
+
+type productReviewDB = pg.Service[ProductReviewModel]
+
+var productReviewRepo *productReviewDB
+var productReviewImageRepo *productReviewImageDB
+var logger = applogger.New("ProductReviewModule")
+
+func InitRepository(db *pg.DB) {
+	if db == nil {
+		logger.Panic("db cannot be nil")
+	}
+
+	productReviewRepo = pg.NewService[ProductReviewModel](db)
+	productReviewImageRepo = pg.NewService[ProductReviewImageModel](db)
+}
+
+func ProductReviewRepository() *productReviewDB {
+	if productReviewRepo == nil {
+		logger.Panic("productReviewRepo is nil")
+	}
+
+	return productReviewRepo
+}
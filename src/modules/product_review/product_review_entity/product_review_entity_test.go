@@ -0,0 +1,25 @@
+package productreviewentity
+
+import "testing"
+
+func TestRatingChange(t *testing.T) {
+	tests := []struct {
+		from ReviewStatus
+		to   ReviewStatus
+		want int
+	}{
+		{STATUS_PENDING, STATUS_APPROVED, 1},
+		{STATUS_HIDDEN, STATUS_APPROVED, 1},
+		{STATUS_APPROVED, STATUS_HIDDEN, -1},
+		{STATUS_APPROVED, STATUS_PENDING, -1},
+		{STATUS_PENDING, STATUS_HIDDEN, 0},
+		{STATUS_HIDDEN, STATUS_PENDING, 0},
+		{STATUS_APPROVED, STATUS_APPROVED, 0},
+		{STATUS_HIDDEN, STATUS_HIDDEN, 0},
+	}
+	for _, tt := range tests {
+		if got := RatingChange(tt.from, tt.to); got != tt.want {
+			t.Errorf("RatingChange(%s, %s) = %d, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
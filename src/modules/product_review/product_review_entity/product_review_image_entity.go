@@ -0,0 +1,37 @@
+package productreviewentity
+
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/db/pg"
+)
+
+// ProductReviewImageModel is an image attached to a review, stored the same way as the images of products.
+type ProductReviewImageModel struct {
+	pg.Model
+	ReviewID     *uuid.UUID `gorm:"not null" json:"reviewId,omitempty"`
+	Position     *int       `gorm:"not null" json:"position,omitempty"`
+	ContentType  *string    `gorm:"not null" json:"contentType,omitempty"`
+	Width        *int       `gorm:"not null" json:"width,omitempty"`
+	Height       *int       `gorm:"not null" json:"height,omitempty"`
+	Size         *int64     `gorm:"not null" json:"size,omitempty"`
+	OriginalKey  *string    `gorm:"not null" json:"-"`
+	ThumbnailKey *string    `gorm:"not null" json:"-"`
+	MediumKey    *string    `gorm:"not null" json:"-"`
+	URL          *string    `gorm:"-" json:"url,omitempty"`
+	ThumbnailURL *string    `gorm:"-" json:"thumbnailUrl,omitempty"`
+	MediumURL    *string    `gorm:"-" json:"mediumUrl,omitempty"`
+}
+
+func (ProductReviewImageModel) TableName() string {
+	return "product_review_images"
+}
+
+type productReviewImageDB = pg.Service[ProductReviewImageModel]
+
+func ProductReviewImageRepository() *productReviewImageDB {
+	if productReviewImageRepo == nil {
+		logger.Panic("productReviewImageRepo is nil")
+	}
+
+	return productReviewImageRepo
+}
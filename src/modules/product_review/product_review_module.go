@@ -0,0 +1,22 @@
+package productreview
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/storage"
+	pr "hilmy.dev/store/src/modules/product_review/product_review_entity"
+)
+
+type Module struct {
+	App     *fiber.App
+	DB      *pg.DB
+	Storage storage.Storage
+	// ImageMaxSize is the largest accepted image in bytes, and ImageMaxCount the number of images a review may have.
+	ImageMaxSize  int
+	ImageMaxCount int
+}
+
+func Load(module *Module) {
+	pr.InitRepository(module.DB)
+	module.controller()
+}
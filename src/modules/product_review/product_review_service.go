@@ -0,0 +1,392 @@
+package productreview
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/imaging"
+	p "hilmy.dev/store/src/modules/product/product_entity"
+	pr "hilmy.dev/store/src/modules/product_review/product_review_entity"
+	t "hilmy.dev/store/src/modules/transaction/transaction_entity"
+)
+
+type searchOptions struct {
+	filters     *[]pg.FindAllWhere
+	byProductID *uuid.UUID
+	byStatus    *pr.ReviewStatus
+}
+
+type paginationOptions struct {
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
+}
+
+type paginationQuery struct {
+	limit *int
+	count *int
+	total *int
+	next  *string
+	prev  *string
+}
+
+// Every uploaded image gets resized variants whose longest side is at most these sizes in pixels.
+const (
+	thumbnailImageSize = 320
+	mediumImageSize    = 960
+)
+
+// errReviewModerated is returned from a moderation transaction when the review changed status since it was read.
+var errReviewModerated = errors.New("product review was moderated concurrently")
+
+func (m *Module) getProductReviewListService(ctx context.Context, pagination *paginationOptions, search *searchOptions) (*[]*pr.ProductReviewModel, *paginationQuery, error) {
+	where := []pg.FindAllWhere{}
+	limit := 0
+	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
+
+	if search != nil {
+		if search.byProductID != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "product_id = ?",
+					Args:  []interface{}{search.byProductID},
+				},
+				IncludeInCount: true,
+			})
+		}
+		if search.byStatus != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "status = ?",
+					Args:  []interface{}{search.byStatus},
+				},
+				IncludeInCount: true,
+			})
+		}
+		if search.filters != nil {
+			where = append(where, *search.filters...)
+		}
+	}
+
+	if pagination != nil {
+		if pagination.limit != nil && *pagination.limit > 0 {
+			limit = *pagination.limit
+		}
+		if pagination.offset != nil && *pagination.offset > 0 {
+			offset = *pagination.offset
+		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
+	}
+
+	data, page, err := pr.ProductReviewRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where:         &where,
+		Limit:         &limit,
+		Offset:        &offset,
+		Keyset:        keyset,
+		Order:         order,
+		Cursor:        cursor,
+		IncludeTables: reviewIncludeTables(),
+		IsSkipCount:   isSkipCount,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, review := range *data {
+		m.setImageURLs(review.Images)
+	}
+
+	return data, &paginationQuery{
+		limit: &page.Limit,
+		count: &page.Count,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
+	}, nil
+}
+
+// reviewIncludeTables preloads the images of a review and the name of its author, leaving the rest of the account
+// out of public responses.
+func reviewIncludeTables() *[]pg.IncludeTables {
+	return &[]pg.IncludeTables{
+		{
+			Query: "User",
+			Args: []interface{}{func(db *pg.DB) *pg.DB {
+				return db.Select("id", "name")
+			}},
+		},
+		{
+			Query: "Images",
+			Args: []interface{}{func(db *pg.DB) *pg.DB {
+				return db.Order("position").Order("created_at")
+			}},
+		},
+	}
+}
+
+func (m *Module) getProductReviewDetailService(ctx context.Context, id *uuid.UUID) (*pr.ProductReviewModel, error) {
+	data, err := pr.ProductReviewRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{id},
+			},
+		},
+		IncludeTables: reviewIncludeTables(),
+		// The review is only looked up on its own to be moderated or to have an image added, based on its status.
+		IsPrimary: true,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product review not found")
+	}
+
+	m.setImageURLs(data.Images)
+	return data, nil
+}
+
+// getProductDetailService reads from the primary when isPrimary is set, for when a review is added based on it.
+func (*Module) getProductDetailService(ctx context.Context, id *uuid.UUID, isPrimary bool) (*p.ProductModel, error) {
+	data, err := p.ProductRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{id},
+			},
+		},
+		IsPrimary: isPrimary,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product not found")
+	}
+
+	return data, nil
+}
+
+// hasBoughtProductService reports whether the user has a completed transaction with the product among its items.
+// The items are the shopping cart items the transaction was made from, which is how the product is matched.
+func (*Module) hasBoughtProductService(ctx context.Context, userID *uuid.UUID, productID *uuid.UUID) (bool, error) {
+	item, err := sonic.Marshal([]map[string]string{{"productId": productID.String()}})
+	if err != nil {
+		return false, err
+	}
+
+	count, err := t.TransactionRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "user_id = ?",
+				Args:  []interface{}{userID},
+			},
+			{
+				Query: "status = ?",
+				Args:  []interface{}{t.STATUS_COMPLETED},
+			},
+			{
+				Query: "data @> ?::jsonb",
+				Args:  []interface{}{string(item)},
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return *count > 0, nil
+}
+
+func (*Module) getProductReviewCountByUserIDService(ctx context.Context, userID *uuid.UUID, productID *uuid.UUID) (*int64, error) {
+	return pr.ProductReviewRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "user_id = ?",
+				Args:  []interface{}{userID},
+			},
+			{
+				Query: "product_id = ?",
+				Args:  []interface{}{productID},
+			},
+		},
+	})
+}
+
+func (*Module) addProductReviewService(ctx context.Context, data *pr.ProductReviewModel) (*pr.ProductReviewModel, error) {
+	return pr.ProductReviewRepository().WithContext(ctx).Create(data)
+}
+
+func (m *Module) setImageURLs(images []*pr.ProductReviewImageModel) {
+	for _, image := range images {
+		url := m.Storage.URL(*image.OriginalKey)
+		thumbnailURL := m.Storage.URL(*image.ThumbnailKey)
+		mediumURL := m.Storage.URL(*image.MediumKey)
+		image.URL = &url
+		image.ThumbnailURL = &thumbnailURL
+		image.MediumURL = &mediumURL
+	}
+}
+
+func (*Module) getProductReviewImageCountService(ctx context.Context, reviewID *uuid.UUID) (*int64, error) {
+	return pr.ProductReviewImageRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "review_id = ?",
+				Args:  []interface{}{reviewID},
+			},
+		},
+	})
+}
+
+// addProductReviewImageService stores the original image with its variants and appends it to the images of the
+// review. Stored files are removed again when a later step fails.
+func (m *Module) addProductReviewImageService(ctx context.Context, reviewID *uuid.UUID, original []byte, img *imaging.Image) (*pr.ProductReviewImageModel, error) {
+	thumbnail, err := imaging.EncodeVariant(imaging.Fit(img, thumbnailImageSize))
+	if err != nil {
+		return nil, err
+	}
+	medium, err := imaging.EncodeVariant(imaging.Fit(img, mediumImageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	last, err := pr.ProductReviewImageRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "review_id = ?",
+				Args:  []interface{}{reviewID},
+			},
+		},
+		Order:     &[]string{"position DESC"},
+		IsPrimary: true,
+	})
+	if err != nil && !pg.IsErrRecordNotFound(err) {
+		return nil, err
+	}
+	if last != nil {
+		position = *last.Position + 1
+	}
+
+	imageID := uuid.New()
+	prefix := "product-reviews/" + reviewID.String() + "/" + imageID.String() + "/"
+	files := []struct {
+		key         string
+		body        []byte
+		contentType string
+	}{
+		{key: prefix + "original" + img.Extension, body: original, contentType: img.ContentType},
+		{key: prefix + "thumbnail" + thumbnail.Extension, body: thumbnail.Data, contentType: thumbnail.ContentType},
+		{key: prefix + "medium" + medium.Extension, body: medium.Data, contentType: medium.ContentType},
+	}
+
+	stored := []string{}
+	removeStored := func() {
+		for _, key := range stored {
+			m.Storage.Delete(context.WithoutCancel(ctx), key)
+		}
+	}
+	for _, file := range files {
+		if err := m.Storage.Put(ctx, file.key, file.body, file.contentType); err != nil {
+			removeStored()
+			return nil, err
+		}
+		stored = append(stored, file.key)
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	size := int64(len(original))
+	data, err := pr.ProductReviewImageRepository().WithContext(ctx).Create(&pr.ProductReviewImageModel{
+		Model: pg.Model{
+			ID: &imageID,
+		},
+		ReviewID:     reviewID,
+		Position:     &position,
+		ContentType:  &img.ContentType,
+		Width:        &width,
+		Height:       &height,
+		Size:         &size,
+		OriginalKey:  &files[0].key,
+		ThumbnailKey: &files[1].key,
+		MediumKey:    &files[2].key,
+	})
+	if err != nil {
+		removeStored()
+		return nil, err
+	}
+
+	m.setImageURLs([]*pr.ProductReviewImageModel{data})
+	return data, nil
+}
+
+// moderateProductReviewService moves the review to status, and adds its rating to or removes it from the rating of
+// the product when the review becomes or stops being approved. Both happen in one transaction, which is rolled back
+// when the review was moderated by someone else in the meantime, so that a rating is never counted twice.
+func (m *Module) moderateProductReviewService(ctx context.Context, id *uuid.UUID, status pr.ReviewStatus) (*pr.ProductReviewModel, error) {
+	review, err := m.getProductReviewDetailService(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if *review.Status == status {
+		return review, nil
+	}
+
+	txs := []func(tx *pg.DB) *pg.DB{
+		func(tx *pg.DB) *pg.DB {
+			result := pr.ProductReviewRepository().UpdateTx(tx, &pr.ProductReviewModel{Status: &status}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{id},
+					},
+					{
+						Query: "status = ?",
+						Args:  []interface{}{review.Status},
+					},
+				},
+			})
+			if result.Error == nil && result.RowsAffected == 0 {
+				result.AddError(errReviewModerated)
+			}
+			return result
+		},
+	}
+
+	// The product may be in the trash, and keeps a correct rating for when it is restored.
+	byProduct := &pg.UpdateOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{review.ProductID},
+			},
+		},
+		IsUnscoped: true,
+	}
+	if change := pr.RatingChange(*review.Status, status); change != 0 {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return p.ProductRepository().UpdateExprTx(tx, p.RatingExprs(change, *review.Rating), byProduct)
+		})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		if errors.Is(err, errReviewModerated) {
+			return nil, apperror.InvalidState("the review was moderated at the same time, try again")
+		}
+		return nil, err
+	}
+
+	review.Status = &status
+	return review, nil
+}
@@ -1,14 +1,53 @@
 package shoppingcart
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
+)
 
 type getShoppingCartItemListReqQuery struct {
-	Limit *int `query:"limit"`
-	Page  *int `query:"page"`
+	Limit        *int    `query:"limit"`
+	Page         *int    `query:"page"`
+	Cursor       *string `query:"cursor"`
+	IncludeTotal *bool   `query:"include_total"`
+}
+
+// shoppingCartItemListQueryFields whitelists the fields accepted in filter[...] and sort.
+var shoppingCartItemListQueryFields = parser.QueryFields{
+	"product_id": {
+		Column:    "product_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_IN},
+	},
+	"variant_id": {
+		Column:    "variant_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_IN},
+	},
+	"amount": {
+		Column:     "amount",
+		Type:       parser.FIELD_TYPE_INT,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"created_at": {
+		Column:     "created_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"updated_at": {
+		Column:     "updated_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
 }
 
 type addShoppingCartItemReq struct {
 	ProductID *uuid.UUID `json:"productId" validate:"required"`
+	// VariantID is required for products with variants, and must be left out for the others.
+	VariantID *uuid.UUID `json:"variantId"`
 	Amount    *int       `json:"amount" validate:"required"`
 }
 
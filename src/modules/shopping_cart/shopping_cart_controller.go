@@ -1,47 +1,72 @@
 package shoppingcart
 
 import (
-	"errors"
+	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
 	am "hilmy.dev/store/src/modules/auth/auth_middleware"
 	"hilmy.dev/store/src/modules/log"
+	p "hilmy.dev/store/src/modules/product/product_entity"
 	sc "hilmy.dev/store/src/modules/shopping_cart/shopping_cart_entity"
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/shopping-cart-items", am.AuthGuard(acc.ROLE_USER), m.getShoppingCartItemList)
-	m.App.Post("/api/v1/shopping-cart-item", am.AuthGuard(acc.ROLE_USER), m.addShoppingCartItem)
-	m.App.Patch("/api/v1/shopping-cart-item/:id", am.AuthGuard(acc.ROLE_USER), m.updateShoppingCartItem)
-	m.App.Delete("/api/v1/shopping-cart-item/:id", am.AuthGuard(acc.ROLE_USER), m.deleteShoppingCartItem)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/shopping-cart-items", &openapi.Operation{
+		Summary:   "List the items in the shopping cart",
+		Tags:      []string{"shopping cart"},
+		Roles:     []string{string(acc.ROLE_USER)},
+		Query:     getShoppingCartItemListReqQuery{},
+		ListQuery: shoppingCartItemListQueryFields,
+		Response:  sc.ShoppingCartItemModel{},
+		IsList:    true,
+	}, am.AuthGuard(acc.ROLE_USER), m.getShoppingCartItemList)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/shopping-cart-item", &openapi.Operation{
+		Summary:  "Add a product, or a variant of it, to the shopping cart",
+		Tags:     []string{"shopping cart"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Body:     addShoppingCartItemReq{},
+		Status:   fiber.StatusCreated,
+		Response: sc.ShoppingCartItemModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.addShoppingCartItem)
+	openapi.Route(m.App, fiber.MethodPatch, "/api/v1/shopping-cart-item/:id", &openapi.Operation{
+		Summary:  "Update an item in the shopping cart",
+		Tags:     []string{"shopping cart"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   updateShoppingCartItemReqParam{},
+		Body:     updateShoppingCartItemReq{},
+		Response: sc.ShoppingCartItemModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.updateShoppingCartItem)
+	openapi.Route(m.App, fiber.MethodDelete, "/api/v1/shopping-cart-item/:id", &openapi.Operation{
+		Summary:  "Remove an item from the shopping cart",
+		Tags:     []string{"shopping cart"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   deleteShoppingCartItemReqParam{},
+		Response: uuid.UUID{},
+	}, am.AuthGuard(acc.ROLE_USER), m.deleteShoppingCartItem)
 }
 
 func (m *Module) getShoppingCartItemList(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	query := new(getShoppingCartItemListReqQuery)
 	if err := parser.ParseReqQuery(c, query); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+
+	listQuery, err := parser.ParseReqListQuery(c, shoppingCartItemListQueryFields, "created_at")
+	if err != nil {
+		return err
 	}
 
 	offset := 0
@@ -49,29 +74,30 @@ func (m *Module) getShoppingCartItemList(c *fiber.Ctx) error {
 		offset = (*query.Page - 1) * *query.Limit
 	}
 
-	shoppingCartItemListData, page, err := m.getShoppingCartItemListService(&paginationOptions{
-		limit:  query.Limit,
-		offset: &offset,
+	shoppingCartItemListData, page, err := m.getShoppingCartItemListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
 	}, &searchOptions{
+		filters:  listQuery.Where,
 		byUserID: token.ID,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Pagination: &contracts.Pagination{
 			Limit: page.limit,
 			Count: page.count,
 			Page:  query.Page,
 			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
 		},
 		Data: shoppingCartItemListData,
 	})
@@ -80,100 +106,81 @@ func (m *Module) getShoppingCartItemList(c *fiber.Ctx) error {
 func (m *Module) addShoppingCartItem(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(addShoppingCartItemReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	productCount, err := m.countProductService(req.ProductID)
+	productDetailData, err := m.getProductDetailService(c.UserContext(), req.ProductID)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+			return apperror.Validation("unregistered product")
+		}
+		return err
 	}
-	if *productCount == 0 {
-		err := errors.New("unregistered product")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+	// Drafts are not disclosed, while archived and unpublished products are known to the buyer but cannot be bought.
+	if *productDetailData.Status == p.STATUS_DRAFT {
+		return apperror.Validation("unregistered product")
+	}
+	if *productDetailData.Status == p.STATUS_ARCHIVED {
+		return apperror.InvalidState("the product is archived")
+	}
+	if !productDetailData.IsLive(time.Now()) {
+		return apperror.InvalidState("the product is not available")
 	}
 
-	shoppingCartItemDetailData, err := m.getShoppingCartItemByProductIDService(token.ID, req.ProductID)
-	if err != nil {
-		if pg.IsErrRecordNotFound(err) {
-			_shoppingCartItemDetailData, err := m.addShoppingCartItemService(&sc.ShoppingCartItemModel{
-				UserID:    token.ID,
-				ProductID: req.ProductID,
-				Amount:    req.Amount,
-			})
-			if err != nil {
-				log.SaveLogService(c.OriginalURL(), err.Error(), true)
-				return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-					Error: &contracts.Error{
-						Status:  fiber.ErrInternalServerError.Error(),
-						Message: err.Error(),
-					},
-				})
+	var variantDetailData *p.ProductVariantModel
+	if req.VariantID != nil {
+		variantDetailData, err = m.getProductVariantDetailService(c.UserContext(), req.ProductID, req.VariantID)
+		if err != nil {
+			if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+				return apperror.Validation("unregistered product variant")
 			}
-			shoppingCartItemDetailData = _shoppingCartItemDetailData
-		} else {
-			log.SaveLogService(c.OriginalURL(), err.Error(), true)
-			return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrInternalServerError.Error(),
-					Message: err.Error(),
-				},
-			})
+			return err
 		}
-	} else if shoppingCartItemDetailData != nil {
-		*shoppingCartItemDetailData.Amount += *req.Amount
-		_shoppingCartItemDetailData, err := m.updateShoppingCartItemService(token.ID, shoppingCartItemDetailData.ID, &sc.ShoppingCartItemModel{
-			Amount: shoppingCartItemDetailData.Amount,
-		})
+	} else {
+		variantCount, err := m.countProductVariantService(c.UserContext(), req.ProductID)
 		if err != nil {
-			log.SaveLogService(c.OriginalURL(), err.Error(), true)
-			return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrInternalServerError.Error(),
-					Message: err.Error(),
-				},
-			})
+			return err
 		}
-		shoppingCartItemDetailData = _shoppingCartItemDetailData
+		if *variantCount > 0 {
+			return apperror.Validation("a variant of the product must be chosen")
+		}
+	}
+
+	shoppingCartItemDetailData, err := m.getShoppingCartItemByProductIDService(c.UserContext(), token.ID, req.ProductID, req.VariantID)
+	if err != nil && !apperror.Is(err, apperror.KIND_NOT_FOUND) {
+		return err
+	}
+
+	amount := *req.Amount
+	if shoppingCartItemDetailData != nil {
+		amount += *shoppingCartItemDetailData.Amount
+	}
+	if stock := productDetailData.StockOf(variantDetailData); stock != nil && amount > *stock {
+		return apperror.InvalidState(fmt.Sprintf("only %d of the product are left in stock", *stock))
+	}
+
+	if shoppingCartItemDetailData == nil {
+		shoppingCartItemDetailData, err = m.addShoppingCartItemService(c.UserContext(), &sc.ShoppingCartItemModel{
+			UserID:    token.ID,
+			ProductID: req.ProductID,
+			VariantID: req.VariantID,
+			Amount:    req.Amount,
+		})
 	} else {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
+		shoppingCartItemDetailData, err = m.updateShoppingCartItemService(c.UserContext(), token.ID, shoppingCartItemDetailData.ID, &sc.ShoppingCartItemModel{
+			Amount: &amount,
 		})
 	}
+	if err != nil {
+		return err
+	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
 		Data: shoppingCartItemDetailData,
 	})
@@ -182,67 +189,35 @@ func (m *Module) addShoppingCartItem(c *fiber.Ctx) error {
 func (m *Module) updateShoppingCartItem(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	param := new(updateShoppingCartItemReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(updateShoppingCartItemReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	var shoppingCartItemDetailData *sc.ShoppingCartItemModel
 	var err error
 	if req.Amount != nil {
 		if *req.Amount > 0 {
-			shoppingCartItemDetailData, err = m.updateShoppingCartItemService(token.ID, param.ID, &sc.ShoppingCartItemModel{
+			shoppingCartItemDetailData, err = m.updateShoppingCartItemService(c.UserContext(), token.ID, param.ID, &sc.ShoppingCartItemModel{
 				Amount: req.Amount,
 			})
 		} else {
-			err = m.deleteShoppingCartItemService(token.ID, param.ID)
+			err = m.deleteShoppingCartItemService(c.UserContext(), token.ID, param.ID)
 		}
 	}
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: func() interface{} {
 			if shoppingCartItemDetailData != nil {
@@ -257,45 +232,19 @@ func (m *Module) updateShoppingCartItem(c *fiber.Ctx) error {
 func (m *Module) deleteShoppingCartItem(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	param := new(deleteShoppingCartItemReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	if err := m.deleteShoppingCartItemService(token.ID, param.ID); err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+	if err := m.deleteShoppingCartItemService(c.UserContext(), token.ID, param.ID); err != nil {
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: param.ID,
 	})
@@ -14,7 +14,10 @@ type ShoppingCartItemModel struct {
 	User      *a.AccountModel `json:"user,omitempty"`
 	ProductID *uuid.UUID      `gorm:"not null" json:"productId,omitempty"`
 	Product   *p.ProductModel `json:"product,omitempty"`
-	Amount    *int            `gorm:"not null" json:"amount,omitempty"`
+	// VariantID is set when the product has variants, and is then the variant that was chosen.
+	VariantID *uuid.UUID             `json:"variantId,omitempty"`
+	Variant   *p.ProductVariantModel `json:"variant,omitempty"`
+	Amount    *int                   `gorm:"not null" json:"amount,omitempty"`
 }
 
 func (ShoppingCartItemModel) TableName() string {
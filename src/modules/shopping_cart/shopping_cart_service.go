@@ -1,31 +1,45 @@
 package shoppingcart
 
 import (
+	"context"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
 	p "hilmy.dev/store/src/modules/product/product_entity"
 	sc "hilmy.dev/store/src/modules/shopping_cart/shopping_cart_entity"
 )
 
 type searchOptions struct {
+	filters  *[]pg.FindAllWhere
 	byUserID *uuid.UUID
 }
 
 type paginationOptions struct {
-	limit  *int
-	offset *int
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
 }
 
 type paginationQuery struct {
 	limit *int
 	count *int
 	total *int
+	next  *string
+	prev  *string
 }
 
-func (*Module) getShoppingCartItemListService(pagination *paginationOptions, search *searchOptions) (*[]*sc.ShoppingCartItemModel, *paginationQuery, error) {
+func (*Module) getShoppingCartItemListService(ctx context.Context, pagination *paginationOptions, search *searchOptions) (*[]*sc.ShoppingCartItemModel, *paginationQuery, error) {
 	where := []pg.FindAllWhere{}
 	limit := 0
 	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
 
 	if search != nil {
 		if search.byUserID != nil {
@@ -37,6 +51,9 @@ func (*Module) getShoppingCartItemListService(pagination *paginationOptions, sea
 				IncludeInCount: true,
 			})
 		}
+		if search.filters != nil {
+			where = append(where, *search.filters...)
+		}
 	}
 
 	if pagination != nil {
@@ -46,13 +63,20 @@ func (*Module) getShoppingCartItemListService(pagination *paginationOptions, sea
 		if pagination.offset != nil && *pagination.offset > 0 {
 			offset = *pagination.offset
 		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
 	}
 
-	data, page, err := sc.ShoppingCartItemRepository().FindAll(&pg.FindAllOptions{
-		Where:  &where,
-		Limit:  &limit,
-		Offset: &offset,
-		Order:  &[]string{"created_at asc"},
+	data, page, err := sc.ShoppingCartItemRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where:       &where,
+		Limit:       &limit,
+		Offset:      &offset,
+		Keyset:      keyset,
+		Order:       order,
+		Cursor:      cursor,
+		IsSkipCount: isSkipCount,
 	})
 	if err != nil {
 		return nil, nil, err
@@ -61,27 +85,48 @@ func (*Module) getShoppingCartItemListService(pagination *paginationOptions, sea
 	return data, &paginationQuery{
 		limit: &page.Limit,
 		count: &page.Count,
-		total: &page.Total,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
 	}, nil
 }
 
-func (*Module) getShoppingCartItemByProductIDService(userID *uuid.UUID, productID *uuid.UUID) (*sc.ShoppingCartItemModel, error) {
-	return sc.ShoppingCartItemRepository().FindOne(&pg.FindOneOptions{
+// getShoppingCartItemByProductIDService finds the item holding the product, or the given variant of it, so that adding
+// it again increases the amount instead of adding another item.
+func (*Module) getShoppingCartItemByProductIDService(ctx context.Context, userID *uuid.UUID, productID *uuid.UUID, variantID *uuid.UUID) (*sc.ShoppingCartItemModel, error) {
+	variantWhere := pg.Where{
+		Query: "variant_id IS NULL",
+	}
+	if variantID != nil {
+		variantWhere = pg.Where{
+			Query: "variant_id = ?",
+			Args:  []interface{}{variantID},
+		}
+	}
+
+	data, err := sc.ShoppingCartItemRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ? AND product_id = ?",
 				Args:  []interface{}{userID, productID},
 			},
+			variantWhere,
 		},
+		IsPrimary: true,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "shopping cart item not found")
+	}
+
+	return data, nil
 }
 
-func (*Module) addShoppingCartItemService(data *sc.ShoppingCartItemModel) (*sc.ShoppingCartItemModel, error) {
-	return sc.ShoppingCartItemRepository().Create(data)
+func (*Module) addShoppingCartItemService(ctx context.Context, data *sc.ShoppingCartItemModel) (*sc.ShoppingCartItemModel, error) {
+	return sc.ShoppingCartItemRepository().WithContext(ctx).Create(data)
 }
 
-func (*Module) updateShoppingCartItemService(userID *uuid.UUID, id *uuid.UUID, data *sc.ShoppingCartItemModel) (*sc.ShoppingCartItemModel, error) {
-	if _, err := sc.ShoppingCartItemRepository().Update(data, &pg.UpdateOptions{
+func (*Module) updateShoppingCartItemService(ctx context.Context, userID *uuid.UUID, id *uuid.UUID, data *sc.ShoppingCartItemModel) (*sc.ShoppingCartItemModel, error) {
+	if _, err := sc.ShoppingCartItemRepository().WithContext(ctx).Update(data, &pg.UpdateOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ? AND id = ?",
@@ -89,26 +134,27 @@ func (*Module) updateShoppingCartItemService(userID *uuid.UUID, id *uuid.UUID, d
 			},
 		},
 	}); err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "shopping cart item not found")
 	}
 
-	data, err := sc.ShoppingCartItemRepository().FindOne(&pg.FindOneOptions{
+	data, err := sc.ShoppingCartItemRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ? AND id = ?",
 				Args:  []interface{}{userID, id},
 			},
 		},
+		IsPrimary: true,
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "shopping cart item not found")
 	}
 
 	return data, nil
 }
 
-func (*Module) deleteShoppingCartItemService(userID *uuid.UUID, id *uuid.UUID) error {
-	return sc.ShoppingCartItemRepository().Destroy(&sc.ShoppingCartItemModel{}, &pg.DestroyOptions{
+func (*Module) deleteShoppingCartItemService(ctx context.Context, userID *uuid.UUID, id *uuid.UUID) error {
+	if err := sc.ShoppingCartItemRepository().WithContext(ctx).Destroy(&sc.ShoppingCartItemModel{}, &pg.DestroyOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ? AND id = ?",
@@ -116,16 +162,55 @@ func (*Module) deleteShoppingCartItemService(userID *uuid.UUID, id *uuid.UUID) e
 			},
 		},
 		IsUnscoped: true,
-	})
+	}); err != nil {
+		return apperror.NotFoundOr(err, "shopping cart item not found")
+	}
+
+	return nil
 }
 
-func (*Module) countProductService(id *uuid.UUID) (*int64, error) {
-	return p.ProductRepository().Count(&pg.CountOptions{
+func (*Module) getProductDetailService(ctx context.Context, id *uuid.UUID) (*p.ProductModel, error) {
+	data, err := p.ProductRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+		IsPrimary: true,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product not found")
+	}
+
+	return data, nil
+}
+
+// countProductVariantService counts the variants of the product.
+func (*Module) countProductVariantService(ctx context.Context, productID *uuid.UUID) (*int64, error) {
+	return p.ProductVariantRepository().WithContext(ctx).Count(&pg.CountOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ?",
+				Args:  []interface{}{productID},
+			},
+		},
+	})
+}
+
+func (*Module) getProductVariantDetailService(ctx context.Context, productID *uuid.UUID, id *uuid.UUID) (*p.ProductVariantModel, error) {
+	data, err := p.ProductVariantRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ? AND id = ?",
+				Args:  []interface{}{productID, id},
+			},
+		},
+		IsPrimary: true,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product variant not found")
+	}
+
+	return data, nil
 }
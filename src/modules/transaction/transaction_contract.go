@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
 	t "hilmy.dev/store/src/modules/transaction/transaction_entity"
 )
 
@@ -9,6 +10,35 @@ type getTransactionListReqQuery struct {
 	SearchByStatus *t.TransactionStatus `query:"status"`
 	Limit          *int                 `query:"limit"`
 	Page           *int                 `query:"page"`
+	Cursor         *string              `query:"cursor"`
+	IncludeTotal   *bool                `query:"include_total"`
+}
+
+// transactionListQueryFields whitelists the fields accepted in filter[...] and sort.
+var transactionListQueryFields = parser.QueryFields{
+	"status": {
+		Column:    "status",
+		Type:      parser.FIELD_TYPE_STRING,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_IN},
+	},
+	"price": {
+		Column:     "price",
+		Type:       parser.FIELD_TYPE_INT,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_NE, parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"created_at": {
+		Column:     "created_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
+	"updated_at": {
+		Column:     "updated_at",
+		Type:       parser.FIELD_TYPE_TIME,
+		Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+		IsSortable: true,
+	},
 }
 
 type getTransactionDetailReqParam struct {
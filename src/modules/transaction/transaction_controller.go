@@ -1,13 +1,14 @@
 package transaction
 
 import (
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/contracts"
-	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/openapi"
 	"hilmy.dev/store/src/libs/parser"
 	acc "hilmy.dev/store/src/modules/account/account_entity"
 	a "hilmy.dev/store/src/modules/auth/auth_entity"
@@ -18,34 +19,60 @@ import (
 )
 
 func (m *Module) controller() {
-	m.App.Get("/api/v1/transactions", am.AuthGuard(acc.ROLE_USER), m.getTransactionList)
-	m.App.Get("/api/v1/transaction/:id", am.AuthGuard(acc.ROLE_USER), m.getTransactionDetail)
-	m.App.Post("/api/v1/transaction", am.AuthGuard(acc.ROLE_USER), m.addTransaction)
-	m.App.Post("/api/v1/transaction/:id/pay", am.AuthGuard(acc.ROLE_USER), m.payTransaction)
-	m.App.Post("/api/v1/transaction/:id/cancel", am.AuthGuard(acc.ROLE_USER), m.cancelTransaction)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/transactions", &openapi.Operation{
+		Summary:   "List transactions",
+		Tags:      []string{"transaction"},
+		Roles:     []string{string(acc.ROLE_USER)},
+		Query:     getTransactionListReqQuery{},
+		ListQuery: transactionListQueryFields,
+		Response:  t.TransactionModel{},
+		IsList:    true,
+	}, am.AuthGuard(acc.ROLE_USER), m.getTransactionList)
+	openapi.Route(m.App, fiber.MethodGet, "/api/v1/transaction/:id", &openapi.Operation{
+		Summary:  "Get a transaction",
+		Tags:     []string{"transaction"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   getTransactionDetailReqParam{},
+		Response: t.TransactionModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.getTransactionDetail)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/transaction", &openapi.Operation{
+		Summary:  "Check out items from the shopping cart",
+		Tags:     []string{"transaction"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Body:     addTransactionReq{},
+		Status:   fiber.StatusCreated,
+		Response: t.TransactionModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.addTransaction)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/transaction/:id/pay", &openapi.Operation{
+		Summary:  "Pay for a transaction from the balance",
+		Tags:     []string{"transaction"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   payTransactionReqParam{},
+		Response: t.TransactionModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.payTransaction)
+	openapi.Route(m.App, fiber.MethodPost, "/api/v1/transaction/:id/cancel", &openapi.Operation{
+		Summary:  "Cancel a transaction",
+		Tags:     []string{"transaction"},
+		Roles:    []string{string(acc.ROLE_USER)},
+		Params:   cancelTransactionReqParam{},
+		Response: t.TransactionModel{},
+	}, am.AuthGuard(acc.ROLE_USER), m.cancelTransaction)
 }
 
 func (m *Module) getTransactionList(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	query := new(getTransactionListReqQuery)
 	if err := parser.ParseReqQuery(c, query); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
+	}
+
+	listQuery, err := parser.ParseReqListQuery(c, transactionListQueryFields, "-created_at")
+	if err != nil {
+		return err
 	}
 
 	offset := 0
@@ -53,30 +80,31 @@ func (m *Module) getTransactionList(c *fiber.Ctx) error {
 		offset = (*query.Page - 1) * *query.Limit
 	}
 
-	transactionDataList, page, err := m.getTransactionListService(&paginationOptions{
-		limit:  query.Limit,
-		offset: &offset,
+	transactionDataList, page, err := m.getTransactionListService(c.UserContext(), &paginationOptions{
+		limit:       query.Limit,
+		keyset:      listQuery.Keyset,
+		order:       listQuery.Order,
+		offset:      &offset,
+		cursor:      query.Cursor,
+		isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
 	}, &searchOptions{
+		filters:             listQuery.Where,
 		byUserID:            token.ID,
 		byTransactionStatus: query.SearchByStatus,
 	})
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Pagination: &contracts.Pagination{
 			Limit: page.limit,
 			Count: page.count,
 			Page:  query.Page,
 			Total: page.total,
+			Next:  page.next,
+			Prev:  page.prev,
 		},
 		Data: transactionDataList,
 	})
@@ -85,46 +113,20 @@ func (m *Module) getTransactionList(c *fiber.Ctx) error {
 func (m *Module) getTransactionDetail(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	param := new(getTransactionDetailReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	transactionDetailData, err := m.getTransactionDetailService(token.ID, param.ID)
+	transactionDetailData, err := m.getTransactionDetailService(c.UserContext(), token.ID, param.ID, false)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: transactionDetailData,
 	})
@@ -133,81 +135,68 @@ func (m *Module) getTransactionDetail(c *fiber.Ctx) error {
 func (m *Module) addTransaction(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	req := new(addTransactionReq)
 	if err := parser.ParseReqBody(c, req); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	transactionPrice := 0
 	shoppingCartItemListData := []*sc.ShoppingCartItemModel{}
 	for i := range *req.ShoppingCartItemIDs {
-		shoppingCartItemDetailData, err := m.getShoppingCartItemDetailService((*req.ShoppingCartItemIDs)[i])
+		shoppingCartItemDetailData, err := m.getShoppingCartItemDetailService(c.UserContext(), (*req.ShoppingCartItemIDs)[i])
 		if err != nil {
-			status := fiber.StatusInternalServerError
-			statusString := fiber.ErrInternalServerError.Error()
-			printStack := true
-			if pg.IsErrRecordNotFound(err) {
-				status = fiber.StatusNotFound
-				statusString = fiber.ErrNotFound.Error()
-				printStack = false
-			}
-			log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-			return c.Status(status).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  statusString,
-					Message: err.Error(),
-				},
-			})
+			return err
 		}
-		productDetailData, err := m.getProductDetailService(shoppingCartItemDetailData.ProductID)
+		productDetailData, err := m.getProductDetailService(c.UserContext(), shoppingCartItemDetailData.ProductID)
 		if err != nil {
-			if pg.IsErrRecordNotFound(err) {
-				if err := m.deleteShoppingCartItemDetailService((*req.ShoppingCartItemIDs)[i]); err != nil {
-					log.SaveLogService(c.OriginalURL(), err.Error(), true)
-					return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-						Error: &contracts.Error{
-							Status:  fiber.ErrInternalServerError.Error(),
-							Message: err.Error(),
-						},
-					})
+			if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+				if err := m.deleteShoppingCartItemDetailService(c.UserContext(), (*req.ShoppingCartItemIDs)[i]); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+		// The item stays in the cart, so that the buyer sees why the checkout failed and can remove it.
+		if !productDetailData.IsLive(time.Now()) {
+			return apperror.InvalidState(fmt.Sprintf("%s is no longer available", *productDetailData.Title))
+		}
+
+		price := *productDetailData.Price
+		if shoppingCartItemDetailData.VariantID != nil {
+			variantDetailData, err := m.getProductVariantDetailService(c.UserContext(), productDetailData.ID, shoppingCartItemDetailData.VariantID)
+			if err != nil {
+				if apperror.Is(err, apperror.KIND_NOT_FOUND) {
+					if err := m.deleteShoppingCartItemDetailService(c.UserContext(), (*req.ShoppingCartItemIDs)[i]); err != nil {
+						return err
+					}
 				}
+				return err
+			}
+			if variantDetailData.Price != nil {
+				price = *variantDetailData.Price
 			}
-			log.SaveLogService(c.OriginalURL(), err.Error(), true)
-			return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-				Error: &contracts.Error{
-					Status:  fiber.ErrInternalServerError.Error(),
-					Message: err.Error(),
-				},
-			})
+			shoppingCartItemDetailData.Variant = variantDetailData
+		} else if len(productDetailData.Options) > 0 {
+			return apperror.Validation(fmt.Sprintf("a variant of %s must be chosen", *productDetailData.Title))
 		}
-		transactionPrice += *shoppingCartItemDetailData.Amount * *productDetailData.Price
+
+		if stock := productDetailData.StockOf(shoppingCartItemDetailData.Variant); stock != nil && *shoppingCartItemDetailData.Amount > *stock {
+			return apperror.InvalidState(fmt.Sprintf("only %d of %s are left in stock", *stock, *productDetailData.Title))
+		}
+
+		transactionPrice += *shoppingCartItemDetailData.Amount * price
+		// The product and the variant are kept as they are at checkout, so that the transaction still shows what was
+		// bought, and at which price, after the catalogue changes.
+		shoppingCartItemDetailData.Product = productDetailData
 		shoppingCartItemListData = append(shoppingCartItemListData, shoppingCartItemDetailData)
 	}
 
 	dataBytes, err := sonic.Marshal(shoppingCartItemListData)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	transactionStatus := t.STATUS_WAITING_PAYMENT
@@ -217,17 +206,12 @@ func (m *Module) addTransaction(c *fiber.Ctx) error {
 		Price:  &transactionPrice,
 		Data:   dataBytes,
 	}
-	if err := m.addTransactionService(&transactionDetailData, req.ShoppingCartItemIDs); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+	if err := m.addTransactionService(c.UserContext(), &transactionDetailData, req.ShoppingCartItemIDs); err != nil {
+		return err
 	}
+	transactionsTotal.WithLabelValues("created").Inc()
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusCreated).JSON(&contracts.Response{
 		Data: &transactionDetailData,
 	})
@@ -236,99 +220,45 @@ func (m *Module) addTransaction(c *fiber.Ctx) error {
 func (m *Module) payTransaction(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	param := new(payTransactionReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	transactionDetailData, err := m.getTransactionDetailService(token.ID, param.ID)
+	transactionDetailData, err := m.getTransactionDetailService(c.UserContext(), token.ID, param.ID, true)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 	if *transactionDetailData.Status != t.STATUS_WAITING_PAYMENT {
-		err := fmt.Errorf("cannot pay for a transaction that is not in %s status", t.STATUS_WAITING_PAYMENT)
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return apperror.InvalidState(fmt.Sprintf("cannot pay for a transaction that is not in %s status", t.STATUS_WAITING_PAYMENT))
 	}
 
-	balanceDetailData, err := m.getBalanceByUserIDService(token.ID)
+	// The balance is read for a clear error when there is none, while the payment itself checks the amount again.
+	balanceDetailData, err := m.getBalanceByUserIDService(c.UserContext(), token.ID)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
-
 	if *balanceDetailData.Amount < *transactionDetailData.Price {
-		err := errors.New("insufficient balance")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return apperror.InsufficientFunds("insufficient balance")
 	}
 
+	// The stock is only taken once the transaction is paid, from the items as they were at checkout.
+	shoppingCartItemListData := []*sc.ShoppingCartItemModel{}
+	if err := sonic.Unmarshal(transactionDetailData.Data, &shoppingCartItemListData); err != nil {
+		return err
+	}
+
+	if err := m.payTransactionService(c.UserContext(), token.ID, transactionDetailData.ID, *transactionDetailData.Price, shoppingCartItemListData); err != nil {
+		return err
+	}
 	transactionStatus := t.STATUS_COMPLETED
 	transactionDetailData.Status = &transactionStatus
-	*balanceDetailData.Amount -= *transactionDetailData.Price
-	if err := m.payTransactionService(token.ID, transactionDetailData.ID, transactionDetailData, balanceDetailData.ID, balanceDetailData); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
-	}
+	transactionsTotal.WithLabelValues("paid").Inc()
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: transactionDetailData,
 	})
@@ -337,68 +267,30 @@ func (m *Module) payTransaction(c *fiber.Ctx) error {
 func (m *Module) cancelTransaction(c *fiber.Ctx) error {
 	token := new(a.JWTPayload)
 	if err := parser.ParseReqBearerToken(c, token); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusUnauthorized).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrUnauthorized.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	param := new(cancelTransactionReqParam)
 	if err := parser.ParseReqParam(c, param); err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
-	transactionDetailData, err := m.getTransactionDetailService(token.ID, param.ID)
+	transactionDetailData, err := m.getTransactionDetailService(c.UserContext(), token.ID, param.ID, true)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		statusString := fiber.ErrInternalServerError.Error()
-		printStack := true
-		if pg.IsErrRecordNotFound(err) {
-			status = fiber.StatusNotFound
-			statusString = fiber.ErrNotFound.Error()
-			printStack = false
-		}
-		log.SaveLogService(c.OriginalURL(), err.Error(), printStack)
-		return c.Status(status).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  statusString,
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
 
 	if *transactionDetailData.Status == t.STATUS_COMPLETED {
-		err := errors.New("cannot cancel a transaction that has already been completed")
-		log.SaveLogService(c.OriginalURL(), err.Error(), false)
-		return c.Status(fiber.StatusBadRequest).JSON(contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrBadRequest.Error(),
-				Message: err.Error(),
-			},
-		})
+		return apperror.InvalidState("cannot cancel a transaction that has already been completed")
 	}
 
-	transactionDetailData, err = m.cancelTransactionService(token.ID, param.ID)
+	transactionDetailData, err = m.cancelTransactionService(c.UserContext(), token.ID, param.ID)
 	if err != nil {
-		log.SaveLogService(c.OriginalURL(), err.Error(), true)
-		return c.Status(fiber.StatusInternalServerError).JSON(&contracts.Response{
-			Error: &contracts.Error{
-				Status:  fiber.ErrInternalServerError.Error(),
-				Message: err.Error(),
-			},
-		})
+		return err
 	}
+	transactionsTotal.WithLabelValues("cancelled").Inc()
 
-	log.SaveLogService(c.OriginalURL(), "Ok", false)
+	log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
 	return c.Status(fiber.StatusOK).JSON(&contracts.Response{
 		Data: transactionDetailData,
 	})
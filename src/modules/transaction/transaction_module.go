@@ -3,6 +3,7 @@ package transaction
 import (
 	"github.com/gofiber/fiber/v2"
 	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/metrics"
 	transactionentity "hilmy.dev/store/src/modules/transaction/transaction_entity"
 )
 
@@ -11,6 +12,12 @@ type Module struct {
 	DB  *pg.DB
 }
 
+var transactionsTotal = metrics.NewCounterVec(
+	"transactions_total",
+	"Total number of transactions by event (created, paid, cancelled).",
+	"event",
+)
+
 func Load(module *Module) {
 	transactionentity.InitRepository(module.DB)
 	module.controller()
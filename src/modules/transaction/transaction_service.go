@@ -1,7 +1,12 @@
 package transaction
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
 	"hilmy.dev/store/src/libs/db/pg"
 	b "hilmy.dev/store/src/modules/balance/balance_entity"
 	p "hilmy.dev/store/src/modules/product/product_entity"
@@ -10,25 +15,36 @@ import (
 )
 
 type searchOptions struct {
+	filters             *[]pg.FindAllWhere
 	byUserID            *uuid.UUID
 	byTransactionStatus *t.TransactionStatus
 }
 
 type paginationOptions struct {
-	limit  *int
-	offset *int
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
 }
 
 type paginationQuery struct {
 	limit *int
 	count *int
 	total *int
+	next  *string
+	prev  *string
 }
 
-func (*Module) getTransactionListService(pagination *paginationOptions, search *searchOptions) (*[]*t.TransactionModel, *paginationQuery, error) {
+func (*Module) getTransactionListService(ctx context.Context, pagination *paginationOptions, search *searchOptions) (*[]*t.TransactionModel, *paginationQuery, error) {
 	where := []pg.FindAllWhere{}
 	limit := 0
 	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
 
 	if search != nil {
 		if search.byUserID != nil && len(*search.byUserID) > 0 {
@@ -49,6 +65,9 @@ func (*Module) getTransactionListService(pagination *paginationOptions, search *
 				IncludeInCount: true,
 			})
 		}
+		if search.filters != nil {
+			where = append(where, *search.filters...)
+		}
 	}
 
 	if pagination != nil {
@@ -58,13 +77,20 @@ func (*Module) getTransactionListService(pagination *paginationOptions, search *
 		if pagination.offset != nil && *pagination.offset > 0 {
 			offset = *pagination.offset
 		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
 	}
 
-	data, page, err := t.TransactionRepository().FindAll(&pg.FindAllOptions{
-		Where:  &where,
-		Limit:  &limit,
-		Offset: &offset,
-		Order:  &[]string{"created_at desc"},
+	data, page, err := t.TransactionRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where:       &where,
+		Limit:       &limit,
+		Offset:      &offset,
+		Keyset:      keyset,
+		Order:       order,
+		Cursor:      cursor,
+		IsSkipCount: isSkipCount,
 	})
 	if err != nil {
 		return nil, nil, err
@@ -73,23 +99,33 @@ func (*Module) getTransactionListService(pagination *paginationOptions, search *
 	return data, &paginationQuery{
 		limit: &page.Limit,
 		count: &page.Count,
-		total: &page.Total,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
 	}, nil
 }
 
-func (*Module) getTransactionDetailService(userID *uuid.UUID, id *uuid.UUID) (*t.TransactionModel, error) {
-	return t.TransactionRepository().FindOne(&pg.FindOneOptions{
+// getTransactionDetailService reads from the primary when isPrimary is set, for when the transaction is paid or
+// cancelled based on it.
+func (*Module) getTransactionDetailService(ctx context.Context, userID *uuid.UUID, id *uuid.UUID, isPrimary bool) (*t.TransactionModel, error) {
+	data, err := t.TransactionRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ? AND id = ?",
 				Args:  []interface{}{userID, id},
 			},
 		},
+		IsPrimary: isPrimary,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "transaction not found")
+	}
+
+	return data, nil
 }
 
-func (m *Module) addTransactionService(data *t.TransactionModel, shoppingCartItemIDs *[]*uuid.UUID) error {
-	return pg.Transaction(m.DB, func(tx *pg.DB) *pg.DB {
+func (m *Module) addTransactionService(ctx context.Context, data *t.TransactionModel, shoppingCartItemIDs *[]*uuid.UUID) error {
+	return pg.Transaction(m.DB.WithContext(ctx), func(tx *pg.DB) *pg.DB {
 		txz := t.TransactionRepository().CreateTx(tx, data)
 		return txz
 	}, func(tx *pg.DB) *pg.DB {
@@ -106,31 +142,122 @@ func (m *Module) addTransactionService(data *t.TransactionModel, shoppingCartIte
 	})
 }
 
-func (m *Module) payTransactionService(userID *uuid.UUID, tID *uuid.UUID, tData *t.TransactionModel, bID *uuid.UUID, bData *b.BalanceModel) error {
-	return pg.Transaction(m.DB, func(tx *pg.DB) *pg.DB {
-		return t.TransactionRepository().UpdateTx(tx, tData, &pg.UpdateOptions{
-			Where: &[]pg.Where{
-				{
-					Query: "user_id = ? AND id = ?",
-					Args:  []interface{}{userID, tID},
+// outOfStockError is returned when the stock of an item ran out between the checkout and the payment.
+type outOfStockError struct {
+	title string
+}
+
+func (e *outOfStockError) Error() string {
+	return e.title + " is out of stock"
+}
+
+var (
+	errTransactionNotWaiting = errors.New("transaction was paid or cancelled concurrently")
+	errBalanceTooLow         = errors.New("balance is lower than the price")
+)
+
+// payTransactionService completes the transaction, takes its price off the balance and the bought amounts off the
+// stock of items, all in one transaction. Every update is made in place and guarded, so that it fails rather than
+// paying twice, overdrawing the balance or overwriting a concurrent change to it, or selling more than is in stock.
+func (m *Module) payTransactionService(ctx context.Context, userID *uuid.UUID, id *uuid.UUID, price int, items []*sc.ShoppingCartItemModel) error {
+	txs := []func(tx *pg.DB) *pg.DB{
+		func(tx *pg.DB) *pg.DB {
+			transactionStatus := t.STATUS_COMPLETED
+			result := t.TransactionRepository().UpdateTx(tx, &t.TransactionModel{
+				Status: &transactionStatus,
+			}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "user_id = ? AND id = ?",
+						Args:  []interface{}{userID, id},
+					},
+					{
+						Query: "status = ?",
+						Args:  []interface{}{t.STATUS_WAITING_PAYMENT},
+					},
 				},
-			},
-		})
-	}, func(tx *pg.DB) *pg.DB {
-		return b.BalanceRepository().UpdateTx(tx, bData, &pg.UpdateOptions{
-			Where: &[]pg.Where{
-				{
-					Query: "id = ?",
-					Args:  []interface{}{bID},
+			})
+			if result.Error == nil && result.RowsAffected == 0 {
+				result.AddError(errTransactionNotWaiting)
+			}
+			return result
+		},
+		func(tx *pg.DB) *pg.DB {
+			result := b.BalanceRepository().UpdateExprTx(tx, map[string]pg.Where{
+				"amount": {
+					Query: "amount - ?",
+					Args:  []interface{}{price},
 				},
-			},
+			}, &pg.UpdateOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "user_id = ?",
+						Args:  []interface{}{userID},
+					},
+					{
+						Query: "amount >= ?",
+						Args:  []interface{}{price},
+					},
+				},
+			})
+			if result.Error == nil && result.RowsAffected == 0 {
+				result.AddError(errBalanceTooLow)
+			}
+			return result
+		},
+	}
+	for _, item := range items {
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			exprs, stockWhere := p.TakeStockExprs(*item.Amount)
+			var result *pg.DB
+			if item.VariantID != nil {
+				result = p.ProductVariantRepository().UpdateExprTx(tx, exprs, &pg.UpdateOptions{
+					Where: &[]pg.Where{
+						{
+							Query: "id = ?",
+							Args:  []interface{}{item.VariantID},
+						},
+						stockWhere,
+					},
+				})
+			} else {
+				result = p.ProductRepository().UpdateExprTx(tx, exprs, &pg.UpdateOptions{
+					Where: &[]pg.Where{
+						{
+							Query: "id = ?",
+							Args:  []interface{}{item.ProductID},
+						},
+						stockWhere,
+					},
+				})
+			}
+			if result.Error == nil && result.RowsAffected == 0 {
+				result.AddError(&outOfStockError{title: *item.Product.Title})
+			}
+			return result
 		})
-	})
+	}
+
+	if err := pg.Transaction(m.DB.WithContext(ctx), txs...); err != nil {
+		if errors.Is(err, errTransactionNotWaiting) {
+			return apperror.InvalidState(fmt.Sprintf("cannot pay for a transaction that is not in %s status", t.STATUS_WAITING_PAYMENT))
+		}
+		if errors.Is(err, errBalanceTooLow) {
+			return apperror.InsufficientFunds("insufficient balance")
+		}
+		var stockError *outOfStockError
+		if errors.As(err, &stockError) {
+			return apperror.InvalidState(stockError.Error())
+		}
+		return err
+	}
+
+	return nil
 }
 
-func (*Module) cancelTransactionService(userID *uuid.UUID, id *uuid.UUID) (*t.TransactionModel, error) {
+func (*Module) cancelTransactionService(ctx context.Context, userID *uuid.UUID, id *uuid.UUID) (*t.TransactionModel, error) {
 	updateTransactionStatus := t.STATUS_CANCELLED
-	data, err := t.TransactionRepository().Update(&t.TransactionModel{
+	data, err := t.TransactionRepository().WithContext(ctx).Update(&t.TransactionModel{
 		Status: &updateTransactionStatus,
 	}, &pg.UpdateOptions{
 		Where: &[]pg.Where{
@@ -141,50 +268,89 @@ func (*Module) cancelTransactionService(userID *uuid.UUID, id *uuid.UUID) (*t.Tr
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperror.NotFoundOr(err, "transaction not found")
 	}
 
 	data.ID = id
 	return data, nil
 }
 
-func (*Module) getShoppingCartItemDetailService(id *uuid.UUID) (*sc.ShoppingCartItemModel, error) {
-	return sc.ShoppingCartItemRepository().FindOne(&pg.FindOneOptions{
+func (*Module) getShoppingCartItemDetailService(ctx context.Context, id *uuid.UUID) (*sc.ShoppingCartItemModel, error) {
+	data, err := sc.ShoppingCartItemRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+		IsPrimary: true,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "shopping cart item not found")
+	}
+
+	return data, nil
 }
 
-func (*Module) deleteShoppingCartItemDetailService(id *uuid.UUID) error {
-	return sc.ShoppingCartItemRepository().Destroy(&sc.ShoppingCartItemModel{
+func (*Module) deleteShoppingCartItemDetailService(ctx context.Context, id *uuid.UUID) error {
+	if err := sc.ShoppingCartItemRepository().WithContext(ctx).Destroy(&sc.ShoppingCartItemModel{
 		Model: pg.Model{
 			ID: id,
 		},
-	})
+	}); err != nil {
+		return apperror.NotFoundOr(err, "shopping cart item not found")
+	}
+
+	return nil
 }
 
-func (*Module) getProductDetailService(id *uuid.UUID) (*p.ProductModel, error) {
-	return p.ProductRepository().FindOne(&pg.FindOneOptions{
+func (*Module) getProductDetailService(ctx context.Context, id *uuid.UUID) (*p.ProductModel, error) {
+	data, err := p.ProductRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "id = ?",
 				Args:  []interface{}{id},
 			},
 		},
+		IsPrimary: true,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product not found")
+	}
+
+	return data, nil
+}
+
+func (*Module) getProductVariantDetailService(ctx context.Context, productID *uuid.UUID, id *uuid.UUID) (*p.ProductVariantModel, error) {
+	data, err := p.ProductVariantRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "product_id = ? AND id = ?",
+				Args:  []interface{}{productID, id},
+			},
+		},
+		IsPrimary: true,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "product variant not found")
+	}
+
+	return data, nil
 }
 
-func (*Module) getBalanceByUserIDService(userID *uuid.UUID) (*b.BalanceModel, error) {
-	return b.BalanceRepository().FindOne(&pg.FindOneOptions{
+func (*Module) getBalanceByUserIDService(ctx context.Context, userID *uuid.UUID) (*b.BalanceModel, error) {
+	data, err := b.BalanceRepository().WithContext(ctx).FindOne(&pg.FindOneOptions{
 		Where: &[]pg.Where{
 			{
 				Query: "user_id = ?",
 				Args:  []interface{}{userID},
 			},
 		},
+		IsPrimary: true,
 	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, "balance not found")
+	}
+
+	return data, nil
 }
@@ -0,0 +1,66 @@
+package trash
+
+import (
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/parser"
+)
+
+type getTrashListReqQuery struct {
+	Limit        *int    `query:"limit"`
+	Page         *int    `query:"page"`
+	Cursor       *string `query:"cursor"`
+	IncludeTotal *bool   `query:"include_total"`
+}
+
+type restoreTrashReqParam struct {
+	ID *uuid.UUID `params:"id" validate:"required"`
+}
+
+var deletedAtQueryField = parser.QueryField{
+	Column:     "deleted_at",
+	Type:       parser.FIELD_TYPE_TIME,
+	Operators:  []parser.Operator{parser.OPERATOR_GT, parser.OPERATOR_GTE, parser.OPERATOR_LT, parser.OPERATOR_LTE},
+	IsSortable: true,
+}
+
+// The query fields whitelist the fields accepted in filter[...] and sort for each resource in the trash.
+var deletedProductListQueryFields = parser.QueryFields{
+	"title": {
+		Column:     "title",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"category_id": {
+		Column:    "category_id",
+		Type:      parser.FIELD_TYPE_UUID,
+		Operators: []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_IN},
+	},
+	"deleted_at": deletedAtQueryField,
+}
+
+var deletedProductCategoryListQueryFields = parser.QueryFields{
+	"name": {
+		Column:     "name",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"deleted_at": deletedAtQueryField,
+}
+
+var deletedAccountListQueryFields = parser.QueryFields{
+	"name": {
+		Column:     "name",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"username": {
+		Column:     "username",
+		Type:       parser.FIELD_TYPE_STRING,
+		Operators:  []parser.Operator{parser.OPERATOR_EQ, parser.OPERATOR_CONTAINS},
+		IsSortable: true,
+	},
+	"deleted_at": deletedAtQueryField,
+}
@@ -0,0 +1,105 @@
+package trash
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/contracts"
+	"hilmy.dev/store/src/libs/openapi"
+	"hilmy.dev/store/src/libs/parser"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	am "hilmy.dev/store/src/modules/auth/auth_middleware"
+	"hilmy.dev/store/src/modules/log"
+)
+
+func (m *Module) controller() {
+	for _, route := range m.trashRoutes() {
+		listPath := "/api/v1/admin/trash/" + route.path
+		m.addTrashRoutes(route, listPath, listPath+"/:id/restore")
+
+		// Deleted categories were listed and restored by the category routes before the trash existed.
+		if route.path == "product-categories" {
+			m.addTrashRoutes(route, "/api/v1/product-categories/deleted", "/api/v1/product-category/:id/restore")
+		}
+	}
+}
+
+func (m *Module) addTrashRoutes(route *trashRoute, listPath string, restorePath string) {
+	openapi.Route(m.App, fiber.MethodGet, listPath, &openapi.Operation{
+		Summary:   "List deleted " + route.plural,
+		Tags:      []string{"trash"},
+		Roles:     []string{string(acc.ROLE_ADMIN)},
+		Query:     getTrashListReqQuery{},
+		ListQuery: route.queryFields,
+		Response:  route.response,
+		IsList:    true,
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.getTrashList(route))
+	openapi.Route(m.App, fiber.MethodPost, restorePath, &openapi.Operation{
+		Summary:  "Restore a deleted " + route.name,
+		Tags:     []string{"trash"},
+		Roles:    []string{string(acc.ROLE_ADMIN)},
+		Params:   restoreTrashReqParam{},
+		Response: route.response,
+	}, am.AuthGuard(acc.ROLE_ADMIN), m.restoreTrash(route))
+}
+
+func (m *Module) getTrashList(route *trashRoute) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := new(getTrashListReqQuery)
+		if err := parser.ParseReqQuery(c, query); err != nil {
+			return err
+		}
+
+		listQuery, err := parser.ParseReqListQuery(c, route.queryFields, "-deleted_at")
+		if err != nil {
+			return err
+		}
+
+		offset := 0
+		if query.Page != nil && query.Limit != nil && *query.Page > 0 && *query.Limit > 0 {
+			offset = (*query.Page - 1) * *query.Limit
+		}
+
+		trashListData, page, err := route.resource.getDeletedList(c.UserContext(), &paginationOptions{
+			limit:       query.Limit,
+			keyset:      listQuery.Keyset,
+			order:       listQuery.Order,
+			offset:      &offset,
+			cursor:      query.Cursor,
+			isSkipCount: query.IncludeTotal != nil && !*query.IncludeTotal,
+		}, listQuery.Where)
+		if err != nil {
+			return err
+		}
+
+		log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+		return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+			Pagination: &contracts.Pagination{
+				Limit: page.limit,
+				Count: page.count,
+				Page:  query.Page,
+				Total: page.total,
+				Next:  page.next,
+				Prev:  page.prev,
+			},
+			Data: trashListData,
+		})
+	}
+}
+
+func (m *Module) restoreTrash(route *trashRoute) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		param := new(restoreTrashReqParam)
+		if err := parser.ParseReqParam(c, param); err != nil {
+			return err
+		}
+
+		trashDetailData, err := route.resource.restore(c.UserContext(), param.ID)
+		if err != nil {
+			return err
+		}
+
+		log.SaveLogService(c.UserContext(), c.OriginalURL(), "Ok", false)
+		return c.Status(fiber.StatusOK).JSON(&contracts.Response{
+			Data: trashDetailData,
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package trash
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/gracefulshutdown"
+	applogger "hilmy.dev/store/src/libs/logger"
+	"hilmy.dev/store/src/libs/storage"
+)
+
+var logger = applogger.New("TrashModule")
+
+// purgeLockKey is an arbitrary application-wide key for pg_try_advisory_lock, so that only one instance of the app
+// purges the trash at a time.
+const purgeLockKey int64 = 4_810_274_553
+
+type Module struct {
+	App     *fiber.App
+	DB      *pg.DB
+	Storage storage.Storage
+	// Retention is how long deleted rows stay in the trash before the purge job deletes them for good, which it does
+	// every PurgeInterval. Nothing is purged when Retention is 0.
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// Load expects the repositories of the resources in the trash to be initialized by their own modules.
+func Load(module *Module) {
+	module.controller()
+	if module.Retention > 0 {
+		module.startPurgeJob()
+	}
+}
+
+// startPurgeJob purges the trash right away and then every PurgeInterval, until shutdown begins. Every instance runs
+// the job, and a run is skipped when another instance is already purging.
+func (m *Module) startPurgeJob() {
+	go func() {
+		ctx := gracefulshutdown.Context()
+		ticker := time.NewTicker(m.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := pg.WithTryLock(ctx, m.DB, purgeLockKey, func() {
+				m.purgeTrashService(ctx, time.Now().Add(-m.Retention))
+			}); err != nil {
+				logger.Error(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,511 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/apperror"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/parser"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	b "hilmy.dev/store/src/modules/balance/balance_entity"
+	p "hilmy.dev/store/src/modules/product/product_entity"
+	pc "hilmy.dev/store/src/modules/product_category/product_category_entity"
+	pr "hilmy.dev/store/src/modules/product_review/product_review_entity"
+	sc "hilmy.dev/store/src/modules/shopping_cart/shopping_cart_entity"
+)
+
+type paginationOptions struct {
+	keyset      *pg.Keyset
+	order       *[]string
+	limit       *int
+	offset      *int
+	cursor      *string
+	isSkipCount bool
+}
+
+type paginationQuery struct {
+	limit *int
+	count *int
+	total *int
+	next  *string
+	prev  *string
+}
+
+// resource is a table whose soft-deleted rows admins can list and restore, and that the purge job clears once they
+// have been deleted for longer than the retention period.
+type resource interface {
+	getDeletedList(ctx context.Context, pagination *paginationOptions, filters *[]pg.FindAllWhere) (interface{}, *paginationQuery, error)
+	restore(ctx context.Context, id *uuid.UUID) (interface{}, error)
+	purge(ctx context.Context, db *pg.DB, deletedBefore time.Time) (int, error)
+}
+
+type trashModel interface {
+	pg.ModelI
+	GetID() *uuid.UUID
+}
+
+type trashResource[T trashModel] struct {
+	// name is the resource in messages, e.g. "product category".
+	name       string
+	repository func() *pg.Service[T]
+	// checkRestore rejects restoring a row that refers to rows that are still deleted.
+	checkRestore func(ctx context.Context, data *T) error
+	// purgeDependents returns the deletions of the rows that refer to the purged row, which run in the same
+	// transaction, and a cleanup to run once the transaction is committed.
+	purgeDependents func(ctx context.Context, id *uuid.UUID) ([]func(tx *pg.DB) *pg.DB, func(), error)
+	// purgeWhere leaves out the rows that are kept for good, so that the purge does not try them on every run.
+	purgeWhere *pg.Where
+}
+
+// trashRoute exposes a resource under /api/v1/admin/trash/<path>.
+type trashRoute struct {
+	path string
+	// name and plural name the resource in the summaries of its operations.
+	name        string
+	plural      string
+	resource    resource
+	queryFields parser.QueryFields
+	response    interface{}
+}
+
+// trashRoutes lists the resources in the order they are purged, so that the rows referring to others go first.
+func (m *Module) trashRoutes() []*trashRoute {
+	return []*trashRoute{
+		{
+			path:        "products",
+			name:        "product",
+			plural:      "products",
+			resource:    m.productResource(),
+			queryFields: deletedProductListQueryFields,
+			response:    p.ProductModel{},
+		},
+		{
+			path:        "accounts",
+			name:        "account",
+			plural:      "accounts",
+			resource:    accountResource(),
+			queryFields: deletedAccountListQueryFields,
+			response:    acc.AccountModel{},
+		},
+		{
+			path:        "product-categories",
+			name:        "product category",
+			plural:      "product categories",
+			resource:    productCategoryResource(),
+			queryFields: deletedProductCategoryListQueryFields,
+			response:    pc.ProductCategoryModel{},
+		},
+	}
+}
+
+func (m *Module) productResource() *trashResource[p.ProductModel] {
+	return &trashResource[p.ProductModel]{
+		name:       "product",
+		repository: p.ProductRepository,
+		checkRestore: func(ctx context.Context, data *p.ProductModel) error {
+			categoryCount, err := pc.ProductCategoryRepository().WithContext(ctx).Count(&pg.CountOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{data.CategoryID},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if *categoryCount == 0 {
+				return apperror.InvalidState("the category of the product is deleted, restore it first")
+			}
+			return nil
+		},
+		purgeDependents: func(ctx context.Context, id *uuid.UUID) ([]func(tx *pg.DB) *pg.DB, func(), error) {
+			limit := pg.FindAllMaximumLimit
+			images, _, err := p.ProductImageRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+				Where: &[]pg.FindAllWhere{
+					{
+						Where: pg.Where{
+							Query: "product_id = ?",
+							Args:  []interface{}{id},
+						},
+						IncludeInCount: true,
+					},
+				},
+				Limit:       &limit,
+				IsUnscoped:  true,
+				IsSkipCount: true,
+				IsPrimary:   true,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			reviewImages, err := findProductReviewImages(ctx, id)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			byProduct := &pg.DestroyOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "product_id = ?",
+						Args:  []interface{}{id},
+					},
+				},
+				IsUnscoped: true,
+			}
+			txs := []func(tx *pg.DB) *pg.DB{
+				func(tx *pg.DB) *pg.DB {
+					return sc.ShoppingCartItemRepository().DestroyTx(tx, &sc.ShoppingCartItemModel{}, byProduct)
+				},
+				func(tx *pg.DB) *pg.DB {
+					return p.ProductVariantRepository().DestroyTx(tx, &p.ProductVariantModel{}, byProduct)
+				},
+				func(tx *pg.DB) *pg.DB {
+					return p.ProductImageRepository().DestroyTx(tx, &p.ProductImageModel{}, byProduct)
+				},
+				func(tx *pg.DB) *pg.DB {
+					return pr.ProductReviewImageRepository().DestroyTx(tx, &pr.ProductReviewImageModel{}, &pg.DestroyOptions{
+						Where: &[]pg.Where{
+							{
+								Query: "review_id IN (SELECT id FROM product_reviews WHERE product_id = ?)",
+								Args:  []interface{}{id},
+							},
+						},
+						IsUnscoped: true,
+					})
+				},
+				func(tx *pg.DB) *pg.DB {
+					return pr.ProductReviewRepository().DestroyTx(tx, &pr.ProductReviewModel{}, byProduct)
+				},
+			}
+
+			// The files go once their records are gone, and a file that cannot be removed is only logged.
+			cleanup := func() {
+				for _, image := range *images {
+					for _, key := range []*string{image.OriginalKey, image.ThumbnailKey, image.MediumKey} {
+						m.Storage.Delete(context.WithoutCancel(ctx), *key)
+					}
+				}
+				for _, image := range reviewImages {
+					for _, key := range []*string{image.OriginalKey, image.ThumbnailKey, image.MediumKey} {
+						m.Storage.Delete(context.WithoutCancel(ctx), *key)
+					}
+				}
+			}
+
+			return txs, cleanup, nil
+		},
+	}
+}
+
+// findProductReviewImages pages through the images of every review of the product, of which there can be more than
+// one page.
+func findProductReviewImages(ctx context.Context, productID *uuid.UUID) ([]*pr.ProductReviewImageModel, error) {
+	images := []*pr.ProductReviewImageModel{}
+	limit := pg.FindAllMaximumLimit
+	var afterID *uuid.UUID
+	for {
+		where := []pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "review_id IN (SELECT id FROM product_reviews WHERE product_id = ?)",
+					Args:  []interface{}{productID},
+				},
+				IncludeInCount: true,
+			},
+		}
+		if afterID != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "id > ?",
+					Args:  []interface{}{afterID},
+				},
+				IncludeInCount: true,
+			})
+		}
+
+		page, _, err := pr.ProductReviewImageRepository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where:       &where,
+			Order:       &[]string{"id"},
+			Limit:       &limit,
+			IsUnscoped:  true,
+			IsSkipCount: true,
+			IsPrimary:   true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, *page...)
+		if len(*page) < limit {
+			return images, nil
+		}
+		afterID = (*page)[len(*page)-1].ID
+	}
+}
+
+func productCategoryResource() *trashResource[pc.ProductCategoryModel] {
+	return &trashResource[pc.ProductCategoryModel]{
+		name:       "product category",
+		repository: pc.ProductCategoryRepository,
+		// The subcategories and products of a restored category are left as they are.
+		checkRestore: func(ctx context.Context, data *pc.ProductCategoryModel) error {
+			if data.ParentID == nil {
+				return nil
+			}
+			parentCount, err := pc.ProductCategoryRepository().WithContext(ctx).Count(&pg.CountOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "id = ?",
+						Args:  []interface{}{data.ParentID},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if *parentCount == 0 {
+				return apperror.InvalidState("the parent of the category is deleted, restore it first")
+			}
+			return nil
+		},
+	}
+}
+
+// accountResource purges the cart and the balance along with an account. Accounts with transactions are kept, since
+// the transactions are the record of what was sold, and so are accounts with reviews, which only buyers can write.
+func accountResource() *trashResource[acc.AccountModel] {
+	return &trashResource[acc.AccountModel]{
+		name:       "account",
+		repository: acc.AccountRepository,
+		purgeWhere: &pg.Where{
+			Query: "NOT EXISTS (SELECT 1 FROM transactions WHERE transactions.user_id = account.id) " +
+				"AND NOT EXISTS (SELECT 1 FROM product_reviews WHERE product_reviews.user_id = account.id)",
+		},
+		purgeDependents: func(ctx context.Context, id *uuid.UUID) ([]func(tx *pg.DB) *pg.DB, func(), error) {
+			byUser := &pg.DestroyOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "user_id = ?",
+						Args:  []interface{}{id},
+					},
+				},
+				IsUnscoped: true,
+			}
+			return []func(tx *pg.DB) *pg.DB{
+				func(tx *pg.DB) *pg.DB {
+					return sc.ShoppingCartItemRepository().DestroyTx(tx, &sc.ShoppingCartItemModel{}, byUser)
+				},
+				func(tx *pg.DB) *pg.DB {
+					return b.BalanceRepository().DestroyTx(tx, &b.BalanceModel{}, byUser)
+				},
+			}, nil, nil
+		},
+	}
+}
+
+func (r *trashResource[T]) getDeletedList(ctx context.Context, pagination *paginationOptions, filters *[]pg.FindAllWhere) (interface{}, *paginationQuery, error) {
+	where := []pg.FindAllWhere{
+		{
+			Where: pg.Where{
+				Query: "deleted_at IS NOT NULL",
+			},
+			IncludeInCount: true,
+		},
+	}
+	limit := 0
+	offset := 0
+	var keyset *pg.Keyset
+	var order *[]string
+	var cursor *string
+	isSkipCount := false
+
+	if filters != nil {
+		where = append(where, *filters...)
+	}
+
+	if pagination != nil {
+		if pagination.limit != nil && *pagination.limit > 0 {
+			limit = *pagination.limit
+		}
+		if pagination.offset != nil && *pagination.offset > 0 {
+			offset = *pagination.offset
+		}
+		keyset = pagination.keyset
+		order = pagination.order
+		cursor = pagination.cursor
+		isSkipCount = pagination.isSkipCount
+	}
+
+	data, page, err := r.repository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+		Where:       &where,
+		Limit:       &limit,
+		Offset:      &offset,
+		Keyset:      keyset,
+		Order:       order,
+		Cursor:      cursor,
+		IsUnscoped:  true,
+		IsSkipCount: isSkipCount,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, &paginationQuery{
+		limit: &page.Limit,
+		count: &page.Count,
+		total: page.Total,
+		next:  page.Next,
+		prev:  page.Prev,
+	}, nil
+}
+
+func (r *trashResource[T]) restore(ctx context.Context, id *uuid.UUID) (interface{}, error) {
+	byID := &[]pg.Where{
+		{
+			Query: "id = ?",
+			Args:  []interface{}{id},
+		},
+	}
+
+	data, err := r.repository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ? AND deleted_at IS NOT NULL",
+				Args:  []interface{}{id},
+			},
+		},
+		IsUnscoped: true,
+		IsPrimary:  true,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, fmt.Sprintf("deleted %s not found", r.name))
+	}
+
+	if r.checkRestore != nil {
+		if err := r.checkRestore(ctx, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.repository().WithContext(ctx).Restore(&pg.RestoreOptions{
+		Where: byID,
+	}); err != nil {
+		return nil, apperror.NotFoundOr(err, fmt.Sprintf("deleted %s not found", r.name))
+	}
+
+	data, err = r.repository().WithContext(ctx).FindOne(&pg.FindOneOptions{
+		Where:     byID,
+		IsPrimary: true,
+	})
+	if err != nil {
+		return nil, apperror.NotFoundOr(err, fmt.Sprintf("%s not found", r.name))
+	}
+
+	return data, nil
+}
+
+// purge deletes for good the rows deleted before deletedBefore, one transaction per row. A row that other rows still
+// refer to, such as a category with deleted products that are not due yet, is kept for a later run.
+func (r *trashResource[T]) purge(ctx context.Context, db *pg.DB, deletedBefore time.Time) (int, error) {
+	purged := 0
+	var lastID *uuid.UUID
+	for {
+		where := []pg.FindAllWhere{
+			{
+				Where: pg.Where{
+					Query: "deleted_at < ?",
+					Args:  []interface{}{deletedBefore},
+				},
+				IncludeInCount: true,
+			},
+		}
+		if r.purgeWhere != nil {
+			where = append(where, pg.FindAllWhere{
+				Where:          *r.purgeWhere,
+				IncludeInCount: true,
+			})
+		}
+		if lastID != nil {
+			where = append(where, pg.FindAllWhere{
+				Where: pg.Where{
+					Query: "id > ?",
+					Args:  []interface{}{lastID},
+				},
+				IncludeInCount: true,
+			})
+		}
+
+		limit := pg.FindAllMaximumLimit
+		data, _, err := r.repository().WithContext(ctx).FindAll(&pg.FindAllOptions{
+			Where:       &where,
+			Order:       &[]string{"id"},
+			Limit:       &limit,
+			IsUnscoped:  true,
+			IsSkipCount: true,
+			IsPrimary:   true,
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		for _, row := range *data {
+			id := (*row).GetID()
+
+			txs := []func(tx *pg.DB) *pg.DB{}
+			var cleanup func()
+			if r.purgeDependents != nil {
+				txs, cleanup, err = r.purgeDependents(ctx, id)
+				if err != nil {
+					return purged, err
+				}
+			}
+			txs = append(txs, func(tx *pg.DB) *pg.DB {
+				return r.repository().DestroyTx(tx, row, &pg.DestroyOptions{
+					Where: &[]pg.Where{
+						{
+							Query: "id = ?",
+							Args:  []interface{}{id},
+						},
+					},
+					IsUnscoped: true,
+				})
+			})
+
+			if err := pg.Transaction(db.WithContext(ctx), txs...); err != nil {
+				var constraintError *pg.ConstraintError
+				if errors.As(err, &constraintError) {
+					logger.Log(fmt.Sprintf("kept deleted %s %s, which is still referred to", r.name, id))
+					continue
+				}
+				return purged, err
+			}
+			if cleanup != nil {
+				cleanup()
+			}
+			purged++
+		}
+
+		if len(*data) < limit {
+			return purged, nil
+		}
+		lastID = (*(*data)[len(*data)-1]).GetID()
+	}
+}
+
+// purgeTrashService purges every resource in turn. A resource that fails is logged and does not stop the others.
+func (m *Module) purgeTrashService(ctx context.Context, deletedBefore time.Time) {
+	for _, route := range m.trashRoutes() {
+		purged, err := route.resource.purge(ctx, m.DB, deletedBefore)
+		if err != nil {
+			logger.Error(err)
+		}
+		if purged > 0 {
+			logger.Log(fmt.Sprintf("purged %d deleted %s", purged, route.path))
+		}
+	}
+}
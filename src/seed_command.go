@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/slug"
+	p "hilmy.dev/store/src/modules/product/product_entity"
+	pc "hilmy.dev/store/src/modules/product_category/product_category_entity"
+)
+
+type seedProduct struct {
+	title       string
+	description string
+	price       int
+}
+
+var seedData = map[string][]seedProduct{
+	"Electronics": {
+		{title: "Wireless Mouse", description: "Ergonomic 2.4 GHz wireless mouse with silent clicks.", price: 150000},
+		{title: "Mechanical Keyboard", description: "Tenkeyless mechanical keyboard with hot-swappable switches.", price: 850000},
+	},
+	"Books": {
+		{title: "The Go Programming Language", description: "A comprehensive introduction to Go.", price: 450000},
+	},
+	"Apparel": {
+		{title: "Cotton T-Shirt", description: "Plain crew neck t-shirt made of combed cotton.", price: 90000},
+	},
+}
+
+// seedCommand inserts sample data for development. Existing categories and products with the same name are left untouched.
+func seedCommand(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	flags.Parse(args)
+
+	pgDB := newPgDB()
+	pc.InitRepository(pgDB)
+	p.InitRepository(pgDB)
+
+	createdCategories := 0
+	createdProducts := 0
+	for categoryName, products := range seedData {
+		categoryName := categoryName
+
+		categoryDetailData, err := pc.ProductCategoryRepository().FindOne(&pg.FindOneOptions{
+			Where: &[]pg.Where{
+				{
+					Query: "name = ? AND parent_id IS NULL",
+					Args:  []interface{}{categoryName},
+				},
+			},
+			IsPrimary: true,
+		})
+		if pg.IsErrRecordNotFound(err) {
+			categoryID := uuid.New()
+			categorySlug := slug.Make(categoryName)
+			categoryPath := pc.NewPath(nil, categoryID)
+			categoryDetailData, err = pc.ProductCategoryRepository().Create(&pc.ProductCategoryModel{
+				Model: pg.Model{
+					ID: &categoryID,
+				},
+				Name: &categoryName,
+				Slug: &categorySlug,
+				Path: &categoryPath,
+			})
+			createdCategories++
+		}
+		exitOnError(err)
+
+		for i := range products {
+			product := products[i]
+
+			count, err := p.ProductRepository().Count(&pg.CountOptions{
+				Where: &[]pg.Where{
+					{
+						Query: "category_id = ? AND title = ?",
+						Args:  []interface{}{categoryDetailData.ID, product.title},
+					},
+				},
+			})
+			exitOnError(err)
+			if *count > 0 {
+				continue
+			}
+
+			status := p.STATUS_PUBLISHED
+			_, err = p.ProductRepository().Create(&p.ProductModel{
+				CategoryID:  categoryDetailData.ID,
+				Title:       &product.title,
+				Description: &product.description,
+				Price:       &product.price,
+				Status:      &status,
+			})
+			exitOnError(err)
+			createdProducts++
+		}
+	}
+
+	logger.Log(fmt.Sprintf("seeded %d categories and %d products", createdCategories, createdProducts))
+	closeResources()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"hilmy.dev/store/src/libs/db/pg"
+	"hilmy.dev/store/src/libs/hash/argon2"
+	acc "hilmy.dev/store/src/modules/account/account_entity"
+	b "hilmy.dev/store/src/modules/balance/balance_entity"
+)
+
+const userUsage = "usage: store user <create | reset-password | set-role> [flags]"
+
+func userCommand(args []string) {
+	if len(args) == 0 {
+		exitWithUsage(userUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		userCreateCommand(args[1:])
+	case "reset-password":
+		userResetPasswordCommand(args[1:])
+	case "set-role":
+		userSetRoleCommand(args[1:])
+	default:
+		exitWithUsage(userUsage)
+	}
+}
+
+func userCreateCommand(args []string) {
+	flags := flag.NewFlagSet("user create", flag.ExitOnError)
+	name := flags.String("name", "", "display name of the account")
+	username := flags.String("username", "", "unique username of the account")
+	password := flags.String("password", "", "password of the account, read from stdin when empty")
+	role := flags.String("role", string(acc.ROLE_USER), "role of the account (ADMIN or USER)")
+	flags.Parse(args)
+
+	if len(*name) == 0 || len(*username) == 0 {
+		exitWithUsage("usage: store user create -name <name> -username <username> [-password <password>] [-role <ADMIN|USER>]")
+	}
+	accountRole, err := parseRole(*role)
+	exitOnError(err)
+	if len(*password) == 0 {
+		*password = readSecret("password: ")
+	}
+
+	pgDB := newPgDB()
+	initArgon2()
+	acc.InitRepository(pgDB)
+	b.InitRepository(pgDB)
+
+	encodedHash, err := argon2.GetEncodedHash(password)
+	exitOnError(err)
+
+	accountDetailData := &acc.AccountModel{
+		Name:     name,
+		Username: username,
+		Password: encodedHash,
+		Role:     accountRole,
+	}
+	txs := []func(tx *pg.DB) *pg.DB{
+		func(tx *pg.DB) *pg.DB {
+			return acc.AccountRepository().CreateTx(tx, accountDetailData)
+		},
+	}
+	// Only customers own a balance, the same as accounts created through signup.
+	if *accountRole == acc.ROLE_USER {
+		balanceAmount := 0
+		txs = append(txs, func(tx *pg.DB) *pg.DB {
+			return b.BalanceRepository().CreateTx(tx, &b.BalanceModel{
+				UserID: accountDetailData.ID,
+				Amount: &balanceAmount,
+			})
+		})
+	}
+	exitOnError(pg.Transaction(pgDB, txs...))
+
+	logger.Log(fmt.Sprintf("created %s account %s with id %s", *accountRole, *username, accountDetailData.ID))
+	closeResources()
+}
+
+func userResetPasswordCommand(args []string) {
+	flags := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	username := flags.String("username", "", "username of the account")
+	password := flags.String("password", "", "new password, read from stdin when empty")
+	flags.Parse(args)
+
+	if len(*username) == 0 {
+		exitWithUsage("usage: store user reset-password -username <username> [-password <password>]")
+	}
+	if len(*password) == 0 {
+		*password = readSecret("new password: ")
+	}
+
+	pgDB := newPgDB()
+	initArgon2()
+	acc.InitRepository(pgDB)
+
+	accountDetailData, err := getAccountByUsername(username)
+	exitOnError(err)
+
+	encodedHash, err := argon2.GetEncodedHash(password)
+	exitOnError(err)
+
+	_, err = acc.AccountRepository().Update(&acc.AccountModel{
+		Password: encodedHash,
+	}, &pg.UpdateOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{accountDetailData.ID},
+			},
+		},
+	})
+	exitOnError(err)
+
+	logger.Log("reset password of account " + *username)
+	closeResources()
+}
+
+func userSetRoleCommand(args []string) {
+	flags := flag.NewFlagSet("user set-role", flag.ExitOnError)
+	username := flags.String("username", "", "username of the account")
+	role := flags.String("role", "", "new role of the account (ADMIN or USER)")
+	flags.Parse(args)
+
+	if len(*username) == 0 || len(*role) == 0 {
+		exitWithUsage("usage: store user set-role -username <username> -role <ADMIN|USER>")
+	}
+	accountRole, err := parseRole(*role)
+	exitOnError(err)
+
+	pgDB := newPgDB()
+	acc.InitRepository(pgDB)
+
+	accountDetailData, err := getAccountByUsername(username)
+	exitOnError(err)
+
+	_, err = acc.AccountRepository().Update(&acc.AccountModel{
+		Role: accountRole,
+	}, &pg.UpdateOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "id = ?",
+				Args:  []interface{}{accountDetailData.ID},
+			},
+		},
+	})
+	exitOnError(err)
+
+	logger.Log(fmt.Sprintf("set role of account %s to %s", *username, *accountRole))
+	closeResources()
+}
+
+func parseRole(role string) (*acc.Role, error) {
+	accountRole := acc.Role(role)
+	if accountRole != acc.ROLE_ADMIN && accountRole != acc.ROLE_USER {
+		return nil, fmt.Errorf("unknown role: %s", role)
+	}
+	return &accountRole, nil
+}
+
+func getAccountByUsername(username *string) (*acc.AccountModel, error) {
+	accountDetailData, err := acc.AccountRepository().FindOne(&pg.FindOneOptions{
+		Where: &[]pg.Where{
+			{
+				Query: "username = ?",
+				Args:  []interface{}{username},
+			},
+		},
+		IsPrimary: true,
+	})
+	if err != nil {
+		if pg.IsErrRecordNotFound(err) {
+			return nil, errors.New("account not found: " + *username)
+		}
+		return nil, err
+	}
+	return accountDetailData, nil
+}